@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/timolinn/dns/cmd/api/handlers"
+	"github.com/timolinn/dns/middleware"
+	"github.com/timolinn/dns/pkg/audit"
+	"github.com/timolinn/dns/pkg/auth"
+	"github.com/timolinn/dns/pkg/config"
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// locate solves navigation requests offline with the navigator the
+// server uses, for the sector and system types of the configuration
+// given by args like serve. The coordinates and velocity are given as
+// arguments, or else read from in as one JSON request per line, eg.
+// {"x": "123.12", "y": "456.56", "z": "789.89", "vel": "20.0"}.
+func locate(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("locate", flag.ContinueOnError)
+	system := fs.String("system", string(handlers.Drone), "system type: 'drone' or 'ship'")
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		return err
+	}
+	served := false
+	for _, s := range cfg.Navigation.Systems {
+		served = served || s == *system
+	}
+	if !served {
+		return errors.Errorf("system type %q is not served: requires one of %s", *system, strings.Join(cfg.Navigation.Systems, ", "))
+	}
+
+	nav := &handlers.SectorNavigator{SectorID: cfg.Navigation.Sector}
+	sys := handlers.System(*system)
+	enc := json.NewEncoder(out)
+	solve := func(cv handlers.CoordsVelocity) error {
+		loc, err := nav.Solve(context.Background(), cv, sys)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(nav.Response(loc, sys))
+	}
+
+	if fs.NArg() > 0 {
+		if fs.NArg() != 4 {
+			return errors.New("locate requires x, y, z and vel")
+		}
+		var v [4]float64
+		for i, arg := range fs.Args() {
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return errors.Errorf("invalid number %q", arg)
+			}
+			v[i] = n
+		}
+		cv := handlers.CoordsVelocity{X: v[0], Y: v[1], Z: v[2], Vel: v[3]}
+		if err := web.Validate(&cv); err != nil {
+			return validationError(err)
+		}
+		return solve(cv)
+	}
+
+	scanner := bufio.NewScanner(in)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var cv handlers.CoordsVelocity
+		dec := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cv); err != nil {
+			return errors.Wrapf(web.ErrMalformedRequestData, "line %d", line)
+		}
+		if err := web.Validate(&cv); err != nil {
+			return errors.Wrapf(validationError(err), "line %d", line)
+		}
+		if err := solve(cv); err != nil {
+			return errors.Wrapf(err, "line %d", line)
+		}
+	}
+	return scanner.Err()
+}
+
+// validationError lists the fields failing validation in err
+func validationError(err error) error {
+	webErr, ok := err.(*web.Error)
+	if !ok {
+		return err
+	}
+	var fields []string
+	for _, f := range webErr.Fields {
+		fields = append(fields, f.Error)
+	}
+	return errors.Errorf("%s: %s", webErr.Err, strings.Join(fields, ", "))
+}
+
+// configCmd validates or prints the configuration the server would
+// be started with, given the same flags.
+func configCmd(args []string, out io.Writer) error {
+	if len(args) == 0 || (args[0] != "validate" && args[0] != "print") {
+		return errors.New("config requires 'validate' or 'print'")
+	}
+	cfg, _, err := loadConfig(args[1:], os.Stderr)
+	if _, invalid := err.(*config.ValidationError); args[0] == "print" && (err == nil || invalid) {
+		data, _ := json.MarshalIndent(cfg.Redacted(), "", "  ")
+		fmt.Fprintln(out, string(data))
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "configuration is valid")
+	return nil
+}
+
+// routes lists the routes the server mounts for the configuration
+// given by args, on the API and on the admin listener.
+func routes(args []string, out io.Writer) error {
+	cfg, _, err := loadConfig(args, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	shutdown := make(chan os.Signal, 1)
+	logger := log.New(ioutil.Discard, "", 0)
+	live := handlers.NewLive(settings(cfg))
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LISTENER\tMETHOD\tPATH")
+	list := func(listener string, app *web.App) {
+		for _, r := range app.Routes() {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", listener, r.Method, r.Path)
+		}
+	}
+	// stand-ins replace the stores, so that listing
+	// the routes does not create or read their files
+	var st stores
+	if cfg.Auth.Keys != "" {
+		st.keys = auth.NewKeyStore()
+	}
+	if cfg.Idempotency.TTL > 0 {
+		st.idempotency = middleware.NewMemoryIdempotencyStore(1, time.Second)
+	}
+	if cfg.Log.Access.Path != "" {
+		st.access = ioutil.Discard
+	}
+	if cfg.Log.Audit.Path != "" {
+		st.audit = new(audit.Log)
+	}
+	if cfg.Log.Record != "" {
+		st.record = ioutil.Discard
+	}
+	opts := append(handlerOptions(cfg, live, st), handlers.WithReload(func() error { return nil }))
+	list("api", handlers.Register(shutdown, logger, opts...))
+	if cfg.Admin.Addr != "" {
+		list("admin", handlers.RegisterAdmin(shutdown, logger, handlers.AdminOptions{Live: live}))
+	}
+	return tw.Flush()
+}
+
+// keysCmd administers the API keys of a key store file, like the
+// /v1/admin/keys endpoints. A running server picks up the changes
+// when its configuration is reloaded.
+func keysCmd(args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("keys requires 'list', 'create', 'rotate' or 'revoke'")
+	}
+	fs := flag.NewFlagSet("keys "+args[0], flag.ContinueOnError)
+	file := fs.String("file", os.Getenv(config.EnvPrefix+"AUTH_KEYS"), "path to the key store, defaults to $DNS_AUTH_KEYS")
+	fleet := fs.String("fleet", "", "fleet of the key to create")
+	systems := fs.String("systems", "", "comma separated system types the created key is bound to")
+	admin := fs.Bool("admin", false, "create an administrator key")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("keys requires -file or DNS_AUTH_KEYS")
+	}
+
+	store, err := auth.OpenKeyStore(*file)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+
+	id := func() (string, error) {
+		if fs.NArg() != 1 {
+			return "", errors.Errorf("keys %s requires the ID of a key", args[0])
+		}
+		return fs.Arg(0), nil
+	}
+
+	switch args[0] {
+	case "list":
+		return enc.Encode(store.List())
+
+	case "create":
+		if *fleet == "" {
+			return errors.New("keys create requires -fleet")
+		}
+		var list []string
+		for _, s := range strings.Split(*systems, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			if s != string(handlers.Drone) && s != string(handlers.Ship) {
+				return errors.Errorf("unknown system type %q: requires 'drone' or 'ship'", s)
+			}
+			list = append(list, s)
+		}
+		key, secret, err := store.Create(*fleet, list, *admin)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(handlers.IssuedKey{Key: key, Secret: secret})
+
+	case "rotate":
+		id, err := id()
+		if err != nil {
+			return err
+		}
+		key, secret, err := store.Rotate(id)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(handlers.IssuedKey{Key: key, Secret: secret})
+
+	case "revoke":
+		id, err := id()
+		if err != nil {
+			return err
+		}
+		key, err := store.Revoke(id)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(key)
+	}
+	return errors.Errorf("unknown keys command %q: requires 'list', 'create', 'rotate' or 'revoke'", args[0])
+}
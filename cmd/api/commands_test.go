@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/timolinn/dns/cmd/api/handlers"
+	"github.com/timolinn/dns/pkg/config"
+)
+
+func TestLocate(t *testing.T) {
+	t.Run("should solve for the configured sector", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := locate([]string{"-sector", "2", "1", "2", "3", "4"}, nil, &out); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		var got map[string]float64
+		if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		// (1 + 2 + 3 + 4) * 2
+		if got["loc"] != 20 {
+			t.Errorf("want loc 20, got %v", got)
+		}
+	})
+
+	t.Run("should read requests from stdin", func(t *testing.T) {
+		in := strings.NewReader(`{"x": "1", "y": "2", "z": "3", "vel": "4"}` + "\n\n" + `{"x": "2", "y": "2", "z": "2", "vel": "2"}` + "\n")
+		var out bytes.Buffer
+		if err := locate([]string{"-system", "ship"}, in, &out); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if lines := strings.Count(out.String(), "\n"); lines != 2 {
+			t.Errorf("want 2 locations, got %q", out.String())
+		}
+	})
+
+	t.Run("should validate the arguments", func(t *testing.T) {
+		err := locate([]string{"0", "2", "3", "4"}, nil, ioutil.Discard)
+		if err == nil || !strings.Contains(err.Error(), "x is a required field") {
+			t.Errorf("want a validation error, got %v", err)
+		}
+	})
+
+	t.Run("should reject system types not served", func(t *testing.T) {
+		if err := locate([]string{"-systems", "drone", "-system", "ship", "1", "2", "3", "4"}, nil, ioutil.Discard); err == nil {
+			t.Error("Should reject the ship system type")
+		}
+	})
+
+	t.Run("should reject invalid configurations", func(t *testing.T) {
+		err := locate([]string{"-sector", "-1", "1", "2", "3", "4"}, nil, ioutil.Discard)
+		if _, ok := err.(*config.ValidationError); !ok {
+			t.Errorf("want *config.ValidationError, got %v", err)
+		}
+	})
+}
+
+func TestConfigCmd(t *testing.T) {
+	var out bytes.Buffer
+	if err := configCmd([]string{"validate", "-sector", "2"}, &out); err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	if out.String() != "configuration is valid\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	err := configCmd([]string{"print", "-systems", "tank", "-jwt-secret", "hunter2"}, &out)
+	if _, ok := err.(*config.ValidationError); !ok {
+		t.Errorf("want *config.ValidationError, got %v", err)
+	}
+	var cfg config.Config
+	if err := json.Unmarshal(out.Bytes(), &cfg); err != nil {
+		t.Fatalf("Should print the invalid configuration, got %s", err)
+	}
+	if strings.Join(cfg.Navigation.Systems, ",") != "tank" || strings.Contains(out.String(), "hunter2") {
+		t.Errorf("unexpected configuration %s", out.String())
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "routes")
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	defer os.RemoveAll(dir)
+	keys := filepath.Join(dir, "keys.json")
+
+	var out bytes.Buffer
+	if err := routes([]string{"-keys", keys, "-limit", "-admin-addr", "127.0.0.1:6060", "-admin-token", strings.Repeat("t", 16)}, &out); err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	for _, want := range []string{"api       POST    /v1/locate", "/v1/admin/keys", "/v1/admin/limits", "admin"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("want %q listed, got\n%s", want, out.String())
+		}
+	}
+	if _, err := os.Stat(keys); !os.IsNotExist(err) {
+		t.Errorf("Should not create the key store, got %v", err)
+	}
+}
+
+func TestKeysCmd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "keys.json")
+
+	run := func(args ...string) []byte {
+		var out bytes.Buffer
+		if err := keysCmd(args, &out); err != nil {
+			t.Fatalf("%q: expected nil-err got %s", args, err)
+		}
+		return out.Bytes()
+	}
+
+	var issued handlers.IssuedKey
+	if err := json.Unmarshal(run("create", "-file", file, "-fleet", "alpha", "-systems", "drone"), &issued); err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	if issued.Secret == "" || issued.Fleet != "alpha" {
+		t.Errorf("unexpected key %+v", issued)
+	}
+
+	var rotated handlers.IssuedKey
+	json.Unmarshal(run("rotate", "-file", file, issued.ID), &rotated)
+	if rotated.Secret == "" || rotated.Secret == issued.Secret {
+		t.Errorf("want a new secret, got %+v", rotated)
+	}
+	run("revoke", "-file", file, issued.ID)
+
+	var keys []map[string]interface{}
+	if err := json.Unmarshal(run("list", "-file", file), &keys); err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	if len(keys) != 1 || keys[0]["revoked_at"] == nil {
+		t.Errorf("want the revoked key listed, got %v", keys)
+	}
+
+	for _, args := range [][]string{
+		{"create", "-file", file},
+		{"create", "-file", file, "-fleet", "alpha", "-systems", "tank"},
+		{"rotate", "-file", file},
+		{"purge", "-file", file},
+	} {
+		if err := keysCmd(args, ioutil.Discard); err == nil {
+			t.Errorf("%q: want an error", args)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"os"
+
+	"github.com/timolinn/dns/middleware"
+	"github.com/timolinn/dns/pkg/auth"
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// ErrUnknownFeature is reported when toggling a feature the App lacks
+var ErrUnknownFeature = errors.New("unknown feature")
+
+// AdminOptions configures the admin App built by RegisterAdmin
+type AdminOptions struct {
+	// Token is the bearer token required by every admin request.
+	Token string
+
+	// Live holds the settings changed by the runtime controls, it
+	// must be the Live the public App was registered with.
+	Live *Live
+
+	// Config returns the effective configuration, secrets redacted.
+	Config func() interface{}
+}
+
+// RegisterAdmin registers the handlers of the admin listener, kept apart
+// from the public App: net/http/pprof profiles, expvar variables, the
+// effective configuration and runtime controls of the log level and of
+// the features of the public App.
+func RegisterAdmin(shutdown chan os.Signal, log *log.Logger, o AdminOptions) *web.App {
+	token := auth.NewTokenAuthenticator(o.Token, auth.Principal{ID: "admin", Scopes: []string{"admin:*"}})
+	app := web.NewApp(shutdown, middleware.Logger(log, &o.Live.level), middleware.Authenticate(token))
+
+	// the named profiles are served by pprof.Index, the other pprof
+	// handlers must be mounted first. The command line is left out as
+	// it may carry secrets given as flags
+	app.MountHandler(http.MethodGet, "/debug/pprof/", std(pprof.Index))
+	app.MountHandler(http.MethodGet, "/debug/pprof/profile", std(pprof.Profile))
+	app.MountHandler(http.MethodGet, "/debug/pprof/symbol", std(pprof.Symbol))
+	app.MountHandler(http.MethodPost, "/debug/pprof/symbol", std(pprof.Symbol))
+	app.MountHandler(http.MethodGet, "/debug/pprof/trace", std(pprof.Trace))
+	app.MountHandler(http.MethodGet, "/debug/pprof/{profile}", std(pprof.Index))
+	app.MountHandler(http.MethodGet, "/debug/vars", vars)
+
+	a := admin{live: o.Live, config: o.Config}
+	app.MountHandler(http.MethodGet, "/config", a.effectiveConfig)
+	app.MountHandler(http.MethodGet, "/log/level", a.logLevel)
+	app.MountHandler(http.MethodPut, "/log/level", a.setLogLevel)
+	app.MountHandler(http.MethodGet, "/features", a.features)
+	app.MountHandler(http.MethodPut, "/features/{name}", a.toggle)
+
+	return app
+}
+
+// std adapts a net/http handler
+func std(h http.HandlerFunc) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		h(w, r)
+		return nil
+	}
+}
+
+// vars serves the expvar variables like expvar.Handler, except for
+// the command line which may carry secrets given as flags
+func vars(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintf(w, "{\n")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if kv.Key == "cmdline" {
+			return
+		}
+		if !first {
+			fmt.Fprintf(w, ",\n")
+		}
+		first = false
+		fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+	})
+	fmt.Fprintf(w, "\n}\n")
+	return nil
+}
+
+// admin serves the runtime controls of the admin listener
+type admin struct {
+	live   *Live
+	config func() interface{}
+}
+
+// LogLevel is the request log level
+type LogLevel struct {
+	Level string `json:"level" validate:"required"`
+}
+
+// Feature tells whether a feature of the App is enabled
+type Feature struct {
+	Enabled bool `json:"enabled"`
+}
+
+func (a *admin) effectiveConfig(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var cfg interface{}
+	if a.config != nil {
+		cfg = a.config()
+	}
+	return web.Respond(ctx, w, cfg, http.StatusOK)
+}
+
+func (a *admin) logLevel(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	level := LogLevel{Level: a.live.Settings().LogLevel.String()}
+	return web.Respond(ctx, w, level, http.StatusOK)
+}
+
+func (a *admin) setLogLevel(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var req LogLevel
+	if err := web.Decode(r, &req); err != nil {
+		return web.RespondError(ctx, w, err)
+	}
+	level, err := middleware.ParseLevel(req.Level)
+	if err != nil {
+		return web.RespondError(ctx, w, web.NewRequestError(err, http.StatusBadRequest))
+	}
+	a.live.SetLogLevel(level)
+	return web.Respond(ctx, w, LogLevel{Level: level.String()}, http.StatusOK)
+}
+
+func (a *admin) features(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return web.Respond(ctx, w, a.live.Features(), http.StatusOK)
+}
+
+func (a *admin) toggle(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var req Feature
+	if err := web.Decode(r, &req); err != nil {
+		return web.RespondError(ctx, w, err)
+	}
+	if !a.live.Toggle(web.Params(r)["name"], req.Enabled) {
+		return web.RespondError(ctx, w, web.NewRequestError(ErrUnknownFeature, http.StatusNotFound))
+	}
+	return web.Respond(ctx, w, a.live.Features(), http.StatusOK)
+}
@@ -0,0 +1,99 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timolinn/dns/cmd/api/handlers"
+)
+
+func TestAdmin(t *testing.T) {
+	shutdown := make(chan os.Signal, 1)
+	logger := log.New(os.Stdout, "TEST : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+
+	const token = "0123456789abcdef"
+	live := handlers.NewLive(handlers.Settings{Sector: 1})
+	app := handlers.Register(shutdown, logger, handlers.WithLive(live), handlers.WithNavigatorCache(10, time.Minute))
+	admin := handlers.RegisterAdmin(shutdown, logger, handlers.AdminOptions{
+		Token:  token,
+		Live:   live,
+		Config: func() interface{} { return map[string]string{"addr": ":8080"} },
+	})
+
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("should require the admin token", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/config", nil)
+		r.Header.Set("Authorization", "Bearer not-the-token")
+		w := httptest.NewRecorder()
+		admin.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Should receive status code %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+	})
+
+	t.Run("should serve the effective configuration and expvar", func(t *testing.T) {
+		if w := call(http.MethodGet, "/config", ""); w.Body.String() != `{"addr":":8080"}` {
+			t.Errorf("unexpected configuration %s", w.Body.String())
+		}
+		w := call(http.MethodGet, "/debug/vars", "")
+		var vars map[string]json.RawMessage
+		if err := json.Unmarshal(w.Body.Bytes(), &vars); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if _, ok := vars["memstats"]; !ok {
+			t.Errorf("want memstats, got %s", w.Body.String())
+		}
+		if _, ok := vars["cmdline"]; ok {
+			t.Errorf("want cmdline left out")
+		}
+	})
+
+	t.Run("should change the log level", func(t *testing.T) {
+		if w := call(http.MethodPut, "/log/level", `{"level":"warn"}`); w.Code != http.StatusOK {
+			t.Errorf("Should receive status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if level := live.Settings().LogLevel.String(); level != "warn" {
+			t.Errorf("want level warn, got %s", level)
+		}
+		if w := call(http.MethodPut, "/log/level", `{"level":"loud"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("Should receive status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("should toggle features", func(t *testing.T) {
+		if w := call(http.MethodPut, "/features/cache", `{"enabled":false}`); w.Code != http.StatusOK {
+			t.Errorf("Should receive status code %d, got %d", http.StatusOK, w.Code)
+		}
+		if live.Features()["cache"] {
+			t.Errorf("want cache disabled")
+		}
+
+		r := httptest.NewRequest(http.MethodPost, "/v1/locate", bytes.NewReader([]byte(`{"x":"1","y":"1","z":"1","vel":"1"}`)))
+		r.Header.Set("X-System-Type", "drone")
+		app.ServeHTTP(httptest.NewRecorder(), r)
+
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/admin/cache", nil))
+		if !strings.Contains(w.Body.String(), `"misses":0`) {
+			t.Errorf("want the cache bypassed, got %s", w.Body.String())
+		}
+
+		if w := call(http.MethodPut, "/features/teleport", `{"enabled":true}`); w.Code != http.StatusNotFound {
+			t.Errorf("Should receive status code %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/timolinn/dns/pkg/audit"
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// audits queries the audit trail of locate requests
+type audits struct {
+	log *audit.Log
+}
+
+// query lists audit records filtered by the principal, system, sector,
+// from and to (RFC 3339) and limit query parameters.
+func (a *audits) query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	f, err := audit.ParseFilter(r.URL.Query().Get)
+	if err != nil {
+		return web.RespondError(ctx, w, web.NewRequestError(err, http.StatusBadRequest))
+	}
+
+	records, err := a.log.Query(f)
+	if err != nil {
+		return web.RespondError(ctx, w, err)
+	}
+	return web.Respond(ctx, w, records, http.StatusOK)
+}
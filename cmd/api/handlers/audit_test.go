@@ -0,0 +1,138 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/timolinn/dns/cmd/api/handlers"
+	"github.com/timolinn/dns/middleware"
+	"github.com/timolinn/dns/pkg/audit"
+	"github.com/timolinn/dns/pkg/auth"
+)
+
+func TestAudit(t *testing.T) {
+	var payload = []byte(`{"x":"123.12","z":"789.89","y":"456.56", "vel":"20.0"}`)
+
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	defer os.RemoveAll(dir)
+	trail, err := audit.Open(filepath.Join(dir, "audit.log"), false)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	defer trail.Close()
+
+	store := auth.NewKeyStore()
+	_, adminKey, _ := store.Create("admin", nil, true)
+	_, droneKey, _ := store.Create("alpha", []string{"drone"}, false)
+
+	shutdown := make(chan os.Signal, 1)
+	logger := log.New(os.Stdout, "TEST : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	app := handlers.Register(shutdown, logger,
+		handlers.WithKeyStore(store),
+		handlers.WithAudit(trail),
+		handlers.WithIdempotency(middleware.NewMemoryIdempotencyStore(100, time.Minute), time.Minute),
+	)
+
+	serve := func(method, path, key string, body []byte, header ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, bytes.NewReader(body))
+		r.Header.Set(auth.HeaderAPIKey, key)
+		for i := 0; i+1 < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		return w
+	}
+
+	requests := []struct {
+		key    string
+		system string
+		body   []byte
+		status int
+	}{
+		{droneKey, "drone", payload, http.StatusOK},
+		{droneKey, "drone", []byte(`{"x":"1"}`), http.StatusUnprocessableEntity},
+		{"", "drone", payload, http.StatusUnauthorized},
+		{droneKey, "ship", payload, http.StatusForbidden},
+	}
+	for _, req := range requests {
+		if w := serve(http.MethodPost, "/v1/locate", req.key, req.body, "X-System-Type", req.system); w.Code != req.status {
+			t.Fatalf("Should receive status code %d, got %d", req.status, w.Code)
+		}
+	}
+	// the replay of an idempotent request is audited as a request too
+	for i := 0; i < 2; i++ {
+		serve(http.MethodPost, "/v1/locate", droneKey, payload, "X-System-Type", "drone", middleware.HeaderIdempotencyKey, "retry-1")
+	}
+
+	query := func(q string) []audit.Record {
+		w := serve(http.MethodGet, "/v1/admin/audit"+q, adminKey, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Should receive status code %d, got %d", http.StatusOK, w.Code)
+		}
+		var records []audit.Record
+		if err := json.Unmarshal(w.Body.Bytes(), &records); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		return records
+	}
+
+	t.Run("should audit every locate request once", func(t *testing.T) {
+		records := query("")
+		want := []int{http.StatusOK, http.StatusUnprocessableEntity, http.StatusUnauthorized, http.StatusForbidden, http.StatusOK, http.StatusOK}
+		if len(records) != len(want) {
+			t.Fatalf("want %d records, got %+v", len(want), records)
+		}
+		for i, r := range records {
+			if r.Status != want[i] {
+				t.Errorf("record %d: want status %d, got %d", r.Seq, want[i], r.Status)
+			}
+			if r.Version == "" {
+				t.Errorf("record %d: want the version of the build", r.Seq)
+			}
+			if r.RequestID == "" {
+				t.Errorf("record %d: want a request ID", r.Seq)
+			}
+		}
+		if r := records[0]; r.Principal == "" || r.System != "drone" || len(r.Input) == 0 || len(r.Output) == 0 {
+			t.Errorf("want the caller, input and output of a located request, got %+v", r)
+		}
+		if r := records[2]; r.Principal != "" || r.Error == "" {
+			t.Errorf("want the error of a rejected request, got %+v", r)
+		}
+		for _, i := range []int{3, 5} {
+			if r := records[i]; r.Principal == "" || r.Principal != records[0].Principal {
+				t.Errorf("want the caller of a request answered before locate, got %+v", r)
+			}
+		}
+	})
+
+	t.Run("should filter records", func(t *testing.T) {
+		if records := query("?system=ship"); len(records) != 1 || records[0].Status != http.StatusForbidden {
+			t.Errorf("want the forbidden request, got %+v", records)
+		}
+		if records := query("?limit=2"); len(records) != 2 {
+			t.Errorf("want 2 records, got %d", len(records))
+		}
+		if w := serve(http.MethodGet, "/v1/admin/audit?from=yesterday", adminKey, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("Should receive status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("should keep the audit trail to administrators", func(t *testing.T) {
+		if w := serve(http.MethodGet, "/v1/admin/audit", droneKey, nil); w.Code != http.StatusForbidden {
+			t.Errorf("Should receive status code %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// caches reports on and purges the navigator cache
+type caches struct {
+	nav *CachedNavigator
+}
+
+func (c *caches) stats(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return web.Respond(ctx, w, c.nav.Stats(), http.StatusOK)
+}
+
+func (c *caches) purge(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	c.nav.Purge()
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -0,0 +1,88 @@
+package handlers_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/timolinn/dns/cmd/api/handlers"
+)
+
+func TestCompression(t *testing.T) {
+	var payload = []byte(`{"x":"123.12","z":"789.89","y":"456.56", "vel":"20.0"}`)
+
+	shutdown := make(chan os.Signal, 1)
+	logger := log.New(os.Stdout, "TEST : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+
+	locate := func(app http.Handler, body []byte, header http.Header) *http.Response {
+		r := httptest.NewRequest(http.MethodPost, "/v1/locate", bytes.NewReader(body))
+		r.Header = header
+		r.Header.Set("X-System-Type", "drone")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	t.Run("should gzip responses over the minimum size", func(t *testing.T) {
+		app := handlers.Register(shutdown, logger, handlers.WithCompression(0))
+		result := locate(app, payload, http.Header{"Accept-Encoding": {"deflate;q=0.5, gzip"}})
+
+		if got := result.Header.Get("Content-Encoding"); got != "gzip" {
+			t.Fatalf("want gzip encoding, got %q", got)
+		}
+		if got := result.Header.Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("want Vary: Accept-Encoding, got %q", got)
+		}
+		zr, err := gzip.NewReader(result.Body)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		var got struct {
+			Loc float64 `json:"loc"`
+		}
+		if err := json.NewDecoder(zr).Decode(&got); err != nil {
+			t.Fatalf("should be able to unmarshal response")
+		}
+		if got.Loc != 1389.57 {
+			t.Errorf("want %v, got %v", 1389.57, got.Loc)
+		}
+	})
+
+	t.Run("should not compress small responses", func(t *testing.T) {
+		app := handlers.Register(shutdown, logger, handlers.WithCompression(1024))
+		result := locate(app, payload, http.Header{"Accept-Encoding": {"gzip"}})
+
+		if got := result.Header.Get("Content-Encoding"); got != "" {
+			t.Errorf("want no encoding, got %q", got)
+		}
+		if result.StatusCode != http.StatusOK {
+			t.Errorf("Should receive status code %d, got %d", http.StatusOK, result.StatusCode)
+		}
+	})
+
+	t.Run("should decode gzip request bodies", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(payload)
+		zw.Close()
+
+		app := handlers.Register(shutdown, logger)
+		result := locate(app, buf.Bytes(), http.Header{"Content-Encoding": {"gzip"}})
+		if result.StatusCode != http.StatusOK {
+			t.Errorf("Should receive status code %d, got %d", http.StatusOK, result.StatusCode)
+		}
+	})
+
+	t.Run("should reject unknown request encodings", func(t *testing.T) {
+		app := handlers.Register(shutdown, logger)
+		result := locate(app, payload, http.Header{"Content-Encoding": {"compress"}})
+		if result.StatusCode != http.StatusUnsupportedMediaType {
+			t.Errorf("Should receive status code %d, got %d", http.StatusUnsupportedMediaType, result.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,87 @@
+package handlers_test
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/timolinn/dns/cmd/api/handlers"
+	"github.com/timolinn/dns/middleware"
+	"github.com/timolinn/dns/pkg/auth"
+)
+
+func TestCORS(t *testing.T) {
+	var payload = []byte(`{"x":"123.12","z":"789.89","y":"456.56", "vel":"20.0"}`)
+
+	shutdown := make(chan os.Signal, 1)
+	logger := log.New(os.Stdout, "TEST : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+
+	cfg := middleware.DefaultCORSConfig
+	cfg.AllowedOrigins = []string{"https://dashboard.fleet.example"}
+
+	// preflight requests never carry credentials, so they must
+	// be answered even when authentication is required
+	app := handlers.Register(shutdown, logger, handlers.WithCORS(cfg), handlers.WithKeyStore(auth.NewKeyStore()))
+
+	preflight := func(origin, method, headers string) *http.Response {
+		r := httptest.NewRequest(http.MethodOptions, "/v1/locate", nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", method)
+		r.Header.Set("Access-Control-Request-Headers", headers)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	t.Run("should answer preflight requests for allowed origins", func(t *testing.T) {
+		result := preflight("https://dashboard.fleet.example", http.MethodPost, "content-type, x-system-type")
+		if result.StatusCode != http.StatusNoContent {
+			t.Errorf("Should receive status code %d, got %d", http.StatusNoContent, result.StatusCode)
+		}
+		if got := result.Header.Get("Access-Control-Allow-Origin"); got != "https://dashboard.fleet.example" {
+			t.Errorf("want allowed origin, got %q", got)
+		}
+		if got := result.Header.Get("Access-Control-Allow-Methods"); got == "" {
+			t.Errorf("should list allowed methods")
+		}
+		if got := result.Header.Get("Access-Control-Allow-Headers"); got != "content-type, x-system-type" {
+			t.Errorf("want allowed headers, got %q", got)
+		}
+	})
+
+	t.Run("should not allow other origins", func(t *testing.T) {
+		result := preflight("https://evil.example", http.MethodPost, "content-type")
+		if got := result.Header.Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("want no allowed origin, got %q", got)
+		}
+		if got := result.Header.Get("Allow"); got != "OPTIONS, POST" {
+			t.Errorf("want Allow header %q, got %q", "OPTIONS, POST", got)
+		}
+	})
+
+	t.Run("should not allow unlisted request headers", func(t *testing.T) {
+		result := preflight("https://dashboard.fleet.example", http.MethodPost, "x-unknown")
+		if got := result.Header.Get("Access-Control-Allow-Methods"); got != "" {
+			t.Errorf("want no allowed methods, got %q", got)
+		}
+	})
+
+	t.Run("should set CORS headers on actual requests", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/v1/locate", bytes.NewReader(payload))
+		r.Header.Set("Origin", "https://dashboard.fleet.example")
+		r.Header.Set("X-System-Type", "drone")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		result := w.Result()
+		if result.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Should receive status code %d, got %d", http.StatusUnauthorized, result.StatusCode)
+		}
+		if got := result.Header.Get("Access-Control-Allow-Origin"); got != "https://dashboard.fleet.example" {
+			t.Errorf("want allowed origin, got %q", got)
+		}
+	})
+}
@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/timolinn/dns/middleware"
+	"github.com/timolinn/dns/pkg/auth"
+	"github.com/timolinn/dns/pkg/dnspb"
+	"github.com/timolinn/dns/pkg/health"
+	"github.com/timolinn/dns/pkg/web"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrBatchTooLarge is returned for batches holding more requests than allowed
+	ErrBatchTooLarge = errors.New("too many requests in the batch")
+
+	// ErrSystemRequired is returned for gRPC requests naming no system
+	// type, by callers that are not bound to one
+	ErrSystemRequired = errors.New("system is a required field")
+)
+
+// HealthWatchInterval is how often the readiness checks run while
+// gRPC health watches are open
+var HealthWatchInterval = time.Second
+
+// systems maps the system types of the gRPC API to those of the App
+var systems = map[dnspb.System]System{
+	dnspb.System_SYSTEM_UNSPECIFIED: "",
+	dnspb.System_SYSTEM_DRONE:       Drone,
+	dnspb.System_SYSTEM_SHIP:        Ship,
+}
+
+// navigation serves the gRPC Navigation service with the navigator,
+// authenticators and audit log of the App
+type navigation struct {
+	dnspb.UnimplementedNavigationServer
+
+	loc            *location
+	authenticators []auth.Authenticator
+	override       bool
+	timeouts       map[string]time.Duration
+	limiters       map[string]*middleware.Limiter
+	maxBatch       int
+	log            *log.Logger
+	level          *middleware.LevelVar
+}
+
+// Locate returns the databank location of one system
+func (n *navigation) Locate(ctx context.Context, req *dnspb.LocateRequest) (*dnspb.LocateResponse, error) {
+	var resp *dnspb.LocateResponse
+	err := n.call(ctx, "Locate", true, func(ctx context.Context, r *http.Request) error {
+		var err error
+		resp, err = n.locate(ctx, r, req)
+		return err
+	})
+	return resp, err
+}
+
+// BatchLocate locates every request of the batch, failed requests
+// get their status as result
+func (n *navigation) BatchLocate(ctx context.Context, req *dnspb.BatchLocateRequest) (*dnspb.BatchLocateResponse, error) {
+	var resp dnspb.BatchLocateResponse
+	err := n.call(ctx, "BatchLocate", true, func(ctx context.Context, r *http.Request) error {
+		if len(req.Requests) > n.maxBatch {
+			return &web.Error{
+				Err:    ErrBatchTooLarge,
+				Status: http.StatusBadRequest,
+				Fields: []web.FieldError{{Field: "requests", Error: ErrBatchTooLarge.Error()}},
+			}
+		}
+		for _, lr := range req.Requests {
+			var result dnspb.LocateResult
+			loc, err := n.locate(ctx, r.Clone(ctx), lr)
+			if err != nil {
+				result.Error = status.Convert(grpcError(err)).Proto()
+			}
+			result.Response = loc
+			resp.Results = append(resp.Results, &result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetSector reports the sector served and the system types navigated,
+// it is public like the home page of the HTTP API
+func (n *navigation) GetSector(ctx context.Context, req *dnspb.GetSectorRequest) (*dnspb.Sector, error) {
+	sector := dnspb.Sector{Id: n.loc.nav.Sector()}
+	err := n.call(ctx, "GetSector", false, func(ctx context.Context, r *http.Request) error {
+		for _, sys := range []dnspb.System{dnspb.System_SYSTEM_DRONE, dnspb.System_SYSTEM_SHIP} {
+			if n.loc.live.serves(systems[sys]) {
+				sector.Systems = append(sector.Systems, sys)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sector, nil
+}
+
+// locate solves one request on behalf of the principal in ctx, if any,
+// and audits it like the HTTP API does. It fails with a *web.Error.
+func (n *navigation) locate(ctx context.Context, r *http.Request, req *dnspb.LocateRequest) (*dnspb.LocateResponse, error) {
+	system, ok := systems[req.System]
+	if !ok {
+		return nil, n.loc.recordError(ctx, r, nil, systemError(ErrUnknownSystemType, http.StatusBadRequest))
+	}
+	if p, ok := auth.FromContext(ctx); ok {
+		bound, err := p.BindSystem(string(system), n.override)
+		if err != nil {
+			return nil, n.loc.recordError(ctx, r, nil, web.NewRequestError(err, http.StatusForbidden))
+		}
+		system = System(bound)
+		if !p.HasScope("locate:" + bound) {
+			return nil, n.loc.recordError(ctx, r, nil, web.NewRequestError(auth.ErrForbidden, http.StatusForbidden))
+		}
+	}
+	if system == "" {
+		return nil, n.loc.recordError(ctx, r, nil, systemError(ErrSystemRequired, http.StatusUnprocessableEntity))
+	}
+	r.Header.Set("X-System-Type", string(system))
+
+	var cv CoordsVelocity
+	if c := req.Coordinates; c != nil {
+		cv = CoordsVelocity{X: c.X, Y: c.Y, Z: c.Z, Vel: c.Vel}
+	}
+	if err := web.Validate(&cv); err != nil {
+		if webErr, ok := err.(*web.Error); ok {
+			for i := range webErr.Fields {
+				webErr.Fields[i].Field = "coordinates." + webErr.Fields[i].Field
+			}
+		}
+		return nil, n.loc.recordError(ctx, r, nil, err)
+	}
+
+	result, err := n.loc.solve(ctx, cv, system)
+	if err == ErrUnknownSystemType {
+		err = systemError(err, http.StatusBadRequest)
+	}
+	if err != nil {
+		return nil, n.loc.recordError(ctx, r, &cv, err)
+	}
+
+	if err := n.loc.record(ctx, r, &cv, n.loc.nav.Response(result, system), http.StatusOK, nil); err != nil {
+		return nil, err
+	}
+	return &dnspb.LocateResponse{Location: result, Sector: n.loc.nav.Sector()}, nil
+}
+
+// systemError reports err on the system field of a request
+func systemError(err error, status int) error {
+	return &web.Error{
+		Err:    err,
+		Status: status,
+		Fields: []web.FieldError{{Field: "system", Error: err.Error()}},
+	}
+}
+
+// call runs a call of the Navigation service the way web.App runs a
+// request: with a request ID, under the limiter and deadline of its
+// method, authenticated when protect is set and authenticators are
+// configured, and logged once done.
+// Protected calls locate, those failing authentication are audited. f
+// is given a request built from the call metadata, standing for the
+// call wherever the HTTP API expects a request.
+func (n *navigation) call(ctx context.Context, method string, protect bool, f func(context.Context, *http.Request) error) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	r := &http.Request{
+		Method: http.MethodPost,
+		URL:    &url.URL{Path: "/" + dnspb.Navigation_ServiceDesc.ServiceName + "/" + method},
+		Header: make(http.Header),
+	}
+	for k, vs := range md {
+		r.Header[textproto.CanonicalMIMEHeaderKey(k)] = vs
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		r.RemoteAddr = p.Addr.String()
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			r.TLS = &info.State
+		}
+	}
+
+	v := web.Values{TraceID: uuid.NewV4().String(), Now: time.Now()}
+	if id := r.Header.Get(web.HeaderRequestID); id != "" && len(id) <= 128 {
+		v.TraceID = id
+	}
+	ctx = context.WithValue(ctx, web.KeyValues, &v)
+	grpc.SetHeader(ctx, metadata.Pairs(web.HeaderRequestID, v.TraceID))
+
+	var err error
+	failed := true
+	if lim, ok := n.limiters[method]; ok {
+		release, lerr := lim.Acquire(ctx)
+		if lerr != nil {
+			err = web.NewRequestError(lerr, http.StatusServiceUnavailable)
+		} else {
+			// released when the call panics too, as a failure
+			defer func() { release(failed) }()
+		}
+	}
+	if d := n.timeouts[method]; d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	if err == nil && protect && len(n.authenticators) > 0 {
+		var p *auth.Principal
+		if p, err = auth.Authenticate(r, n.authenticators...); err != nil {
+			err = n.loc.recordError(ctx, r, nil, web.NewRequestError(err, http.StatusUnauthorized))
+		} else {
+			ctx = auth.NewContext(ctx, p)
+		}
+	}
+	if err == nil {
+		err = f(ctx, r.WithContext(ctx))
+	}
+	err = grpcError(err)
+
+	code := status.Code(err)
+	l := middleware.LevelInfo
+	switch code {
+	case codes.OK:
+	case codes.InvalidArgument, codes.Unauthenticated, codes.PermissionDenied, codes.NotFound, codes.Canceled:
+		l = middleware.LevelWarn
+	default:
+		l = middleware.LevelError
+	}
+	failed = l == middleware.LevelError
+	if n.level.Enabled(l) {
+		n.log.Printf("%s : (%s) : GRPC %s -> %s (%s)", v.TraceID, code, r.URL.Path, r.RemoteAddr, time.Since(v.Now))
+	}
+	return err
+}
+
+// grpcError converts the errors of the App to gRPC status errors. The
+// fields failing validation are carried in a google.rpc.BadRequest
+// detail, and unexpected errors are not disclosed.
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch err {
+	case context.DeadlineExceeded, context.Canceled:
+		return status.FromContextError(err).Err()
+	}
+
+	webErr, ok := err.(*web.Error)
+	if !ok {
+		return status.Error(codes.Internal, http.StatusText(http.StatusInternalServerError))
+	}
+	code := codes.Unknown
+	switch webErr.Status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	}
+	st := status.New(code, webErr.Error())
+	if len(webErr.Fields) == 0 {
+		return st.Err()
+	}
+
+	var br errdetails.BadRequest
+	for _, f := range webErr.Fields {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       f.Field,
+			Description: f.Error,
+		})
+	}
+	if detailed, err := st.WithDetails(&br); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// healthServer answers gRPC health checks with the readiness checks of
+// the App, for the whole server and for the Navigation service. Watches
+// share one run of the checks every HealthWatchInterval, and end once
+// the App drains so that they do not hold up its shutdown.
+type healthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+
+	checker  *health.Checker
+	draining <-chan struct{}
+
+	mu       sync.Mutex
+	watchers int
+	stop     chan struct{}
+	serving  grpc_health_v1.HealthCheckResponse_ServingStatus
+	changed  chan struct{}
+}
+
+func (h *healthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	if !h.knows(req.Service) {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.Service)
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: h.status(ctx)}, nil
+}
+
+// Watch sends the serving status whenever it changes. Once the App
+// drains it sends NOT_SERVING and ends the watch as Unavailable.
+func (h *healthServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	ctx := stream.Context()
+	known := h.knows(req.Service)
+	if known {
+		h.join()
+		defer h.leave()
+	}
+
+	last := grpc_health_v1.HealthCheckResponse_UNKNOWN
+	for {
+		// as specified, unknown services are watched as such rather than failing
+		s, changed := grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN, chan struct{}(nil)
+		if known {
+			s, changed = h.current()
+		}
+		if s != last {
+			if err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: s}); err != nil {
+				return err
+			}
+			last = s
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-h.draining:
+			if known && last != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+				stream.Send(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_NOT_SERVING})
+			}
+			return status.Error(codes.Unavailable, "the server is shutting down")
+		case <-changed:
+		}
+	}
+}
+
+// join adds a watcher, the first one starts running the checks
+func (h *healthServer) join() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.watchers++; h.watchers > 1 {
+		return
+	}
+	if h.changed == nil {
+		h.changed = make(chan struct{})
+	}
+	// the status of an earlier run is stale, watchers wait for the first check
+	h.serving = grpc_health_v1.HealthCheckResponse_UNKNOWN
+	h.stop = make(chan struct{})
+	go h.poll(h.stop)
+}
+
+// leave removes a watcher, the last one stops running the checks
+func (h *healthServer) leave() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.watchers--; h.watchers == 0 {
+		close(h.stop)
+		h.stop = nil
+	}
+}
+
+// current returns the serving status found by the last checks, and a
+// channel closed once it changes
+func (h *healthServer) current() (grpc_health_v1.HealthCheckResponse_ServingStatus, chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.serving, h.changed
+}
+
+// poll runs the checks every HealthWatchInterval until stop is closed,
+// telling the watchers when the status changes
+func (h *healthServer) poll(stop chan struct{}) {
+	ticker := time.NewTicker(HealthWatchInterval)
+	defer ticker.Stop()
+	for {
+		s := h.status(context.Background())
+		h.mu.Lock()
+		if h.stop == stop && s != h.serving {
+			h.serving = s
+			close(h.changed)
+			h.changed = make(chan struct{})
+		}
+		h.mu.Unlock()
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// knows reports whether service is checked, the empty name being the
+// whole server
+func (h *healthServer) knows(service string) bool {
+	return service == "" || service == dnspb.Navigation_ServiceDesc.ServiceName
+}
+
+func (h *healthServer) status(ctx context.Context) grpc_health_v1.HealthCheckResponse_ServingStatus {
+	report := h.checker.Check(ctx)
+	if !report.Ready() {
+		return grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+	return grpc_health_v1.HealthCheckResponse_SERVING
+}
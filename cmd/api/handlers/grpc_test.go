@@ -0,0 +1,321 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/timolinn/dns/cmd/api/handlers"
+	"github.com/timolinn/dns/middleware"
+	"github.com/timolinn/dns/pkg/auth"
+	"github.com/timolinn/dns/pkg/dnspb"
+	"github.com/timolinn/dns/pkg/health"
+	"github.com/timolinn/dns/pkg/web"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dialGRPC serves the gRPC services registered along an App built with
+// opts, and dials them
+func dialGRPC(t *testing.T, opts ...handlers.Option) *grpc.ClientConn {
+	_, conn := serveGRPC(t, opts...)
+	return conn
+}
+
+// serveGRPC is dialGRPC also returning the App
+func serveGRPC(t *testing.T, opts ...handlers.Option) (*web.App, *grpc.ClientConn) {
+	shutdown := make(chan os.Signal, 1)
+	logger := log.New(os.Stdout, "TEST : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+
+	srv := grpc.NewServer()
+	app := handlers.Register(shutdown, logger, append(opts, handlers.WithGRPC(srv, 2))...)
+	ln := bufconn.Listen(1 << 20)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	dial := func(ctx context.Context, _ string) (net.Conn, error) { return ln.Dial() }
+	conn, err := grpc.Dial("bufconn", grpc.WithContextDialer(dial), grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return app, conn
+}
+
+var coords = &dnspb.Coordinates{X: 123.12, Y: 456.56, Z: 789.89, Vel: 20}
+
+func TestGRPCLocate(t *testing.T) {
+	nav := dnspb.NewNavigationClient(dialGRPC(t))
+	ctx := context.Background()
+
+	t.Run("should locate drones and ships", func(t *testing.T) {
+		for _, sys := range []dnspb.System{dnspb.System_SYSTEM_DRONE, dnspb.System_SYSTEM_SHIP} {
+			var header metadata.MD
+			resp, err := nav.Locate(ctx, &dnspb.LocateRequest{System: sys, Coordinates: coords}, grpc.Header(&header))
+			if err != nil {
+				t.Fatalf("expected nil-err got %s", err)
+			}
+			if resp.Location != 1389.57 || resp.Sector != 1 {
+				t.Errorf("Should locate 1389.57 in sector 1, got %v in %v", resp.Location, resp.Sector)
+			}
+			if len(header.Get("x-request-id")) != 1 {
+				t.Error("Should send the request ID")
+			}
+		}
+	})
+
+	t.Run("should report invalid fields in the status details", func(t *testing.T) {
+		_, err := nav.Locate(ctx, &dnspb.LocateRequest{
+			System:      dnspb.System_SYSTEM_DRONE,
+			Coordinates: &dnspb.Coordinates{X: 1, Y: 2, Z: 3},
+		})
+		st := status.Convert(err)
+		if st.Code() != codes.InvalidArgument {
+			t.Fatalf("Should receive code %s, got %s", codes.InvalidArgument, st.Code())
+		}
+		if got := violations(st); len(got) != 1 || got["coordinates.vel"] == "" {
+			t.Errorf("Should report coordinates.vel, got %v", got)
+		}
+	})
+
+	t.Run("should require a system type", func(t *testing.T) {
+		_, err := nav.Locate(ctx, &dnspb.LocateRequest{Coordinates: coords})
+		st := status.Convert(err)
+		if st.Code() != codes.InvalidArgument || violations(st)["system"] == "" {
+			t.Errorf("Should report the system field, got %v", st)
+		}
+	})
+
+	t.Run("should locate batches", func(t *testing.T) {
+		resp, err := nav.BatchLocate(ctx, &dnspb.BatchLocateRequest{Requests: []*dnspb.LocateRequest{
+			{System: dnspb.System_SYSTEM_SHIP, Coordinates: coords},
+			{System: dnspb.System_SYSTEM_DRONE},
+		}})
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if len(resp.Results) != 2 {
+			t.Fatalf("Should receive 2 results, got %d", len(resp.Results))
+		}
+		if r := resp.Results[0]; r.Error != nil || r.Response.GetLocation() != 1389.57 {
+			t.Errorf("Should locate the first request, got %v", r)
+		}
+		if r := resp.Results[1]; r.Response != nil || codes.Code(r.Error.GetCode()) != codes.InvalidArgument {
+			t.Errorf("Should reject the second request, got %v", r)
+		}
+	})
+
+	t.Run("should reject batches over the limit", func(t *testing.T) {
+		req := &dnspb.LocateRequest{System: dnspb.System_SYSTEM_DRONE, Coordinates: coords}
+		_, err := nav.BatchLocate(ctx, &dnspb.BatchLocateRequest{Requests: []*dnspb.LocateRequest{req, req, req}})
+		if code := status.Code(err); code != codes.InvalidArgument {
+			t.Errorf("Should receive code %s, got %s", codes.InvalidArgument, code)
+		}
+	})
+
+	t.Run("should report the sector", func(t *testing.T) {
+		sector, err := nav.GetSector(ctx, &dnspb.GetSectorRequest{})
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if sector.Id != 1 || len(sector.Systems) != 2 {
+			t.Errorf("Should serve drones and ships in sector 1, got %v", sector)
+		}
+	})
+}
+
+func TestGRPCAuth(t *testing.T) {
+	store := auth.NewKeyStore()
+	_, droneKey, err := store.Create("alpha", []string{"drone"}, false)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	nav := dnspb.NewNavigationClient(dialGRPC(t, handlers.WithKeyStore(store)))
+	withKey := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", droneKey)
+
+	cases := []struct {
+		name   string
+		ctx    context.Context
+		system dnspb.System
+		code   codes.Code
+	}{
+		{"should reject calls without a key", context.Background(), dnspb.System_SYSTEM_DRONE, codes.Unauthenticated},
+		{"should accept a key for its system", withKey, dnspb.System_SYSTEM_DRONE, codes.OK},
+		{"should bind the system of the key", withKey, dnspb.System_SYSTEM_UNSPECIFIED, codes.OK},
+		{"should reject a key for another system", withKey, dnspb.System_SYSTEM_SHIP, codes.PermissionDenied},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := nav.Locate(test.ctx, &dnspb.LocateRequest{System: test.system, Coordinates: coords})
+			if code := status.Code(err); code != test.code {
+				t.Errorf("Should receive code %s, got %s", test.code, code)
+			}
+		})
+	}
+
+	t.Run("should keep the sector public", func(t *testing.T) {
+		if _, err := nav.GetSector(context.Background(), &dnspb.GetSectorRequest{}); err != nil {
+			t.Errorf("expected nil-err got %s", err)
+		}
+	})
+}
+
+func TestGRPCHealth(t *testing.T) {
+	checker := health.New()
+	client := grpc_health_v1.NewHealthClient(dialGRPC(t, handlers.WithHealth(checker)))
+	ctx := context.Background()
+
+	check := func(service string) (grpc_health_v1.HealthCheckResponse_ServingStatus, codes.Code) {
+		resp, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+		return resp.GetStatus(), status.Code(err)
+	}
+
+	if s, code := check(""); s != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Errorf("Should be serving, got %s %s", s, code)
+	}
+	if _, code := check("unknown"); code != codes.NotFound {
+		t.Errorf("Should receive code %s, got %s", codes.NotFound, code)
+	}
+
+	checker.Drain()
+	if s, _ := check("dns.v1.Navigation"); s != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Should not be serving once draining, got %s", s)
+	}
+}
+
+func TestGRPCHealthWatch(t *testing.T) {
+	interval := handlers.HealthWatchInterval
+	handlers.HealthWatchInterval = 10 * time.Millisecond
+	defer func() { handlers.HealthWatchInterval = interval }()
+
+	var checks int32
+	checker := health.New()
+	checker.Register("counted", func(context.Context) error {
+		atomic.AddInt32(&checks, 1)
+		return nil
+	})
+	app, conn := serveGRPC(t, handlers.WithHealth(checker))
+	client := grpc_health_v1.NewHealthClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var watches []grpc_health_v1.Health_WatchClient
+	for _, service := range []string{"", "dns.v1.Navigation", "unknown"} {
+		w, err := client.Watch(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		watches = append(watches, w)
+	}
+	recv := func(w grpc_health_v1.Health_WatchClient) grpc_health_v1.HealthCheckResponse_ServingStatus {
+		resp, err := w.Recv()
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		return resp.Status
+	}
+
+	t.Run("should send the serving status", func(t *testing.T) {
+		for _, w := range watches[:2] {
+			if s := recv(w); s != grpc_health_v1.HealthCheckResponse_SERVING {
+				t.Errorf("Should be serving, got %s", s)
+			}
+		}
+		if s := recv(watches[2]); s != grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN {
+			t.Errorf("Should be an unknown service, got %s", s)
+		}
+	})
+
+	t.Run("should share the checks between watches", func(t *testing.T) {
+		before := atomic.LoadInt32(&checks)
+		time.Sleep(10 * handlers.HealthWatchInterval)
+		// one run per interval, give or take a tick
+		if got := atomic.LoadInt32(&checks) - before; got > 12 {
+			t.Errorf("Should run the checks about 10 times, got %d", got)
+		}
+	})
+
+	t.Run("should send changes", func(t *testing.T) {
+		checker.Drain()
+		for _, w := range watches[:2] {
+			if s := recv(w); s != grpc_health_v1.HealthCheckResponse_NOT_SERVING {
+				t.Errorf("Should not be serving, got %s", s)
+			}
+		}
+	})
+
+	t.Run("should end once the App drains", func(t *testing.T) {
+		app.Drain()
+		for _, w := range watches {
+			if _, err := w.Recv(); status.Code(err) != codes.Unavailable {
+				t.Errorf("Should receive code %s, got %v", codes.Unavailable, err)
+			}
+		}
+	})
+}
+
+func TestGRPCRoutes(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should honor the deadline of a method", func(t *testing.T) {
+		perMethod := map[string]time.Duration{"/dns.v1.Navigation/Locate": time.Nanosecond}
+		nav := dnspb.NewNavigationClient(dialGRPC(t, handlers.WithTimeouts(0, perMethod)))
+
+		_, err := nav.Locate(ctx, &dnspb.LocateRequest{System: dnspb.System_SYSTEM_DRONE, Coordinates: coords})
+		if code := status.Code(err); code != codes.DeadlineExceeded {
+			t.Errorf("Should receive code %s, got %v", codes.DeadlineExceeded, err)
+		}
+		if _, err := nav.GetSector(ctx, &dnspb.GetSectorRequest{}); err != nil {
+			t.Errorf("expected nil-err got %s", err)
+		}
+	})
+
+	t.Run("should limit every method", func(t *testing.T) {
+		app, conn := serveGRPC(t, handlers.WithLimits(middleware.DefaultLimiterConfig))
+		nav := dnspb.NewNavigationClient(conn)
+		if _, err := nav.Locate(ctx, &dnspb.LocateRequest{System: dnspb.System_SYSTEM_DRONE, Coordinates: coords}); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/admin/limits", nil))
+		var stats []middleware.LimiterStats
+		if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		names := make(map[string]bool)
+		for _, s := range stats {
+			names[s.Name] = true
+		}
+		for _, method := range []string{"Locate", "BatchLocate", "GetSector"} {
+			if name := "GRPC /dns.v1.Navigation/" + method; !names[name] {
+				t.Errorf("Should limit %s, got %v", name, names)
+			}
+		}
+	})
+}
+
+// violations returns the field violations of the BadRequest detail of st
+func violations(st *status.Status) map[string]string {
+	fields := make(map[string]string)
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.FieldViolations {
+				fields[v.Field] = v.Description
+			}
+		}
+	}
+	return fields
+}
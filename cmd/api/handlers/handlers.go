@@ -3,20 +3,342 @@
 package handlers
 
 import (
+	"expvar"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/timolinn/dns/middleware"
+	"github.com/timolinn/dns/pkg/audit"
+	"github.com/timolinn/dns/pkg/auth"
+	"github.com/timolinn/dns/pkg/capture"
+	"github.com/timolinn/dns/pkg/dnspb"
+	"github.com/timolinn/dns/pkg/health"
 	"github.com/timolinn/dns/pkg/web"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
 )
 
+// navcache publishes the navigator cache statistics under /debug/vars
+var navcache = expvar.NewMap("navigator_cache")
+
+// options holds the optional dependencies of the web App
+type options struct {
+	keys           *auth.KeyStore
+	authenticators []auth.Authenticator
+	override       bool
+	cors           web.Middleware
+	compress       web.Middleware
+	timeout        time.Duration
+	timeouts       map[string]time.Duration
+	limits         *middleware.LimiterConfig
+	idempotency    web.Middleware
+	cacheSize      int
+	cacheTTL       time.Duration
+	accessLog      web.Middleware
+	audit          *audit.Log
+	record         web.Middleware
+	systems        []System
+	live           *Live
+	reload         func() error
+	health         *health.Checker
+	grpc           *grpc.Server
+	maxBatch       int
+}
+
+// Option configures optional behaviour of the App built by Register
+type Option func(*options)
+
+// WithKeyStore accepts API keys from the store and mounts
+// the key administration endpoints.
+func WithKeyStore(keys *auth.KeyStore) Option {
+	return func(o *options) {
+		o.keys = keys
+		o.authenticators = append(o.authenticators, keys)
+	}
+}
+
+// WithJWT accepts bearer tokens checked by the verifier
+func WithJWT(v *auth.JWTVerifier) Option {
+	return func(o *options) {
+		o.authenticators = append(o.authenticators, v)
+	}
+}
+
+// WithClientCerts identifies drones and ships by their verified
+// TLS client certificate
+func WithClientCerts() Option {
+	return func(o *options) {
+		o.authenticators = append(o.authenticators, &auth.CertAuthenticator{
+			Systems: []string{string(Drone), Ship},
+		})
+	}
+}
+
+// WithSystemOverride lets the system type bound to the caller's identity
+// replace a mismatching X-System-Type header instead of rejecting it.
+func WithSystemOverride() Option {
+	return func(o *options) {
+		o.override = true
+	}
+}
+
+// WithCORS lets browsers on the configured origins call DNS
+func WithCORS(cfg middleware.CORSConfig) Option {
+	return func(o *options) {
+		o.cors = middleware.CORS(cfg)
+	}
+}
+
+// WithCompression compresses responses of at least minSize bytes
+// for clients that accept it
+func WithCompression(minSize int, encoders ...middleware.Encoder) Option {
+	return func(o *options) {
+		o.compress = middleware.Compress(minSize, encoders...)
+	}
+}
+
+// WithTimeouts puts a deadline on every request, routes listed
+// in perRoute by path get their own deadline instead.
+func WithTimeouts(fallback time.Duration, perRoute map[string]time.Duration) Option {
+	return func(o *options) {
+		o.timeout = fallback
+		o.timeouts = perRoute
+	}
+}
+
+// WithLimits sheds load with an adaptive concurrency limiter,
+// every route gets its own limiter and queue.
+func WithLimits(cfg middleware.LimiterConfig) Option {
+	return func(o *options) {
+		o.limits = &cfg
+	}
+}
+
+// WithIdempotency replays the stored response to POST requests repeating
+// an Idempotency-Key, for ttl.
+func WithIdempotency(store middleware.IdempotencyStore, ttl time.Duration) Option {
+	return func(o *options) {
+		o.idempotency = middleware.Idempotency(store, ttl)
+	}
+}
+
+// WithNavigatorCache memoizes up to size navigation results for ttl
+func WithNavigatorCache(size int, ttl time.Duration) Option {
+	return func(o *options) {
+		o.cacheSize, o.cacheTTL = size, ttl
+	}
+}
+
+// WithAccessLog writes an access log line per request to out in the
+// common, combined or json format
+func WithAccessLog(out io.Writer, format string) Option {
+	return func(o *options) {
+		o.accessLog = middleware.AccessLog(out, format)
+	}
+}
+
+// WithAudit records every locate request and its result in the
+// audit log and mounts the audit query endpoint
+func WithAudit(log *audit.Log) Option {
+	return func(o *options) {
+		o.audit = log
+	}
+}
+
+// WithRecording captures every request and response to out for the
+// replay tool
+func WithRecording(out io.Writer) Option {
+	return func(o *options) {
+		o.record = middleware.Record(capture.NewWriter(out))
+	}
+}
+
+// WithSystems only serves the given system types, others are
+// rejected as unknown. It is ignored when WithLive is given.
+func WithSystems(systems ...System) Option {
+	return func(o *options) {
+		o.systems = systems
+	}
+}
+
+// WithLive serves the sector, system types and log level held by live,
+// and lets it reconfigure the route limiters, so that they can change
+// while the App serves.
+func WithLive(live *Live) Option {
+	return func(o *options) {
+		o.live = live
+	}
+}
+
+// WithReload mounts an endpoint reloading the configuration with
+// reload, which must leave the configuration untouched when it fails.
+func WithReload(reload func() error) Option {
+	return func(o *options) {
+		o.reload = reload
+	}
+}
+
+// WithHealth answers readiness probes with the outcome of the checks
+// registered on checker
+func WithHealth(checker *health.Checker) Option {
+	return func(o *options) {
+		o.health = checker
+	}
+}
+
+// WithGRPC registers the gRPC Navigation and health services on s,
+// solving, authenticating and auditing with the App. Batches may hold
+// up to maxBatch requests.
+func WithGRPC(s *grpc.Server, maxBatch int) Option {
+	return func(o *options) {
+		o.grpc, o.maxBatch = s, maxBatch
+	}
+}
+
 // Register register request handlers and middlewares
-func Register(shutdown chan os.Signal, log *log.Logger) http.Handler {
-	app := web.NewApp(shutdown, middleware.Logger(log))
+func Register(shutdown chan os.Signal, log *log.Logger, opts ...Option) *web.App {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	live := o.live
+	if live == nil {
+		live = NewLive(Settings{Sector: SectorID, Systems: o.systems})
+	}
+
+	idempotency := live.gate("idempotency", o.idempotency)
+	app := web.NewApp(shutdown,
+		middleware.Logger(log, &live.level),
+		live.gate("access_log", o.accessLog),
+		o.cors,
+		live.gate("compression", o.compress),
+		live.gate("recording", o.record),
+	)
+
+	// protect authenticates requests, runs authed once the caller is
+	// known, binds them to the caller's system type and enforces scopes.
+	// Authentication is only required once an authenticator is configured
+	protect := func(authed []web.Middleware, scopes ...string) []web.Middleware {
+		if len(o.authenticators) == 0 || len(scopes) == 0 {
+			return nil
+		}
+		mw := append([]web.Middleware{middleware.Authenticate(o.authenticators...)}, authed...)
+		return append(mw, middleware.BindSystem(o.override), middleware.RequireScopes(scopes...))
+	}
+
+	// mountWith mounts a handler behind pre, its route limiter and
+	// deadline, requiring the given scopes, with authed run once the
+	// caller is authenticated. Routes without scopes are public and POST
+	// routes honor idempotency keys
+	var lim limits
+	mountWith := func(pre, authed []web.Middleware, verb, path string, h web.Handler, scopes ...string) {
+		mw := append([]web.Middleware(nil), pre...)
+		if o.limits != nil {
+			l := middleware.NewLimiter(verb+" "+path, *o.limits)
+			lim.limiters = append(lim.limiters, l)
+			live.addLimiter(l)
+			mw = append(mw, l.Middleware())
+		}
+		d, ok := o.timeouts[path]
+		if !ok {
+			d = o.timeout
+		}
+		if d > 0 {
+			mw = append(mw, middleware.Timeout(d))
+		}
+		mw = append(mw, protect(authed, scopes...)...)
+		if verb == http.MethodPost && idempotency != nil {
+			mw = append(mw, idempotency)
+		}
+		app.MountHandler(verb, path, h, mw...)
+	}
+	mount := func(verb, path string, h web.Handler, scopes ...string) {
+		mountWith(nil, nil, verb, path, h, scopes...)
+	}
+
+	var nav Navigator = &liveNavigator{live: live}
+	if o.cacheSize > 0 {
+		cache := NewCachedNavigator(nav, o.cacheSize, o.cacheTTL)
+		navcache.Set("stats", expvar.Func(func() interface{} { return cache.Stats() }))
+		nav = &toggledNavigator{Navigator: cache, on: live.feature("cache"), fallback: nav}
+
+		c := caches{nav: cache}
+		mount(http.MethodGet, "/v1/admin/cache", c.stats, "admin:cache")
+		mount(http.MethodDelete, "/v1/admin/cache", c.purge, "admin:cache")
+	}
+	loc := location{nav: nav, audit: o.audit, live: live, log: log}
+
+	mount(http.MethodGet, "/", home(nav))
+
+	p := probes{health: o.health}
+	if p.health == nil {
+		p.health = health.New()
+	}
+	// probes are answered without a limiter or deadline, so that an
+	// overloaded server is not taken for a dead one and restarted
+	app.MountHandler(http.MethodGet, "/healthz", p.live)
+	app.MountHandler(http.MethodGet, "/readyz", p.ready)
+	app.MountHandler(http.MethodGet, "/version", version)
+	// requests rejected before reaching locate are audited too, with
+	// their principal once authenticated
+	mountWith([]web.Middleware{loc.audited}, []web.Middleware{loc.identified}, http.MethodPost, "/v1/locate", loc.locate, "locate:{system}")
+
+	if o.keys != nil {
+		k := keys{store: o.keys}
+		mount(http.MethodGet, "/v1/admin/keys", k.list, "admin:keys")
+		mount(http.MethodPost, "/v1/admin/keys", k.create, "admin:keys")
+		mount(http.MethodPost, "/v1/admin/keys/{id}/rotate", k.rotate, "admin:keys")
+		mount(http.MethodDelete, "/v1/admin/keys/{id}", k.revoke, "admin:keys")
+	}
+
+	if o.audit != nil {
+		a := audits{log: o.audit}
+		mount(http.MethodGet, "/v1/admin/audit", a.query, "admin:audit")
+	}
+
+	if o.limits != nil {
+		mount(http.MethodGet, "/v1/admin/limits", lim.stats, "admin:limits")
+	}
+
+	if o.reload != nil {
+		rl := reloads{live: live, reload: o.reload}
+		mount(http.MethodPost, "/v1/admin/reload", rl.reloadConfig, "admin:config")
+	}
 
-	app.MountHandler(http.MethodGet, "/", home)
-	app.MountHandler(http.MethodPost, "/v1/locate", locate)
+	if o.grpc != nil {
+		n := &navigation{
+			loc:            &loc,
+			authenticators: o.authenticators,
+			override:       o.override,
+			timeouts:       make(map[string]time.Duration),
+			limiters:       make(map[string]*middleware.Limiter),
+			maxBatch:       o.maxBatch,
+			log:            log,
+			level:          &live.level,
+		}
+		// methods get their limiter and deadline like routes, the
+		// deadline of a method being set by its full name
+		for _, m := range dnspb.Navigation_ServiceDesc.Methods {
+			path := "/" + dnspb.Navigation_ServiceDesc.ServiceName + "/" + m.MethodName
+			if o.limits != nil {
+				l := middleware.NewLimiter("GRPC "+path, *o.limits)
+				lim.limiters = append(lim.limiters, l)
+				live.addLimiter(l)
+				n.limiters[m.MethodName] = l
+			}
+			d, ok := o.timeouts[path]
+			if !ok {
+				d = o.timeout
+			}
+			n.timeouts[m.MethodName] = d
+		}
+		dnspb.RegisterNavigationServer(o.grpc, n)
+		grpc_health_v1.RegisterHealthServer(o.grpc, &healthServer{checker: p.health, draining: app.Draining()})
+	}
 
 	return app
 }
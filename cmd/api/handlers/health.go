@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/timolinn/dns/pkg/health"
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// probes answers liveness and readiness probes
+type probes struct {
+	health *health.Checker
+}
+
+// live reports that the process is up, even while draining
+func (p *probes) live(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return web.Respond(ctx, w, map[string]string{"status": health.StatusOK}, http.StatusOK)
+}
+
+// ready reports the outcome of every dependency check, failing while
+// a check fails or the server shuts down
+func (p *probes) ready(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	report := p.health.Check(ctx)
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	return web.Respond(ctx, w, report, status)
+}
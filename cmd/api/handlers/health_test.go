@@ -0,0 +1,105 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timolinn/dns/cmd/api/handlers"
+	"github.com/timolinn/dns/middleware"
+	"github.com/timolinn/dns/pkg/health"
+)
+
+func TestProbes(t *testing.T) {
+	shutdown := make(chan os.Signal, 1)
+	logger := log.New(os.Stdout, "TEST : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+
+	checker := health.New()
+	app := handlers.Register(shutdown, logger, handlers.WithHealth(checker))
+
+	probe := func(path string) int {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	t.Run("should be live and ready", func(t *testing.T) {
+		if code := probe("/healthz"); code != http.StatusOK {
+			t.Errorf("Should receive status code %d, got %d", http.StatusOK, code)
+		}
+		if code := probe("/readyz"); code != http.StatusOK {
+			t.Errorf("Should receive status code %d, got %d", http.StatusOK, code)
+		}
+	})
+
+	t.Run("should stay live but not ready while draining", func(t *testing.T) {
+		checker.Drain()
+		if code := probe("/healthz"); code != http.StatusOK {
+			t.Errorf("Should receive status code %d, got %d", http.StatusOK, code)
+		}
+		if code := probe("/readyz"); code != http.StatusServiceUnavailable {
+			t.Errorf("Should receive status code %d, got %d", http.StatusServiceUnavailable, code)
+		}
+	})
+}
+
+func TestProbesUnderLoad(t *testing.T) {
+	shutdown := make(chan os.Signal, 1)
+	logger := log.New(os.Stdout, "TEST : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+
+	limits := middleware.DefaultLimiterConfig
+	limits.InitialLimit, limits.MinLimit, limits.MaxLimit, limits.QueueSize = 1, 1, 1, 0
+	app := handlers.Register(shutdown, logger, handlers.WithLimits(limits), handlers.WithTimeouts(time.Minute, nil))
+
+	// a locate request whose body never ends holds the only slot
+	body, sending := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/locate", body))
+	}()
+	defer func() {
+		sending.Close()
+		<-done
+	}()
+
+	stats := func() map[string]middleware.LimiterStats {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/admin/limits", nil))
+		var list []middleware.LimiterStats
+		if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		byName := make(map[string]middleware.LimiterStats)
+		for _, s := range list {
+			byName[s.Name] = s
+		}
+		return byName
+	}
+	for stats()["POST /v1/locate"].InFlight == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/locate", strings.NewReader(`{}`)))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Should shed locate requests, got status code %d", w.Code)
+	}
+
+	for _, path := range []string{"/healthz", "/readyz", "/version"} {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: Should receive status code %d, got %d", path, http.StatusOK, w.Code)
+		}
+		if _, ok := stats()["GET "+path]; ok {
+			t.Errorf("%s: Should not be limited", path)
+		}
+	}
+}
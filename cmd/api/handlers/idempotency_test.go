@@ -0,0 +1,121 @@
+package handlers_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/timolinn/dns/cmd/api/handlers"
+	"github.com/timolinn/dns/middleware"
+	"github.com/timolinn/dns/pkg/web"
+)
+
+func TestIdempotency(t *testing.T) {
+	var payload = []byte(`{"x":"123.12","z":"789.89","y":"456.56", "vel":"20.0"}`)
+	var otherPayload = []byte(`{"x":"1.0","z":"2.0","y":"3.0", "vel":"4.0"}`)
+
+	shutdown := make(chan os.Signal, 1)
+	logger := log.New(os.Stdout, "TEST : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+
+	store := middleware.NewMemoryIdempotencyStore(100, time.Minute)
+	app := handlers.Register(shutdown, logger, handlers.WithIdempotency(store, time.Minute))
+
+	locate := func(key string, body []byte) *http.Response {
+		r := httptest.NewRequest(http.MethodPost, "/v1/locate", bytes.NewReader(body))
+		r.Header.Set("X-System-Type", "drone")
+		r.Header.Set(middleware.HeaderIdempotencyKey, key)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	first := locate("retry-1", payload)
+	firstBody, _ := ioutil.ReadAll(first.Body)
+	if first.StatusCode != http.StatusOK {
+		t.Fatalf("Should receive status code %d, got %d", http.StatusOK, first.StatusCode)
+	}
+
+	t.Run("should replay the first response for repeated keys", func(t *testing.T) {
+		result := locate("retry-1", payload)
+		body, _ := ioutil.ReadAll(result.Body)
+		if result.StatusCode != http.StatusOK {
+			t.Errorf("Should receive status code %d, got %d", http.StatusOK, result.StatusCode)
+		}
+		if result.Header.Get("Idempotent-Replayed") != "true" {
+			t.Errorf("replayed responses should be marked")
+		}
+		if !bytes.Equal(body, firstBody) {
+			t.Errorf("want body %s, got %s", firstBody, body)
+		}
+	})
+
+	t.Run("should keep the request ID of the repeated request", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/v1/locate", bytes.NewReader(payload))
+		r.Header.Set("X-System-Type", "drone")
+		r.Header.Set(middleware.HeaderIdempotencyKey, "retry-1")
+		r.Header.Set(web.HeaderRequestID, "retry-of-retry-1")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		if w.Header().Get("Idempotent-Replayed") != "true" {
+			t.Fatalf("replayed responses should be marked")
+		}
+		if got := w.Header().Values(web.HeaderRequestID); len(got) != 1 || got[0] != "retry-of-retry-1" {
+			t.Errorf("want request ID retry-of-retry-1, got %v", got)
+		}
+		if got := w.Header().Get("Content-Type"); got != first.Header.Get("Content-Type") {
+			t.Errorf("want the Content-Type of the first response, got %q", got)
+		}
+	})
+
+	t.Run("should reject reused keys with a different payload", func(t *testing.T) {
+		result := locate("retry-1", otherPayload)
+		if result.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("Should receive status code %d, got %d", http.StatusUnprocessableEntity, result.StatusCode)
+		}
+	})
+
+	t.Run("should process new keys", func(t *testing.T) {
+		result := locate("retry-2", otherPayload)
+		if result.StatusCode != http.StatusOK {
+			t.Errorf("Should receive status code %d, got %d", http.StatusOK, result.StatusCode)
+		}
+		if result.Header.Get("Idempotent-Replayed") != "" {
+			t.Errorf("new requests should not be marked as replayed")
+		}
+	})
+
+	t.Run("should not fail the server when a replay can not be written", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/v1/locate", bytes.NewReader(payload))
+		r.Header.Set("X-System-Type", "drone")
+		r.Header.Set(middleware.HeaderIdempotencyKey, "retry-1")
+		app.ServeHTTP(brokenWriter{httptest.NewRecorder()}, r)
+		select {
+		case sig := <-shutdown:
+			t.Errorf("Should keep serving, got %v", sig)
+		default:
+		}
+	})
+
+	t.Run("should reject bodies too large to fingerprint", func(t *testing.T) {
+		result := locate("retry-3", bytes.Repeat([]byte(" "), 2<<20))
+		if result.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Errorf("Should receive status code %d, got %d", http.StatusRequestEntityTooLarge, result.StatusCode)
+		}
+	})
+}
+
+// brokenWriter fails every write, like the connection of a client gone
+type brokenWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (brokenWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/timolinn/dns/pkg/auth"
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// NewKey expected request data for issuing an API key
+type NewKey struct {
+	Fleet   string   `json:"fleet" validate:"required"`
+	Systems []string `json:"systems" validate:"dive,oneof=drone ship"`
+	Admin   bool     `json:"admin"`
+}
+
+// IssuedKey is returned when a key is created or rotated, it is
+// the only time the plaintext key is ever shown.
+type IssuedKey struct {
+	auth.Key
+	Secret string `json:"key"`
+}
+
+// keys administers the API keys used by drones and ships
+type keys struct {
+	store *auth.KeyStore
+}
+
+func (k *keys) list(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return web.Respond(ctx, w, k.store.List(), http.StatusOK)
+}
+
+func (k *keys) create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var nk NewKey
+	if err := web.Decode(r, &nk); err != nil {
+		return web.RespondError(ctx, w, err)
+	}
+
+	key, secret, err := k.store.Create(nk.Fleet, nk.Systems, nk.Admin)
+	if err != nil {
+		return web.RespondError(ctx, w, err)
+	}
+	return web.Respond(ctx, w, IssuedKey{key, secret}, http.StatusCreated)
+}
+
+func (k *keys) rotate(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	key, secret, err := k.store.Rotate(web.Params(r)["id"])
+	if err != nil {
+		return web.RespondError(ctx, w, keyError(err))
+	}
+	return web.Respond(ctx, w, IssuedKey{key, secret}, http.StatusOK)
+}
+
+func (k *keys) revoke(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	key, err := k.store.Revoke(web.Params(r)["id"])
+	if err != nil {
+		return web.RespondError(ctx, w, keyError(err))
+	}
+	return web.Respond(ctx, w, key, http.StatusOK)
+}
+
+// keyError maps key store errors to web errors
+func keyError(err error) error {
+	switch err {
+	case auth.ErrKeyNotFound:
+		return web.NewRequestError(err, http.StatusNotFound)
+	case auth.ErrKeyRevoked:
+		return web.NewRequestError(err, http.StatusConflict)
+	}
+	return err
+}
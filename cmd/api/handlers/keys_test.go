@@ -0,0 +1,130 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/timolinn/dns/cmd/api/handlers"
+	"github.com/timolinn/dns/pkg/auth"
+)
+
+func TestAPIKeys(t *testing.T) {
+	var payload = []byte(`{"x":"123.12","z":"789.89","y":"456.56", "vel":"20.0"}`)
+
+	shutdown := make(chan os.Signal, 1)
+	logger := log.New(os.Stdout, "TEST : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+
+	store := auth.NewKeyStore()
+	_, adminKey, err := store.Create("admin", nil, true)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	_, droneKey, err := store.Create("alpha", []string{"drone"}, false)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+
+	app := handlers.Register(shutdown, logger, handlers.WithKeyStore(store))
+
+	locate := func(key, system string) int {
+		r := httptest.NewRequest(http.MethodPost, "/v1/locate", bytes.NewReader(payload))
+		r.Header.Set("X-System-Type", system)
+		if key != "" {
+			r.Header.Set(auth.HeaderAPIKey, key)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		return w.Result().StatusCode
+	}
+
+	admin := func(method, path, key string, body []byte) *http.Response {
+		r := httptest.NewRequest(method, path, bytes.NewReader(body))
+		r.Header.Set(auth.HeaderAPIKey, key)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	cases := []struct {
+		name   string
+		key    string
+		system string
+		status int
+	}{
+		{"should reject requests without a key", "", "drone", http.StatusUnauthorized},
+		{"should reject unknown keys", "dns_0000000000000000_ffff", "drone", http.StatusUnauthorized},
+		{"should accept a key for its system", droneKey, "drone", http.StatusOK},
+		{"should reject a key for another system", droneKey, "ship", http.StatusForbidden},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			if got := locate(test.key, test.system); got != test.status {
+				t.Errorf("Should receive status code %d, got %d", test.status, got)
+			}
+		})
+	}
+
+	t.Run("should only allow admins on admin endpoints", func(t *testing.T) {
+		result := admin(http.MethodGet, "/v1/admin/keys", droneKey, nil)
+		if result.StatusCode != http.StatusForbidden {
+			t.Errorf("Should receive status code %d, got %d", http.StatusForbidden, result.StatusCode)
+		}
+	})
+
+	t.Run("should create, rotate and revoke keys", func(t *testing.T) {
+		result := admin(http.MethodPost, "/v1/admin/keys", adminKey, []byte(`{"fleet":"beta","systems":["ship"]}`))
+		if result.StatusCode != http.StatusCreated {
+			t.Fatalf("Should receive status code %d, got %d", http.StatusCreated, result.StatusCode)
+		}
+		var created handlers.IssuedKey
+		if err := json.NewDecoder(result.Body).Decode(&created); err != nil {
+			t.Fatalf("should be able to unmarshal response")
+		}
+		if created.Hash != "" {
+			t.Errorf("key hash should never be returned")
+		}
+		if got := locate(created.Secret, "ship"); got != http.StatusOK {
+			t.Errorf("created key: want %d, got %d", http.StatusOK, got)
+		}
+
+		result = admin(http.MethodPost, "/v1/admin/keys/"+created.ID+"/rotate", adminKey, nil)
+		if result.StatusCode != http.StatusOK {
+			t.Fatalf("Should receive status code %d, got %d", http.StatusOK, result.StatusCode)
+		}
+		var rotated handlers.IssuedKey
+		if err := json.NewDecoder(result.Body).Decode(&rotated); err != nil {
+			t.Fatalf("should be able to unmarshal response")
+		}
+		if got := locate(created.Secret, "ship"); got != http.StatusUnauthorized {
+			t.Errorf("rotated out key: want %d, got %d", http.StatusUnauthorized, got)
+		}
+		if got := locate(rotated.Secret, "ship"); got != http.StatusOK {
+			t.Errorf("rotated key: want %d, got %d", http.StatusOK, got)
+		}
+
+		result = admin(http.MethodDelete, "/v1/admin/keys/"+created.ID, adminKey, nil)
+		if result.StatusCode != http.StatusOK {
+			t.Fatalf("Should receive status code %d, got %d", http.StatusOK, result.StatusCode)
+		}
+		if got := locate(rotated.Secret, "ship"); got != http.StatusUnauthorized {
+			t.Errorf("revoked key: want %d, got %d", http.StatusUnauthorized, got)
+		}
+
+		result = admin(http.MethodPost, "/v1/admin/keys/"+created.ID+"/rotate", adminKey, nil)
+		if result.StatusCode != http.StatusConflict {
+			t.Errorf("Should receive status code %d, got %d", http.StatusConflict, result.StatusCode)
+		}
+	})
+
+	t.Run("should validate new keys", func(t *testing.T) {
+		result := admin(http.MethodPost, "/v1/admin/keys", adminKey, []byte(`{"systems":["ultradrone"]}`))
+		if result.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("Should receive status code %d, got %d", http.StatusUnprocessableEntity, result.StatusCode)
+		}
+	})
+}
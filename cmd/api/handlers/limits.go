@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/timolinn/dns/middleware"
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// limits reports the state of the concurrency limiter of every route
+type limits struct {
+	limiters []*middleware.Limiter
+}
+
+func (l *limits) stats(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	stats := make([]middleware.LimiterStats, 0, len(l.limiters))
+	for _, lim := range l.limiters {
+		stats = append(stats, lim.Stats())
+	}
+	return web.Respond(ctx, w, stats, http.StatusOK)
+}
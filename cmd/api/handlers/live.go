@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/timolinn/dns/middleware"
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// Settings are the settings of a registered App that may change
+// while it serves.
+type Settings struct {
+	Sector   float64          `json:"sector"`
+	Systems  []System         `json:"systems"`
+	LogLevel middleware.Level `json:"log_level"`
+
+	// Limits replaces the configuration of the route limiters when
+	// the App sheds load, see WithLimits.
+	Limits *middleware.LimiterConfig `json:"-"`
+}
+
+// Live holds the Settings of the App it is registered with, see WithLive.
+// Applying new Settings takes effect on the next request, without
+// restarting the server.
+type Live struct {
+	sector  uint64       // math.Float64bits of the sector, accessed atomically
+	systems atomic.Value // map[System]bool
+	level   middleware.LevelVar
+
+	mu       sync.Mutex
+	settings Settings
+	limiters []*middleware.Limiter
+	features map[string]*int32
+}
+
+// NewLive constructs a Live holding s
+func NewLive(s Settings) *Live {
+	l := &Live{features: make(map[string]*int32)}
+	l.Apply(s)
+	return l
+}
+
+// Apply replaces the settings of the App
+func (l *Live) Apply(s Settings) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	systems := make(map[System]bool)
+	for _, sys := range s.Systems {
+		systems[sys] = true
+	}
+	l.systems.Store(systems)
+	atomic.StoreUint64(&l.sector, math.Float64bits(s.Sector))
+	l.level.Set(s.LogLevel)
+	if s.Limits != nil {
+		for _, lim := range l.limiters {
+			lim.SetConfig(*s.Limits)
+		}
+	}
+	l.settings = s
+}
+
+// Settings returns the current settings
+func (l *Live) Settings() Settings {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.settings
+}
+
+// Sector returns the ID of the sector served
+func (l *Live) Sector() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&l.sector))
+}
+
+// serves reports whether requests of the system type are served,
+// every system type is served when none is listed.
+func (l *Live) serves(system System) bool {
+	systems := l.systems.Load().(map[System]bool)
+	return len(systems) == 0 || systems[system]
+}
+
+// addLimiter lets the limiter be reconfigured by Apply
+func (l *Live) addLimiter(lim *middleware.Limiter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.settings.Limits != nil {
+		lim.SetConfig(*l.settings.Limits)
+	}
+	l.limiters = append(l.limiters, lim)
+}
+
+// SetLogLevel changes the request log level until the next Apply
+func (l *Live) SetLogLevel(level middleware.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level.Set(level)
+	l.settings.LogLevel = level
+}
+
+// Features reports which features of the App are enabled, by name
+func (l *Live) Features() map[string]bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	features := make(map[string]bool, len(l.features))
+	for name, on := range l.features {
+		features[name] = atomic.LoadInt32(on) == 1
+	}
+	return features
+}
+
+// Toggle enables or disables a feature of the App, it reports false
+// when the App has no such feature.
+func (l *Live) Toggle(name string, enabled bool) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	on, ok := l.features[name]
+	if !ok {
+		return false
+	}
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(on, v)
+	return true
+}
+
+// feature registers an enabled feature, returning the flag to check
+func (l *Live) feature(name string) *int32 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	on, ok := l.features[name]
+	if !ok {
+		on = new(int32)
+		*on = 1
+		l.features[name] = on
+	}
+	return on
+}
+
+// gate makes mw a feature that can be toggled, requests skip it
+// while it is disabled.
+func (l *Live) gate(name string, mw web.Middleware) web.Middleware {
+	if mw == nil {
+		return nil
+	}
+	on := l.feature(name)
+	return func(next web.Handler) web.Handler {
+		wrapped := mw(next)
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if atomic.LoadInt32(on) == 1 {
+				return wrapped(ctx, w, r)
+			}
+			return next(ctx, w, r)
+		}
+		return h
+	}
+}
+
+// toggledNavigator solves with next while its feature is enabled,
+// and with fallback otherwise
+type toggledNavigator struct {
+	Navigator
+	on       *int32
+	fallback Navigator
+}
+
+func (tn *toggledNavigator) Solve(ctx context.Context, cv CoordsVelocity, system System) (float64, error) {
+	if atomic.LoadInt32(tn.on) == 1 {
+		return tn.Navigator.Solve(ctx, cv, system)
+	}
+	return tn.fallback.Solve(ctx, cv, system)
+}
+
+// liveNavigator is a SectorNavigator serving the sector held by a Live
+type liveNavigator struct {
+	live *Live
+}
+
+func (ln *liveNavigator) Solve(ctx context.Context, cv CoordsVelocity, system System) (float64, error) {
+	nav := SectorNavigator{SectorID: ln.live.Sector()}
+	return nav.Solve(ctx, cv, system)
+}
+
+func (ln *liveNavigator) Response(data float64, system System) map[string]float64 {
+	nav := SectorNavigator{SectorID: ln.live.Sector()}
+	return nav.Response(data, system)
+}
+
+func (ln *liveNavigator) Sector() float64 {
+	return ln.live.Sector()
+}
+
+// reloads reloads the configuration of the App on demand
+type reloads struct {
+	live   *Live
+	reload func() error
+}
+
+func (rl *reloads) reloadConfig(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	if err := rl.reload(); err != nil {
+		return web.RespondError(ctx, w, web.NewRequestError(err, http.StatusUnprocessableEntity))
+	}
+	return web.Respond(ctx, w, rl.live.Settings(), http.StatusOK)
+}
@@ -0,0 +1,77 @@
+package handlers_test
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/timolinn/dns/cmd/api/handlers"
+	"github.com/timolinn/dns/middleware"
+)
+
+func TestLive(t *testing.T) {
+	shutdown := make(chan os.Signal, 1)
+	logger := log.New(os.Stdout, "TEST : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+
+	locate := func(app http.Handler, system string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/v1/locate", bytes.NewReader([]byte(`{"x":"1","y":"1","z":"1","vel":"1"}`)))
+		r.Header.Set("X-System-Type", system)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("should apply new settings without registering again", func(t *testing.T) {
+		live := handlers.NewLive(handlers.Settings{Sector: 1})
+		app := handlers.Register(shutdown, logger, handlers.WithLive(live), handlers.WithNavigatorCache(10, time.Minute))
+
+		if w := locate(app, "drone"); w.Body.String() != `{"loc":4}` {
+			t.Errorf("want sector 1 result, got %s", w.Body.String())
+		}
+
+		live.Apply(handlers.Settings{Sector: 2, Systems: []handlers.System{handlers.Drone}, LogLevel: middleware.LevelError})
+		if w := locate(app, "drone"); w.Body.String() != `{"loc":8}` {
+			t.Errorf("want sector 2 result, got %s", w.Body.String())
+		}
+		if w := locate(app, "ship"); w.Code != http.StatusBadRequest {
+			t.Errorf("Should receive status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("should reconfigure the route limiters", func(t *testing.T) {
+		limits := middleware.DefaultLimiterConfig
+		live := handlers.NewLive(handlers.Settings{Sector: 1})
+		app := handlers.Register(shutdown, logger, handlers.WithLive(live), handlers.WithLimits(limits))
+
+		limits.InitialLimit, limits.MaxLimit = 3, 3
+		live.Apply(handlers.Settings{Sector: 1, Limits: &limits})
+
+		r := httptest.NewRequest(http.MethodGet, "/v1/admin/limits", nil)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		if !bytes.Contains(w.Body.Bytes(), []byte(`"limit":3`)) {
+			t.Errorf("want limit 3, got %s", w.Body.String())
+		}
+	})
+
+	t.Run("should keep the settings when a reload fails", func(t *testing.T) {
+		live := handlers.NewLive(handlers.Settings{Sector: 1})
+		reload := func() error { return errors.New("navigation.sector: must be positive") }
+		app := handlers.Register(shutdown, logger, handlers.WithLive(live), handlers.WithReload(reload))
+
+		r := httptest.NewRequest(http.MethodPost, "/v1/admin/reload", nil)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Should receive status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
+		}
+		if live.Sector() != 1 {
+			t.Errorf("want sector 1, got %v", live.Sector())
+		}
+	})
+}
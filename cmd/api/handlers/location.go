@@ -2,27 +2,176 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/timolinn/dns/pkg/audit"
+	"github.com/timolinn/dns/pkg/auth"
+	"github.com/timolinn/dns/pkg/buildinfo"
 	"github.com/timolinn/dns/pkg/web"
 )
 
+// location answers drones and ships looking for their databank
+type location struct {
+	nav   Navigator
+	audit *audit.Log
+	live  *Live
+	log   *log.Logger
+}
+
+// auditKey is the context key of the auditState of a request
+type auditKey struct{}
+
+// auditState is claimed by whichever of locate and audited audits a
+// request, and carries the principal identified should audited do it
+type auditState struct {
+	claim     int32
+	mu        sync.Mutex
+	principal *auth.Principal
+}
+
 // Locate calculates complex maths
-func locate(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+func (l *location) locate(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	// start trace span
 
 	data := CoordsVelocity{}
 	err := web.Decode(r, &data)
 	if err != nil {
-		return web.RespondError(ctx, w, err)
+		return l.respondError(ctx, w, r, nil, err)
 	}
 	systemType := System(r.Header.Get("X-System-Type"))
 
-	system := NewSectorNavigator()
-	result, err := system.Solve(data, systemType)
-	if err != nil {
+	result, err := l.solve(ctx, data, systemType)
+	if err == ErrUnknownSystemType {
 		er := &web.Error{Err: err, Status: http.StatusBadRequest}
-		return web.RespondError(ctx, w, er)
+		return l.respondError(ctx, w, r, &data, er)
+	}
+	if err != nil {
+		return l.respondError(ctx, w, r, &data, err)
+	}
+
+	resp := l.nav.Response(result, systemType)
+	if err := l.record(ctx, r, &data, resp, http.StatusOK, nil); err != nil {
+		return web.RespondError(ctx, w, err)
+	}
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+// solve solves the request of a served system type
+func (l *location) solve(ctx context.Context, data CoordsVelocity, system System) (float64, error) {
+	if !l.live.serves(system) {
+		return 0, ErrUnknownSystemType
+	}
+	return l.nav.Solve(ctx, data, system)
+}
+
+// respondError audits a failed request before responding
+func (l *location) respondError(ctx context.Context, w http.ResponseWriter, r *http.Request, in *CoordsVelocity, err error) error {
+	return web.RespondError(ctx, w, l.recordError(ctx, r, in, err))
+}
+
+// recordError audits a failed request, it returns err or the error
+// of the audit log
+func (l *location) recordError(ctx context.Context, r *http.Request, in *CoordsVelocity, err error) error {
+	status := http.StatusInternalServerError
+	if webErr, ok := err.(*web.Error); ok {
+		status = webErr.Status
+	}
+	if aerr := l.record(ctx, r, in, nil, status, err); aerr != nil {
+		return aerr
+	}
+	return err
+}
+
+// audited audits the requests answered before reaching locate, like
+// those rejected by authentication, the route limiter or the deadline,
+// and idempotent replays. The audit log failing is logged, the request
+// was answered by then.
+func (l *location) audited(f web.Handler) web.Handler {
+	if l.audit == nil {
+		return f
+	}
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var state auditState
+		err := f(context.WithValue(ctx, auditKey{}, &state), w, r)
+		if !atomic.CompareAndSwapInt32(&state.claim, 0, 1) {
+			return err
+		}
+		state.mu.Lock()
+		if state.principal != nil {
+			ctx = auth.NewContext(ctx, state.principal)
+		}
+		state.mu.Unlock()
+
+		var status int
+		if v, ok := ctx.Value(web.KeyValues).(*web.Values); ok {
+			status = v.StatusCode
+		}
+		var failure error
+		if status >= http.StatusBadRequest {
+			failure = errors.New(http.StatusText(status))
+		}
+		if aerr := l.record(ctx, r, nil, nil, status, failure); aerr != nil {
+			l.log.Printf("locate : auditing a %d response: %v", status, aerr)
+		}
+		return err
+	}
+}
+
+// identified hands the principal authenticated to audited, for the
+// requests rejected or answered after authentication but before locate
+func (l *location) identified(f web.Handler) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		state, ok := ctx.Value(auditKey{}).(*auditState)
+		if p, found := auth.FromContext(ctx); ok && found {
+			state.mu.Lock()
+			state.principal = p
+			state.mu.Unlock()
+		}
+		return f(ctx, w, r)
+	}
+}
+
+// record writes the request and its outcome to the audit log, if any.
+// Only the first record of a request claimed by audited is written.
+func (l *location) record(ctx context.Context, r *http.Request, in *CoordsVelocity, out map[string]float64, status int, failure error) error {
+	if l.audit == nil {
+		return nil
+	}
+	if state, ok := ctx.Value(auditKey{}).(*auditState); ok && !atomic.CompareAndSwapInt32(&state.claim, 0, 1) {
+		return nil
+	}
+
+	rec := audit.Record{
+		Time:    time.Now().UTC(),
+		Version: buildinfo.Current(),
+		System:  r.Header.Get("X-System-Type"),
+		Sector:  l.nav.Sector(),
+		Status:  status,
+	}
+	if v, ok := ctx.Value(web.KeyValues).(*web.Values); ok {
+		rec.RequestID = v.TraceID
+	}
+	if p, ok := auth.FromContext(ctx); ok {
+		rec.Principal, rec.Fleet = p.ID, p.Fleet
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		rec.Remote = host
+	}
+	if in != nil {
+		rec.Input, _ = json.Marshal(in)
+	}
+	if out != nil {
+		rec.Output, _ = json.Marshal(out)
+	}
+	if failure != nil {
+		rec.Error = failure.Error()
 	}
-	return web.Respond(ctx, w, system.Response(result, systemType), http.StatusOK)
+	return l.audit.Append(rec)
 }
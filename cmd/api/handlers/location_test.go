@@ -204,4 +204,16 @@ func TestLocate(t *testing.T) {
 			t.Errorf("want %v, got %v", want.Location, got.Location)
 		}
 	})
+
+	t.Run("should reject system types that are not served", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/v1/locate", bytes.NewReader(payload))
+		r.Header.Set("X-System-Type", "ship")
+		w := httptest.NewRecorder()
+		app := handlers.Register(shutdown, logger, handlers.WithSystems(handlers.Drone))
+		app.ServeHTTP(w, r)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Should receive status code %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
 }
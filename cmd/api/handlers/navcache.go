@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrSolvePanicked is returned to requests sharing a computation
+// that panicked
+var ErrSolvePanicked = errors.New("navigator panicked")
+
+// CacheStats reports how a CachedNavigator is performing
+type CacheStats struct {
+	Size      int   `json:"size"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Shared    int64 `json:"shared"`
+	Evictions int64 `json:"evictions"`
+}
+
+// solveKey identifies a Solve computation
+type solveKey struct {
+	cv     CoordsVelocity
+	system System
+	sector float64
+}
+
+type cacheEntry struct {
+	key     solveKey
+	result  float64
+	expires time.Time
+}
+
+// solveCall is a computation shared by concurrent identical requests
+type solveCall struct {
+	done   chan struct{}
+	result float64
+	err    error
+}
+
+// CachedNavigator memoizes the results of another Navigator in a bounded
+// LRU cache whose entries expire after a TTL. Concurrent identical Solve
+// calls are collapsed into a single computation, and the cache is purged
+// whenever the sector served by the wrapped navigator changes.
+type CachedNavigator struct {
+	next Navigator
+	size int
+	ttl  time.Duration
+
+	mu       sync.Mutex
+	sector   float64
+	lru      *list.List
+	entries  map[solveKey]*list.Element
+	inflight map[solveKey]*solveCall
+	stats    CacheStats
+}
+
+// NewCachedNavigator wraps next in a cache of at most size results,
+// each kept for ttl.
+func NewCachedNavigator(next Navigator, size int, ttl time.Duration) *CachedNavigator {
+	return &CachedNavigator{
+		next:     next,
+		size:     size,
+		ttl:      ttl,
+		sector:   next.Sector(),
+		lru:      list.New(),
+		entries:  make(map[solveKey]*list.Element),
+		inflight: make(map[solveKey]*solveCall),
+	}
+}
+
+// Solve returns the cached result for identical inputs, computing it
+// with the wrapped navigator on a miss.
+func (cn *CachedNavigator) Solve(ctx context.Context, cv CoordsVelocity, system System) (float64, error) {
+	key := solveKey{cv: cv, system: system, sector: cn.next.Sector()}
+
+	cn.mu.Lock()
+	if key.sector != cn.sector {
+		cn.purge()
+		cn.sector = key.sector
+	}
+	if el, ok := cn.entries[key]; ok {
+		e := el.Value.(*cacheEntry)
+		if time.Now().Before(e.expires) {
+			cn.lru.MoveToFront(el)
+			cn.stats.Hits++
+			cn.mu.Unlock()
+			return e.result, nil
+		}
+		cn.remove(el)
+	}
+	cn.stats.Misses++
+
+	if call, ok := cn.inflight[key]; ok {
+		cn.stats.Shared++
+		cn.mu.Unlock()
+		return cn.wait(ctx, call, cv, system)
+	}
+
+	call := &solveCall{done: make(chan struct{})}
+	cn.inflight[key] = call
+	cn.mu.Unlock()
+
+	cn.lead(ctx, key, call, cv, system)
+	return call.result, call.err
+}
+
+// lead computes call for the requests sharing it. A panic of the
+// wrapped navigator releases them with ErrSolvePanicked before it
+// carries on up the stack of this request.
+func (cn *CachedNavigator) lead(ctx context.Context, key solveKey, call *solveCall, cv CoordsVelocity, system System) {
+	completed := false
+	defer func() {
+		cn.mu.Lock()
+		delete(cn.inflight, key)
+		if completed && call.err == nil && key.sector == cn.sector {
+			cn.add(key, call.result)
+		}
+		cn.mu.Unlock()
+		if !completed {
+			p := recover()
+			call.err = fmt.Errorf("%w: %v", ErrSolvePanicked, p)
+			close(call.done)
+			if p != nil {
+				panic(p)
+			}
+			return
+		}
+		close(call.done)
+	}()
+
+	call.result, call.err = cn.next.Solve(ctx, cv, system)
+	completed = true
+}
+
+// wait waits for a computation started by another request. When that
+// request gave up because its own context ended, the computation is
+// retried on behalf of this one.
+func (cn *CachedNavigator) wait(ctx context.Context, call *solveCall, cv CoordsVelocity, system System) (float64, error) {
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	if call.err == context.Canceled || call.err == context.DeadlineExceeded {
+		return cn.next.Solve(ctx, cv, system)
+	}
+	return call.result, call.err
+}
+
+// Response implements Navigator
+func (cn *CachedNavigator) Response(data float64, system System) map[string]float64 {
+	return cn.next.Response(data, system)
+}
+
+// Sector implements Navigator
+func (cn *CachedNavigator) Sector() float64 {
+	return cn.next.Sector()
+}
+
+// Purge drops every cached result
+func (cn *CachedNavigator) Purge() {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	cn.purge()
+}
+
+// Stats returns a snapshot of the cache statistics
+func (cn *CachedNavigator) Stats() CacheStats {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	stats := cn.stats
+	stats.Size = cn.lru.Len()
+	return stats
+}
+
+// add caches a result, evicting the least recently used one when
+// full. It must be called with cn.mu held.
+func (cn *CachedNavigator) add(key solveKey, result float64) {
+	if cn.size <= 0 {
+		return
+	}
+	if el, ok := cn.entries[key]; ok {
+		cn.remove(el)
+	}
+	for cn.lru.Len() >= cn.size {
+		cn.remove(cn.lru.Back())
+		cn.stats.Evictions++
+	}
+	e := &cacheEntry{key: key, result: result, expires: time.Now().Add(cn.ttl)}
+	cn.entries[key] = cn.lru.PushFront(e)
+}
+
+// remove must be called with cn.mu held.
+func (cn *CachedNavigator) remove(el *list.Element) {
+	cn.lru.Remove(el)
+	delete(cn.entries, el.Value.(*cacheEntry).key)
+}
+
+// purge must be called with cn.mu held.
+func (cn *CachedNavigator) purge() {
+	cn.lru.Init()
+	cn.entries = make(map[solveKey]*list.Element)
+}
@@ -0,0 +1,161 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/timolinn/dns/cmd/api/handlers"
+)
+
+// countingNavigator counts the computations it performs
+type countingNavigator struct {
+	handlers.SectorNavigator
+	calls   int64
+	release chan struct{}
+	panics  bool
+}
+
+func (cn *countingNavigator) Solve(ctx context.Context, cv handlers.CoordsVelocity, system handlers.System) (float64, error) {
+	atomic.AddInt64(&cn.calls, 1)
+	if cn.release != nil {
+		<-cn.release
+	}
+	if cn.panics {
+		panic("navigation computer on fire")
+	}
+	return cn.SectorNavigator.Solve(ctx, cv, system)
+}
+
+func TestCachedNavigator(t *testing.T) {
+	ctx := context.Background()
+	in := handlers.CoordsVelocity{X: 2.0, Y: 2.0, Z: 2.0, Vel: 2.0}
+
+	t.Run("should memoize identical computations", func(t *testing.T) {
+		next := &countingNavigator{SectorNavigator: handlers.SectorNavigator{SectorID: 1}}
+		cache := handlers.NewCachedNavigator(next, 10, time.Minute)
+
+		for i := 0; i < 3; i++ {
+			res, err := cache.Solve(ctx, in, handlers.Drone)
+			if err != nil {
+				t.Fatalf("expected nil-err got %s", err)
+			}
+			if res != 8 {
+				t.Errorf("CachedNavigator.Solve(): want=%v :: got=%v", 8, res)
+			}
+		}
+		if next.calls != 1 {
+			t.Errorf("want 1 computation, got %d", next.calls)
+		}
+		if stats := cache.Stats(); stats.Hits != 2 || stats.Misses != 1 || stats.Size != 1 {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("should purge the cache when the sector changes", func(t *testing.T) {
+		next := &countingNavigator{SectorNavigator: handlers.SectorNavigator{SectorID: 1}}
+		cache := handlers.NewCachedNavigator(next, 10, time.Minute)
+
+		cache.Solve(ctx, in, handlers.Drone)
+		next.SectorID = 2
+		res, _ := cache.Solve(ctx, in, handlers.Drone)
+		if res != 16 {
+			t.Errorf("CachedNavigator.Solve(): want=%v :: got=%v", 16, res)
+		}
+		if next.calls != 2 {
+			t.Errorf("want 2 computations, got %d", next.calls)
+		}
+	})
+
+	t.Run("should evict the least recently used result", func(t *testing.T) {
+		next := &countingNavigator{SectorNavigator: handlers.SectorNavigator{SectorID: 1}}
+		cache := handlers.NewCachedNavigator(next, 1, time.Minute)
+
+		other := handlers.CoordsVelocity{X: 1.0, Y: 1.0, Z: 1.0, Vel: 1.0}
+		cache.Solve(ctx, in, handlers.Drone)
+		cache.Solve(ctx, other, handlers.Drone)
+		cache.Solve(ctx, in, handlers.Drone)
+		if stats := cache.Stats(); stats.Evictions != 2 || stats.Size != 1 {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("should collapse concurrent identical computations", func(t *testing.T) {
+		next := &countingNavigator{
+			SectorNavigator: handlers.SectorNavigator{SectorID: 1},
+			release:         make(chan struct{}),
+		}
+		cache := handlers.NewCachedNavigator(next, 10, time.Minute)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if res, err := cache.Solve(ctx, in, handlers.Drone); err != nil || res != 8 {
+					t.Errorf("CachedNavigator.Solve(): want=%v :: got=%v, %v", 8, res, err)
+				}
+			}()
+		}
+		for cache.Stats().Misses < 10 {
+			time.Sleep(time.Millisecond)
+		}
+		close(next.release)
+		wg.Wait()
+
+		if next.calls != 1 {
+			t.Errorf("want 1 computation, got %d", next.calls)
+		}
+		if stats := cache.Stats(); stats.Shared != 9 {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("should release the requests sharing a computation that panicked", func(t *testing.T) {
+		next := &countingNavigator{
+			SectorNavigator: handlers.SectorNavigator{SectorID: 1},
+			release:         make(chan struct{}),
+			panics:          true,
+		}
+		cache := handlers.NewCachedNavigator(next, 10, time.Minute)
+
+		leader := make(chan interface{}, 1)
+		go func() {
+			defer func() { leader <- recover() }()
+			cache.Solve(ctx, in, handlers.Drone)
+		}()
+		for cache.Stats().Misses < 1 {
+			time.Sleep(time.Millisecond)
+		}
+
+		waiter := make(chan error, 1)
+		go func() {
+			_, err := cache.Solve(ctx, in, handlers.Drone)
+			waiter <- err
+		}()
+		for cache.Stats().Shared < 1 {
+			time.Sleep(time.Millisecond)
+		}
+		close(next.release)
+
+		if p := <-leader; p == nil {
+			t.Error("Should panic in the leading request")
+		}
+		select {
+		case err := <-waiter:
+			if !errors.Is(err, handlers.ErrSolvePanicked) {
+				t.Errorf("Should return ErrSolvePanicked, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Should release the waiting request")
+		}
+
+		next.panics = false
+		if res, err := cache.Solve(ctx, in, handlers.Drone); err != nil || res != 8 {
+			t.Errorf("Should compute again, got %v, %v", res, err)
+		}
+	})
+}
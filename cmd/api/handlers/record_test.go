@@ -0,0 +1,83 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/timolinn/dns/cmd/api/handlers"
+	"github.com/timolinn/dns/pkg/auth"
+	"github.com/timolinn/dns/pkg/capture"
+)
+
+func TestRecording(t *testing.T) {
+	shutdown := make(chan os.Signal, 1)
+	logger := log.New(os.Stdout, "DNS : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+
+	var buf bytes.Buffer
+	app := handlers.Register(shutdown, logger, handlers.WithRecording(&buf), handlers.WithCompression(0))
+
+	for _, system := range []string{"drone", "ship", "tank"} {
+		r := httptest.NewRequest(http.MethodPost, "/v1/locate", strings.NewReader(`{"x":"2","y":"2","z":"2","vel":"2"}`))
+		r.Header.Set("X-System-Type", system)
+		r.Header.Set("Accept-Encoding", "gzip")
+		app.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	t.Run("should replay the capture without diffs", func(t *testing.T) {
+		replay := handlers.Register(shutdown, logger)
+
+		var replayed int
+		err := capture.Read(&buf, func(e *capture.Exchange) error {
+			replayed++
+			r, err := e.NewRequest("")
+			if err != nil {
+				return err
+			}
+			w := httptest.NewRecorder()
+			replay.ServeHTTP(w, r)
+			if d := capture.Compare(e.Response, w.Code, w.Body.Bytes()); d != nil {
+				t.Errorf("%s %s: unexpected diff %+v", e.Request.Method, e.Request.URI, d)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if replayed != 3 {
+			t.Errorf("want 3 exchanges, got %d", replayed)
+		}
+	})
+
+	t.Run("should not record the keys created", func(t *testing.T) {
+		store := auth.NewKeyStore()
+		_, adminKey, err := store.Create("admin", nil, true)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		var buf bytes.Buffer
+		app := handlers.Register(shutdown, logger, handlers.WithRecording(&buf), handlers.WithKeyStore(store))
+
+		r := httptest.NewRequest(http.MethodPost, "/v1/admin/keys", strings.NewReader(`{"fleet":"alpha","systems":["drone"]}`))
+		r.Header.Set(auth.HeaderAPIKey, adminKey)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Should receive status code %d, got %d", http.StatusCreated, w.Code)
+		}
+		var created struct {
+			Key string `json:"key"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || created.Key == "" {
+			t.Fatalf("Should return the key, got %s", w.Body.String())
+		}
+		if strings.Contains(buf.String(), created.Key) {
+			t.Errorf("Should not record the key secret, got %s", buf.String())
+		}
+	})
+}
@@ -34,10 +34,12 @@ type CoordsVelocity struct {
 }
 
 // Navigator describes a contract for providing
-// navigation service to multiple kinds of systems
+// navigation service to multiple kinds of systems.
+// Implementations must give up once ctx is done.
 type Navigator interface {
-	Solve(CoordsVelocity, System) (float64, error)
+	Solve(context.Context, CoordsVelocity, System) (float64, error)
 	Response(float64, System) map[string]float64
+	Sector() float64
 }
 
 // SectorNavigator provides navigation functionality
@@ -54,7 +56,11 @@ func NewSectorNavigator() Navigator {
 }
 
 // Solve computes the navigation puzzle
-func (sn *SectorNavigator) Solve(cv CoordsVelocity, system System) (float64, error) {
+func (sn *SectorNavigator) Solve(ctx context.Context, cv CoordsVelocity, system System) (float64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	switch system {
 	case Drone, Ship:
 		result := (cv.X * sn.SectorID) + (cv.Y * sn.SectorID) + (cv.Z * sn.SectorID) + cv.Vel*sn.SectorID
@@ -65,6 +71,11 @@ func (sn *SectorNavigator) Solve(cv CoordsVelocity, system System) (float64, err
 	}
 }
 
+// Sector returns the ID of the sector the navigator serves
+func (sn *SectorNavigator) Sector() float64 {
+	return sn.SectorID
+}
+
 // Response constucts a response map based on systemType
 func (sn *SectorNavigator) Response(data float64, systemType System) map[string]float64 {
 	resp := make(map[string]float64)
@@ -77,9 +88,12 @@ func (sn *SectorNavigator) Response(data float64, systemType System) map[string]
 	return resp
 }
 
-func home(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintln(w, fmt.Sprintf("Welcome to DNS on Sector %v", SectorID))
-	return nil
+// home welcomes callers to the sector served by nav
+func home(nav Navigator) func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, fmt.Sprintf("Welcome to DNS on Sector %v", nav.Sector()))
+		return nil
+	}
 }
@@ -1,6 +1,7 @@
 package handlers_test
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"net/http/httptest"
@@ -25,7 +26,7 @@ func TestSolve(t *testing.T) {
 	navigator := handlers.NewSectorNavigator()
 	for _, test := range cases {
 		t.Run("should return correct computation result", func(t *testing.T) {
-			res, err := navigator.Solve(test.in, handlers.Drone)
+			res, err := navigator.Solve(context.Background(), test.in, handlers.Drone)
 			if err != nil {
 				t.Fatalf("expected nil-err got %s", err)
 			}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+
+	"github.com/timolinn/dns/pkg/buildinfo"
+	"github.com/timolinn/dns/pkg/web"
+)
+
+func init() {
+	// label the metrics with the build serving them
+	expvar.Publish("build", expvar.Func(func() interface{} { return buildinfo.Get() }))
+}
+
+// version serves the build information of the binary
+func version(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return web.Respond(ctx, w, buildinfo.Get(), http.StatusOK)
+}
@@ -0,0 +1,34 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/timolinn/dns/cmd/api/handlers"
+	"github.com/timolinn/dns/pkg/buildinfo"
+)
+
+func TestVersion(t *testing.T) {
+	shutdown := make(chan os.Signal, 1)
+	logger := log.New(os.Stdout, "TEST : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	app := handlers.Register(shutdown, logger)
+
+	t.Run("should serve the build information", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("Should receive status code %d, got %d", http.StatusOK, w.Code)
+		}
+		var info buildinfo.Info
+		if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if info != buildinfo.Get() {
+			t.Errorf("want %+v, got %+v", buildinfo.Get(), info)
+		}
+	})
+}
@@ -2,72 +2,646 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/timolinn/dns/cmd/api/handlers"
+	"github.com/timolinn/dns/middleware"
+	"github.com/timolinn/dns/pkg/audit"
+	"github.com/timolinn/dns/pkg/auth"
+	"github.com/timolinn/dns/pkg/buildinfo"
+	"github.com/timolinn/dns/pkg/config"
+	"github.com/timolinn/dns/pkg/health"
+	"github.com/timolinn/dns/pkg/listener"
+	"github.com/timolinn/dns/pkg/rotate"
+	"github.com/timolinn/dns/pkg/tlsutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
 )
 
-var version = "develop"
+func main() {
+	// without a subcommand, or with flags only, the server is started
+	cmd, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
 
-var addr string
-var readtimeout, writetimeout int
+	var err error
+	switch cmd {
+	case "serve":
+		serve(args)
+		return
+	case "locate":
+		err = locate(args, os.Stdin, os.Stdout)
+	case "config":
+		err = configCmd(args, os.Stdout)
+	case "routes":
+		err = routes(args, os.Stdout)
+	case "keys":
+		err = keysCmd(args, os.Stdout)
+	case "version":
+		fmt.Println("dns", buildinfo.Get())
+	case "help":
+		usage()
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dns:", err)
+		os.Exit(1)
+	}
+}
 
-func main() {
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: dns [serve] [flags]")
+	fmt.Fprintln(os.Stderr, "       dns locate [-system drone|ship] [flags] [x y z vel]")
+	fmt.Fprintln(os.Stderr, "       dns config validate|print [flags]")
+	fmt.Fprintln(os.Stderr, "       dns routes [flags]")
+	fmt.Fprintln(os.Stderr, "       dns keys list|create|rotate|revoke [-file path] ...")
+	fmt.Fprintln(os.Stderr, "       dns version")
+	fmt.Fprintln(os.Stderr, "flags are the settings listed by dns serve -h")
+}
 
-	flag.StringVar(&addr, "addr", ":8080", "define server address")
-	flag.IntVar(&readtimeout, "readtimeout", 5, "sets the read timeout in seconds")
-	flag.IntVar(&writetimeout, "writetimeout", 10, "sets the write timeout in seconds")
-	flag.Parse()
+// serve starts the server configured by args, the environment and
+// the configuration file, and serves until it is shut down
+func serve(args []string) {
+	cfg, opts, err := loadConfig(args, os.Stderr)
+	if err == flag.ErrHelp {
+		return
+	}
+	// the version is printed even when the configuration is invalid
+	if opts.version {
+		fmt.Println("dns", buildinfo.Get())
+		return
+	}
+	// an invalid configuration is printed too, to find what is wrong with it
+	if _, invalid := err.(*config.ValidationError); opts.printConfig && (err == nil || invalid) {
+		data, _ := json.MarshalIndent(cfg.Redacted(), "", "  ")
+		fmt.Println(string(data))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if opts.printConfig {
+		return
+	}
 
-	logger := log.New(os.Stdout, "DNS : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
-	if err := run(logger); err != nil {
+	// every line is labelled with the version, telling apart the
+	// processes of an upgrade logging to the same output
+	info := buildinfo.Get()
+	logger := log.New(os.Stdout, "DNS "+info.Version+" : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	logger.Printf("main: starting dns %s", info)
+	if err := run(logger, cfg, args); err != nil {
 		logger.Println(err)
 		os.Exit(1)
 	}
 }
 
-func run(logger *log.Logger) error {
+// options are the command line flags that are not settings
+type options struct {
+	printConfig bool
+	version     bool
+}
+
+// loadConfig loads the configuration from the command line args, the
+// environment and the configuration file, writing flag errors and
+// warnings about unknown DNS_* environment variables to out. It also
+// returns the flags that are not settings.
+func loadConfig(args []string, out io.Writer) (config.Config, options, error) {
+	var opts options
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.BoolVar(&opts.printConfig, "print-config", false, "print the effective configuration, secrets redacted, and exit")
+	fs.BoolVar(&opts.version, "version", false, "print the version and exit")
+	cfg, err := config.Load(fs, args)
+	for _, name := range config.UnknownEnv(os.Environ(), listener.EnvInherit, listener.EnvReady) {
+		fmt.Fprintf(out, "dns: ignoring %s, it names no setting\n", name)
+	}
+	return cfg, opts, err
+}
+
+// limiterConfig returns the route limiter configuration of cfg
+func limiterConfig(cfg config.Config) middleware.LimiterConfig {
+	limits := middleware.DefaultLimiterConfig
+	limits.InitialLimit, limits.MaxLimit = cfg.Limits.Initial, cfg.Limits.Max
+	limits.QueueSize, limits.TargetLatency = cfg.Limits.Queue, cfg.Limits.Latency.Duration()
+	return limits
+}
+
+// configCheck and storageCheck are how often readiness probes load the
+// configuration again and create files beside the ones DNS writes
+const (
+	configCheck  = 10 * time.Second
+	storageCheck = 30 * time.Second
+)
+
+// reloadable lists the settings applied by a reload, through settings
+// and by reading the API keys again. Other settings require a restart.
+var reloadable = map[string]bool{
+	"navigation.sector":  true,
+	"navigation.systems": true,
+	"log.level":          true,
+	"limits.initial":     true,
+	"limits.max":         true,
+	"limits.queue":       true,
+	"limits.latency":     true,
+}
+
+// settings returns the settings of cfg applied without a restart
+func settings(cfg config.Config) handlers.Settings {
+	s := handlers.Settings{Sector: cfg.Navigation.Sector}
+	for _, system := range cfg.Navigation.Systems {
+		s.Systems = append(s.Systems, handlers.System(system))
+	}
+	// the level was checked when the configuration was validated
+	s.LogLevel, _ = middleware.ParseLevel(cfg.Log.Level)
+	if cfg.Limits.Enabled {
+		limits := limiterConfig(cfg)
+		s.Limits = &limits
+	}
+	return s
+}
+
+// stores are the stores and files the App of a configuration uses,
+// opened by run
+type stores struct {
+	keys        *auth.KeyStore
+	jwt         *auth.JWTVerifier
+	idempotency middleware.IdempotencyStore
+	access      io.Writer
+	audit       *audit.Log
+	record      io.Writer
+}
+
+// handlerOptions returns the options of the App serving cfg with st,
+// the stores left nil are disabled.
+func handlerOptions(cfg config.Config, live *handlers.Live, st stores) []handlers.Option {
+	opts := []handlers.Option{handlers.WithLive(live)}
+	if st.keys != nil {
+		opts = append(opts, handlers.WithKeyStore(st.keys))
+	}
+	if st.jwt != nil {
+		opts = append(opts, handlers.WithJWT(st.jwt))
+	}
+	if cfg.Auth.SystemOverride {
+		opts = append(opts, handlers.WithSystemOverride())
+	}
+	if len(cfg.CORS.Origins) > 0 {
+		cors := middleware.DefaultCORSConfig
+		cors.AllowedOrigins = cfg.CORS.Origins
+		cors.AllowedMethods = cfg.CORS.Methods
+		cors.AllowedHeaders = cfg.CORS.Headers
+		cors.AllowCredentials = cfg.CORS.Credentials
+		opts = append(opts, handlers.WithCORS(cors))
+	}
+	if cfg.Timeouts.Request > 0 || len(cfg.Timeouts.Routes) > 0 {
+		opts = append(opts, handlers.WithTimeouts(cfg.Timeouts.Request.Duration(), cfg.Timeouts.Routes.Durations()))
+	}
+	if cfg.Limits.Enabled {
+		opts = append(opts, handlers.WithLimits(limiterConfig(cfg)))
+	}
+	if st.idempotency != nil {
+		opts = append(opts, handlers.WithIdempotency(st.idempotency, cfg.Idempotency.TTL.Duration()))
+	}
+	if cfg.Navigation.CacheSize > 0 {
+		opts = append(opts, handlers.WithNavigatorCache(cfg.Navigation.CacheSize, cfg.Navigation.CacheTTL.Duration()))
+	}
+	if st.access != nil {
+		opts = append(opts, handlers.WithAccessLog(st.access, cfg.Log.Access.Format))
+	}
+	if st.audit != nil {
+		opts = append(opts, handlers.WithAudit(st.audit))
+	}
+	if st.record != nil {
+		opts = append(opts, handlers.WithRecording(st.record))
+	}
+	if cfg.Compression.Enabled {
+		opts = append(opts, handlers.WithCompression(cfg.Compression.MinSize))
+	}
+	return opts
+}
+
+// run serves cfg, loaded from args which are loaded again on reload
+func run(logger *log.Logger, cfg config.Config, args []string) error {
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 
+	live := handlers.NewLive(settings(cfg))
+	var st stores
+
+	var keys *auth.KeyStore
+	if cfg.Auth.Keys != "" {
+		var err error
+		keys, err = auth.OpenKeyStore(cfg.Auth.Keys)
+		if err != nil {
+			return err
+		}
+		// a fresh key store gets an administrator key so that
+		// keys can be issued for the fleet over the admin endpoints
+		if keys.Len() == 0 {
+			_, secret, err := keys.Create("admin", nil, true)
+			if err != nil {
+				return errors.Wrap(err, "issuing admin key")
+			}
+			// the secret is kept out of the application log, which
+			// is shipped to log aggregation
+			fmt.Fprintf(os.Stderr, "dns: issued admin API key %s, it will not be shown again\n", secret)
+			logger.Println("main: issued an admin API key, its secret was written to stderr")
+		}
+		st.keys = keys
+	}
+	if cfg.Auth.JWKS != "" || cfg.Auth.JWTSecret != "" {
+		jwtConfig := auth.JWTConfig{
+			Issuer:   cfg.Auth.JWTIssuer,
+			Audience: cfg.Auth.JWTAudience,
+			Leeway:   30 * time.Second,
+		}
+		var err error
+		if cfg.Auth.JWKS != "" {
+			st.jwt, err = auth.LoadJWTVerifier(cfg.Auth.JWKS, jwtConfig)
+		} else {
+			st.jwt, err = auth.NewSecretJWTVerifier([]byte(cfg.Auth.JWTSecret), jwtConfig)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if cfg.Idempotency.TTL > 0 {
+		st.idempotency = middleware.NewMemoryIdempotencyStore(cfg.Idempotency.MaxKeys, cfg.Idempotency.Lease.Duration())
+	}
+	if access := cfg.Log.Access; access.Path != "" {
+		st.access = os.Stdout
+		if access.Path != "-" {
+			w, err := rotate.New(rotate.Config{
+				Filename:   access.Path,
+				MaxSize:    int64(access.MaxSize) << 20,
+				Interval:   access.Rotate.Duration(),
+				Compress:   access.Compress,
+				MaxBackups: access.MaxBackups,
+				MaxAge:     access.MaxAge.Duration(),
+			})
+			if err != nil {
+				return err
+			}
+			defer w.Close()
+			st.access = w
+		}
+	}
+	if cfg.Log.Audit.Path != "" {
+		trail, err := audit.Open(cfg.Log.Audit.Path, cfg.Log.Audit.Sync)
+		if err != nil {
+			return err
+		}
+		if n := trail.Truncated(); n > 0 {
+			logger.Printf("main: truncated an incomplete audit record of %d bytes", n)
+		}
+		defer trail.Close()
+		st.audit = trail
+	}
+	if cfg.Log.Record != "" {
+		f, err := os.OpenFile(cfg.Log.Record, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return errors.Wrap(err, "opening capture file")
+		}
+		defer f.Close()
+		st.record = f
+	}
+	opts := handlerOptions(cfg, live, st)
+
+	// readiness fails while the configuration on disk does not
+	// validate, so that a broken deploy is noticed before a reload.
+	// Loading it is costly, so it is checked at most every configCheck,
+	// and every reload records its outcome.
+	validConfig := health.NewCached(func(ctx context.Context) error {
+		_, _, err := loadConfig(args, ioutil.Discard)
+		return err
+	}, configCheck)
+
+	// reload applies the settings of the reloaded configuration that
+	// do not require a restart, and re-reads the API keys. The others
+	// are logged as waiting for a restart. Nothing is applied when the
+	// new configuration is invalid or turns the route limiters on or off,
+	// which are only mounted at startup.
+	var reloading sync.Mutex
+	current := cfg
+	reload := func() error {
+		reloading.Lock()
+		defer reloading.Unlock()
+
+		next, _, err := loadConfig(args, ioutil.Discard)
+		validConfig.Set(err)
+		if err != nil {
+			return err
+		}
+		var restart []string
+		for _, path := range config.Changed(cfg, next) {
+			if path == "limits.enabled" {
+				return errors.New("limits.enabled can not be changed by a reload, restart the server")
+			}
+			if !reloadable[path] {
+				restart = append(restart, path)
+			}
+		}
+		if keys != nil {
+			if err := keys.Reload(); err != nil {
+				return err
+			}
+		}
+		s := settings(next)
+		live.Apply(s)
+		current = next
+		logger.Printf("main: reloaded configuration, sector %v, systems %v, log level %s", s.Sector, s.Systems, s.LogLevel)
+		if len(restart) > 0 {
+			logger.Printf("main: changes to %s apply after a restart", strings.Join(restart, ", "))
+		}
+		return nil
+	}
+	opts = append(opts, handlers.WithReload(reload))
+
+	// readiness also fails while the files DNS writes cannot be created,
+	// probed every storageCheck
+	checker := health.New()
+	checker.Register("config", validConfig.Check)
+	var files []string
+	for _, path := range []string{cfg.Auth.Keys, cfg.Log.Audit.Path, cfg.Log.Access.Path, cfg.Log.Record} {
+		if path != "" && path != "-" {
+			files = append(files, path)
+		}
+	}
+	if len(files) > 0 {
+		checker.Register("storage", health.NewCached(health.Writable(files...), storageCheck).Check)
+	}
+	opts = append(opts, handlers.WithHealth(checker))
+
+	var tlsConfig *tls.Config
+	if tlscfg := cfg.Server.TLS; tlscfg.Cert != "" {
+		certs, err := tlsutil.NewCertReloader(tlscfg.Cert, tlscfg.Key, tlscfg.Reload.Duration(), logger)
+		if err != nil {
+			return err
+		}
+		// the version and suites were checked when the
+		// configuration was validated
+		minVersion, _ := tlsutil.ParseVersion(tlscfg.MinVersion)
+		suites, _ := tlsutil.CipherSuites(tlscfg.CipherSuites)
+		tlsConfig = &tls.Config{
+			GetCertificate: certs.GetCertificate,
+			MinVersion:     minVersion,
+			NextProtos:     []string{"http/1.1"},
+		}
+		if len(suites) > 0 {
+			tlsConfig.CipherSuites = suites
+		}
+		if tlscfg.HTTP2 {
+			tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+		}
+		if tlscfg.ClientCA != "" {
+			pool, err := tlsutil.LoadCertPool(tlscfg.ClientCA)
+			if err != nil {
+				return err
+			}
+			mode, err := tlsutil.ClientAuth(tlscfg.ClientAuth)
+			if err != nil {
+				return err
+			}
+			tlsConfig.ClientCAs, tlsConfig.ClientAuth = pool, mode
+			opts = append(opts, handlers.WithClientCerts())
+		}
+	}
+
+	// the gRPC server shares the navigator, authentication, audit log
+	// and health checks of the App, and serves the certificate of the
+	// HTTP server over HTTP/2
+	var grpcServer *grpc.Server
+	if cfg.GRPC.Addr != "" {
+		var serverOpts []grpc.ServerOption
+		if tlsConfig != nil {
+			grpcTLS := tlsConfig.Clone()
+			grpcTLS.NextProtos = []string{"h2"}
+			serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(grpcTLS)))
+		}
+		grpcServer = grpc.NewServer(serverOpts...)
+		reflection.Register(grpcServer)
+		opts = append(opts, handlers.WithGRPC(grpcServer, cfg.GRPC.MaxBatch))
+	}
+
+	app := handlers.Register(shutdown, logger, opts...)
 	server := &http.Server{
-		Addr:         addr,
-		Handler:      handlers.Register(shutdown, logger),
-		ReadTimeout:  time.Duration(readtimeout) * time.Second,
-		WriteTimeout: time.Duration(writetimeout) * time.Second,
+		Addr:         cfg.Server.Addr,
+		Handler:      app,
+		ReadTimeout:  cfg.Server.ReadTimeout.Duration(),
+		WriteTimeout: cfg.Server.WriteTimeout.Duration(),
 		ErrorLog:     logger,
+		TLSConfig:    tlsConfig,
+	}
+	if tlsConfig != nil && !cfg.Server.TLS.HTTP2 {
+		// a non-nil map keeps net/http from enabling HTTP/2
+		server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+
+	lns, err := listener.Open(cfg.Server.Listeners())
+	if err != nil {
+		return errors.Wrap(err, "opening listeners")
+	}
+
+	// handle errors from request listeners
+	serverErr := make(chan error, len(lns)+2)
+
+	// the admin listener is kept off the public address, the
+	// profiles it serves may take longer than the write timeout
+	var adminServer *http.Server
+	var adminLns []listener.Listener
+	if cfg.Admin.Addr != "" {
+		adminLns, err = listener.Open([]listener.Spec{{Scheme: listener.TCP, Address: cfg.Admin.Addr}})
+		if err != nil {
+			return errors.Wrap(err, "opening admin listener")
+		}
+
+		admin := handlers.RegisterAdmin(shutdown, logger, handlers.AdminOptions{
+			Token: cfg.Admin.Token,
+			Live:  live,
+			Config: func() interface{} {
+				reloading.Lock()
+				defer reloading.Unlock()
+				return current.Redacted()
+			},
+		})
+		adminServer = &http.Server{
+			Addr:        cfg.Admin.Addr,
+			Handler:     admin,
+			ReadTimeout: cfg.Server.ReadTimeout.Duration(),
+			ErrorLog:    logger,
+		}
+		go func() {
+			log.Printf("admin listening on %v", adminLns[0].Addr())
+			serverErr <- errors.Wrap(adminServer.Serve(adminLns[0]), "admin")
+		}()
+	}
+
+	var grpcLns []listener.Listener
+	if grpcServer != nil {
+		grpcLns, err = listener.Open([]listener.Spec{{Scheme: listener.TCP, Address: cfg.GRPC.Addr}})
+		if err != nil {
+			return errors.Wrap(err, "opening gRPC listener")
+		}
+		go func() {
+			log.Printf("gRPC listening on %v", grpcLns[0].Addr())
+			serverErr <- errors.Wrap(grpcServer.Serve(grpcLns[0]), "grpc")
+		}()
 	}
 
-	// handle errors from request listener
-	serverErr := make(chan error, 1)
+	// serve the same handler on every listener
+	for _, ln := range lns {
+		go func(ln listener.Listener) {
+			log.Printf("server listening on %v (%v)", ln.Spec, ln.Addr())
+			if ln.TLS() {
+				// the certificate is served by the reloader
+				serverErr <- server.ServeTLS(ln, "", "")
+				return
+			}
+			serverErr <- server.Serve(ln)
+		}(ln)
+	}
+
+	// listeners of the process that started this one by an upgrade
+	// that the configuration no longer serves refuse their clients
+	for _, spec := range listener.CloseUnclaimed() {
+		logger.Printf("main: closed inherited listener %s, it is no longer configured", spec)
+	}
+
+	// let the process that started this one by an upgrade drain
+	if err := listener.Ready(); err != nil {
+		logger.Printf("main: %v", err)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	// the executable is looked up now, a new binary
+	// may be installed over it before an upgrade
+	exe, err := os.Executable()
+	if err != nil {
+		return errors.Wrap(err, "locating executable")
+	}
+	usr2 := make(chan os.Signal, 1)
+	signal.Notify(usr2, syscall.SIGUSR2)
+	handover := append(append(append([]listener.Listener(nil), lns...), adminLns...), grpcLns...)
+
+	// upgrades run apart from the loop, which keeps handling signals
+	// and server errors meanwhile. Shutting down stops the new process
+	// of an upgrade still waited for.
+	type upgrade struct {
+		p   *os.Process
+		err error
+	}
+	upgrades := make(chan upgrade, 1)
+	upgrading := false
+	upgradeCtx, cancelUpgrade := context.WithCancel(context.Background())
+	defer cancelUpgrade()
+
+	for {
+		select {
+		case err := <-serverErr:
+			return errors.Wrap(err, "server error")
+
+		case <-hup:
+			if err := reload(); err != nil {
+				logger.Printf("main: keeping the current configuration: %v", err)
+			}
+
+		case <-usr2:
+			if upgrading {
+				logger.Println("main: already upgrading")
+				continue
+			}
+			upgrading = true
+			logger.Printf("main: upgrading to %s", exe)
+			go func() {
+				p, err := listener.Upgrade(upgradeCtx, exe, os.Args[1:], handover, cfg.Server.UpgradeTimeout.Duration())
+				upgrades <- upgrade{p: p, err: err}
+			}()
 
-	// start our server
-	go func() {
-		log.Printf("server listening on %v", server.Addr)
-		serverErr <- server.ListenAndServe()
-	}()
+		case u := <-upgrades:
+			upgrading = false
+			if u.err != nil {
+				logger.Printf("main: upgrade failed, this process keeps serving: %v", u.err)
+				continue
+			}
+			logger.Printf("main: process %d serves, draining this one", u.p.Pid)
+			u.p.Release()
+			select {
+			case shutdown <- syscall.SIGUSR2:
+			default:
+			}
 
-	select {
-	case err := <-serverErr:
-		return errors.Wrap(err, "server error")
+		case sig := <-shutdown:
+			log.Printf("main %v: Start service shutdown", sig)
+			cancelUpgrade()
 
-	case sig := <-shutdown:
-		log.Printf("main %v: Start service shutdown", sig)
+			// fail readiness first, giving load balancers time to
+			// stop routing new requests before connections drain.
+			// After an upgrade the new process serves the same
+			// sockets, the instance stays ready
+			app.Drain()
+			// requests already in flight answer Connection: close
+			// too, and idle connections are closed
+			server.SetKeepAlivesEnabled(false)
+			if sig != syscall.SIGUSR2 {
+				checker.Drain()
+				time.Sleep(cfg.Server.ReadyDelay.Duration())
+			}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.DrainTimeout.Duration())
+			defer cancel()
 
-		if err := server.Shutdown(ctx); err != nil {
-			server.Close()
-			return errors.Wrap(err, "could not stop server gracefully")
+			if adminServer != nil {
+				defer adminServer.Close()
+			}
+			if grpcServer != nil {
+				// GracefulStop waits for every call, the drain deadline
+				// cuts the remaining ones
+				stopped := make(chan struct{})
+				go func() {
+					grpcServer.GracefulStop()
+					close(stopped)
+				}()
+				defer func() {
+					select {
+					case <-stopped:
+					case <-ctx.Done():
+						grpcServer.Stop()
+					}
+				}()
+			}
+			if err := server.Shutdown(ctx); err != nil {
+				for _, r := range app.InFlight() {
+					logger.Printf("main: still active at the drain deadline: %s %s %s for %v", r.ID, r.Method, r.Path, time.Since(r.Started).Round(time.Millisecond))
+				}
+				server.Close()
+				return errors.Wrap(err, "could not stop server gracefully")
+			}
+			if adminServer != nil {
+				adminServer.Shutdown(ctx)
+			}
+			return nil
 		}
 	}
-	return nil
 }
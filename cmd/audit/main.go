@@ -0,0 +1,123 @@
+// Command audit verifies and exports the audit trail of locate
+// requests written by the DNS server with -audit-log.
+//
+//	audit verify -file audit.log
+//	audit export -file audit.log -principal drone-7 -from 2020-06-01T00:00:00Z -format csv
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/timolinn/dns/pkg/audit"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "verify":
+		err = verify(os.Args[2:], os.Stdout)
+	case "export":
+		err = export(os.Args[2:], os.Stdout)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "audit:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: audit verify -file <path>")
+	fmt.Fprintln(os.Stderr, "       audit export -file <path> [-principal id] [-system type] [-sector id] [-from time] [-to time] [-limit n] [-format ndjson|csv]")
+}
+
+func verify(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	file := fs.String("file", "audit.log", "path to the audit log")
+	fs.Parse(args)
+
+	var count uint64
+	err := audit.Scan(*file, func(r *audit.Record) error {
+		count = r.Seq
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s: %d records, checksum chain intact\n", *file, count)
+	return nil
+}
+
+func export(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	file := fs.String("file", "audit.log", "path to the audit log")
+	format := fs.String("format", "ndjson", "output format: 'ndjson' or 'csv'")
+	params := map[string]*string{
+		"principal": fs.String("principal", "", "only export records of this drone or ship"),
+		"system":    fs.String("system", "", "only export records of this system type"),
+		"sector":    fs.String("sector", "", "only export records of this sector"),
+		"from":      fs.String("from", "", "only export records at or after this RFC 3339 time"),
+		"to":        fs.String("to", "", "only export records before this RFC 3339 time"),
+		"limit":     fs.String("limit", "0", "export at most this many records, 0 exports all"),
+	}
+	fs.Parse(args)
+
+	filter, err := audit.ParseFilter(func(name string) string { return *params[name] })
+	if err != nil {
+		return err
+	}
+
+	var write func(*audit.Record) error
+	switch *format {
+	case "ndjson":
+		enc := json.NewEncoder(out)
+		write = func(r *audit.Record) error { return enc.Encode(r) }
+	case "csv":
+		w := csv.NewWriter(out)
+		defer w.Flush()
+		w.Write([]string{"seq", "time", "request_id", "principal", "fleet", "remote", "system", "sector", "input", "output", "status", "error", "version", "checksum"})
+		write = func(r *audit.Record) error {
+			return w.Write([]string{
+				strconv.FormatUint(r.Seq, 10), r.Time.Format(time.RFC3339Nano), r.RequestID,
+				r.Principal, r.Fleet, r.Remote, r.System,
+				strconv.FormatFloat(r.Sector, 'f', -1, 64),
+				string(r.Input), string(r.Output), strconv.Itoa(r.Status), r.Error, r.Version, r.Checksum,
+			})
+		}
+	default:
+		return errors.Errorf("unknown format %q: requires 'ndjson' or 'csv'", *format)
+	}
+
+	exported := 0
+	err = audit.Scan(*file, func(r *audit.Record) error {
+		if !filter.Match(r) {
+			return nil
+		}
+		if err := write(r); err != nil {
+			return err
+		}
+		if exported++; filter.Limit > 0 && exported >= filter.Limit {
+			return io.EOF
+		}
+		return nil
+	})
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
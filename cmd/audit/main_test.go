@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/timolinn/dns/pkg/audit"
+)
+
+// trail writes an audit log of the given principals to a temporary
+// directory and returns its path
+func trail(t *testing.T, principals ...string) string {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "audit.log")
+	l, err := audit.Open(path, false)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	defer l.Close()
+	for _, p := range principals {
+		err := l.Append(audit.Record{Principal: p, System: "drone", Status: 200, Input: json.RawMessage(`{"x":"1"}`)})
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+	}
+	return path
+}
+
+func TestVerify(t *testing.T) {
+	path := trail(t, "drone-1", "drone-2")
+
+	var out bytes.Buffer
+	if err := verify([]string{"-file", path}, &out); err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	if !strings.Contains(out.String(), "2 records, checksum chain intact") {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	ioutil.WriteFile(path, bytes.Replace(data, []byte("drone-1"), []byte("drone-9"), 1), 0600)
+	if err := verify([]string{"-file", path}, &out); errors.Cause(err) != audit.ErrCorrupted {
+		t.Errorf("want %v, got %v", audit.ErrCorrupted, err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	path := trail(t, "drone-1", "drone-2", "drone-1")
+
+	t.Run("should export ndjson", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := export([]string{"-file", path, "-principal", "drone-1"}, &out); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("want 2 records, got %q", out.String())
+		}
+		var r audit.Record
+		if err := json.Unmarshal([]byte(lines[1]), &r); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if r.Seq != 3 || r.Principal != "drone-1" {
+			t.Errorf("unexpected record %+v", r)
+		}
+	})
+
+	t.Run("should export csv", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := export([]string{"-file", path, "-format", "csv", "-limit", "2"}, &out); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		rows, err := csv.NewReader(&out).ReadAll()
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if len(rows) != 3 || rows[0][0] != "seq" {
+			t.Fatalf("want a header and 2 rows, got %q", rows)
+		}
+		if rows[2][0] != "2" || rows[2][3] != "drone-2" || rows[2][8] != `{"x":"1"}` {
+			t.Errorf("unexpected row %q", rows[2])
+		}
+	})
+
+	t.Run("should reject unknown formats", func(t *testing.T) {
+		if err := export([]string{"-file", path, "-format", "xml"}, ioutil.Discard); err == nil {
+			t.Error("Should reject the xml format")
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/timolinn/dns/pkg/client"
+)
+
+// exitCode maps the error of a request to the exit status of dnsctl
+func exitCode(err error) int {
+	var apiErr *client.Error
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &apiErr):
+		switch {
+		case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+			return exitAuth
+		case apiErr.StatusCode >= http.StatusInternalServerError:
+			return exitServer
+		case apiErr.StatusCode >= http.StatusBadRequest:
+			return exitRejected
+		}
+	case errors.As(err, &netErr):
+		return exitUnreached
+	}
+	return exitFailure
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/timolinn/dns/pkg/client"
+)
+
+// printer writes results as a table or as one JSON object per line
+type printer struct {
+	json bool
+	enc  *json.Encoder
+	tw   *tabwriter.Writer
+}
+
+func newPrinter(w io.Writer, format string) *printer {
+	return &printer{
+		json: format == "json",
+		enc:  json.NewEncoder(w),
+		tw:   tabwriter.NewWriter(w, 0, 4, 2, ' ', 0),
+	}
+}
+
+// result is the outcome of locating one request
+type result struct {
+	Line     int      `json:"line,omitempty"`
+	System   string   `json:"system"`
+	X        float64  `json:"x"`
+	Y        float64  `json:"y"`
+	Z        float64  `json:"z"`
+	Vel      float64  `json:"vel"`
+	Location *float64 `json:"location,omitempty"`
+	Error    string   `json:"error,omitempty"`
+
+	code int
+}
+
+func (p *printer) results(results []result) {
+	if p.json {
+		for _, r := range results {
+			p.enc.Encode(r)
+		}
+		return
+	}
+	fmt.Fprintln(p.tw, "LINE\tSYSTEM\tX\tY\tZ\tVEL\tLOCATION")
+	for _, r := range results {
+		loc := r.Error
+		if r.Location != nil {
+			loc = strconv.FormatFloat(*r.Location, 'f', -1, 64)
+		}
+		line := "-"
+		if r.Line > 0 {
+			line = strconv.Itoa(r.Line)
+		}
+		fmt.Fprintf(p.tw, "%s\t%s\t%v\t%v\t%v\t%v\t%s\n", line, r.System, r.X, r.Y, r.Z, r.Vel, loc)
+	}
+	p.tw.Flush()
+}
+
+// solve locates the request of r, recording the outcome in r
+func solve(c *client.Client, r *result) {
+	cv := client.CoordsVelocity{X: r.X, Y: r.Y, Z: r.Z, Vel: r.Vel}
+	loc, err := c.Locate(context.Background(), client.System(r.System), cv)
+	if err != nil {
+		r.Error, r.code = err.Error(), exitCode(err)
+		return
+	}
+	r.Location = &loc
+}
+
+// locate locates one request given as arguments
+func locate(c *client.Client, out *printer, args []string) int {
+	fs := flag.NewFlagSet("locate", flag.ContinueOnError)
+	system := fs.String("system", string(client.Drone), "system type: 'drone' or 'ship'")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 4 {
+		fmt.Fprintln(os.Stderr, "dnsctl: locate requires x, y, z and vel")
+		return exitUsage
+	}
+	var v [4]float64
+	for i, arg := range fs.Args() {
+		n, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dnsctl: invalid number %q\n", arg)
+			return exitUsage
+		}
+		v[i] = n
+	}
+
+	r := result{System: *system, X: v[0], Y: v[1], Z: v[2], Vel: v[3]}
+	solve(c, &r)
+	if r.code != exitOK {
+		fmt.Fprintln(os.Stderr, "dnsctl:", r.Error)
+		return r.code
+	}
+	out.results([]result{r})
+	return exitOK
+}
+
+// batch locates every request of a CSV or NDJSON file. The exit status
+// is the most severe of the failed requests.
+func batch(c *client.Client, out *printer, args []string) int {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	system := fs.String("system", string(client.Drone), "system type of the requests that do not name one")
+	format := fs.String("format", "", "input format: 'csv' or 'ndjson', guessed from the file extension")
+	concurrency := fs.Int("c", 4, "number of requests sent at once")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 || *concurrency < 1 {
+		fmt.Fprintln(os.Stderr, "dnsctl: batch requires one file, or - for stdin")
+		return exitUsage
+	}
+
+	in := os.Stdin
+	if path := fs.Arg(0); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "dnsctl:", err)
+			return exitUsage
+		}
+		defer f.Close()
+		in = f
+		if *format == "" && strings.EqualFold(filepath.Ext(path), ".csv") {
+			*format = "csv"
+		}
+	}
+
+	var results []result
+	var err error
+	switch *format {
+	case "csv":
+		results, err = readCSV(in, *system)
+	case "", "ndjson":
+		results, err = readNDJSON(in, *system)
+	default:
+		err = errors.Errorf("unknown format %q: requires 'csv' or 'ndjson'", *format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dnsctl:", err)
+		return exitUsage
+	}
+
+	var wg sync.WaitGroup
+	next := make(chan *result)
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range next {
+				solve(c, r)
+			}
+		}()
+	}
+	for i := range results {
+		next <- &results[i]
+	}
+	close(next)
+	wg.Wait()
+
+	out.results(results)
+	code := exitOK
+	for _, r := range results {
+		if severity(r.code) > severity(code) {
+			code = r.code
+		}
+	}
+	return code
+}
+
+// severity orders the exit codes of failed requests, a server that
+// cannot be reached being the most severe
+func severity(code int) int {
+	switch code {
+	case exitOK:
+		return 0
+	case exitRejected:
+		return 1
+	case exitFailure:
+		return 2
+	case exitServer:
+		return 3
+	case exitAuth:
+		return 4
+	}
+	return 5
+}
+
+// fields are the fields of a request, in the order of result
+var fields = []string{"x", "y", "z", "vel"}
+
+// coords returns the fields of r, in the order of fields
+func (r *result) coords() []*float64 {
+	return []*float64{&r.X, &r.Y, &r.Z, &r.Vel}
+}
+
+// readCSV reads requests from CSV with a header naming the x, y, z
+// and vel columns, and optionally a system column
+func readCSV(r io.Reader, system string) ([]result, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading CSV header")
+	}
+	col := make(map[string]int)
+	for i, name := range header {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range fields {
+		if _, ok := col[name]; !ok {
+			return nil, errors.Errorf("CSV header lacks the %s column", name)
+		}
+	}
+
+	var results []result
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		r := result{Line: line, System: system}
+		if i, ok := col["system"]; ok && record[i] != "" {
+			r.System = record[i]
+		}
+		for i, v := range r.coords() {
+			s := record[col[fields[i]]]
+			if *v, err = strconv.ParseFloat(strings.TrimSpace(s), 64); err != nil {
+				return nil, errors.Errorf("line %d: invalid %s %q", line, fields[i], s)
+			}
+		}
+		results = append(results, r)
+	}
+}
+
+// readNDJSON reads requests from one JSON object per line, the values
+// written as numbers or as strings like the API expects them
+func readNDJSON(r io.Reader, system string) ([]result, error) {
+	dec := json.NewDecoder(r)
+	var results []result
+	for line := 1; ; line++ {
+		var req struct {
+			X      json.Number `json:"x"`
+			Y      json.Number `json:"y"`
+			Z      json.Number `json:"z"`
+			Vel    json.Number `json:"vel"`
+			System string      `json:"system"`
+		}
+		err := dec.Decode(&req)
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "request %d", line)
+		}
+		res := result{Line: line, System: system}
+		if req.System != "" {
+			res.System = req.System
+		}
+		numbers := []json.Number{req.X, req.Y, req.Z, req.Vel}
+		for i, v := range res.coords() {
+			if *v, err = numbers[i].Float64(); err != nil {
+				return nil, errors.Errorf("request %d: invalid %s %q", line, fields[i], numbers[i])
+			}
+		}
+		results = append(results, res)
+	}
+}
+
+// checkHealth checks the liveness and readiness of the server
+func checkHealth(c *client.Client, out *printer, args []string) int {
+	ctx := context.Background()
+	if err := c.Live(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "dnsctl:", err)
+		return exitCode(err)
+	}
+	// a server that is not ready answers its report along with a 503
+	report, err := c.Ready(ctx)
+	if err != nil && (!errors.Is(err, client.ErrUnavailable) || report.Status == "") {
+		fmt.Fprintln(os.Stderr, "dnsctl:", err)
+		return exitCode(err)
+	}
+
+	if out.json {
+		out.enc.Encode(report)
+	} else {
+		fmt.Fprintf(out.tw, "READY\t%s\n", report.Status)
+		if len(report.Checks) > 0 {
+			fmt.Fprintln(out.tw, "CHECK\tSTATUS\tDURATION\tERROR")
+		}
+		for _, r := range report.Checks {
+			fmt.Fprintf(out.tw, "%s\t%s\t%.2fms\t%s\n", r.Name, r.Status, r.Duration, r.Error)
+		}
+		out.tw.Flush()
+	}
+	if !report.Ready() {
+		return exitServer
+	}
+	return exitOK
+}
+
+// sector prints the sector the server navigates, the databanks are
+// those of this sector
+func sector(c *client.Client, out *printer, args []string) int {
+	id, err := c.Sector(context.Background())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dnsctl:", err)
+		return exitCode(err)
+	}
+
+	if out.json {
+		out.enc.Encode(struct {
+			Sector float64 `json:"sector"`
+		}{id})
+		return exitOK
+	}
+	fmt.Fprintf(out.tw, "SECTOR\t%v\n", id)
+	out.tw.Flush()
+	return exitOK
+}
@@ -0,0 +1,132 @@
+// Command dnsctl calls a DNS server from the command line, to locate
+// databanks, batch-locate from files and check the server health.
+//
+//	dnsctl -server https://dns.example -api-key dns_... locate -system ship 123.12 456.56 789.89 20.0
+//	dnsctl batch -system drone coordinates.csv
+//	dnsctl -o json health
+//
+// The exit status tells why a command failed, see the exit codes below.
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/timolinn/dns/pkg/client"
+	"github.com/timolinn/dns/pkg/tlsutil"
+)
+
+// Exit codes
+const (
+	exitOK        = 0
+	exitFailure   = 1 // unexpected failure, eg. a malformed response
+	exitUsage     = 2 // invalid command line or input file
+	exitRejected  = 3 // the server rejected the request, 400 and 422
+	exitAuth      = 4 // missing or invalid credentials, 401 and 403
+	exitServer    = 5 // the server failed or is not ready, 5xx
+	exitUnreached = 6 // the server could not be reached
+)
+
+func main() {
+	fs := flag.NewFlagSet("dnsctl", flag.ContinueOnError)
+	server := fs.String("server", env("DNSCTL_SERVER", "http://localhost:8080"), "base URL of the DNS server, defaults to $DNSCTL_SERVER")
+	apiKey := fs.String("api-key", os.Getenv("DNSCTL_API_KEY"), "API key sent in X-API-Key, defaults to $DNSCTL_API_KEY")
+	token := fs.String("token", os.Getenv("DNSCTL_TOKEN"), "bearer token sent in Authorization, defaults to $DNSCTL_TOKEN")
+	cert := fs.String("cert", "", "path to the client certificate")
+	key := fs.String("key", "", "path to the client certificate key")
+	cacert := fs.String("cacert", "", "path to the CA bundle verifying the server")
+	insecure := fs.Bool("insecure", false, "skip verifying the server certificate")
+	timeout := fs.Duration("timeout", 10*time.Second, "sets the timeout of every request")
+	output := fs.String("o", "table", "output format: 'table' or 'json'")
+	fs.Usage = usage
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		os.Exit(exitUsage)
+	}
+	if fs.NArg() == 0 || (*output != "table" && *output != "json") {
+		usage()
+		os.Exit(exitUsage)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: *insecure}
+	if *cert != "" {
+		pair, err := tls.LoadX509KeyPair(*cert, *key)
+		if err != nil {
+			fail(err, exitUsage)
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+	if *cacert != "" {
+		pool, err := tlsutil.LoadCertPool(*cacert)
+		if err != nil {
+			fail(err, exitUsage)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	opts := []client.Option{client.WithHTTPClient(&http.Client{
+		Timeout:   *timeout,
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig},
+	})}
+	if *apiKey != "" {
+		opts = append(opts, client.WithAPIKey(*apiKey))
+	}
+	if *token != "" {
+		opts = append(opts, client.WithToken(*token))
+	}
+	c, err := client.New(*server, opts...)
+	if err != nil {
+		fail(err, exitUsage)
+	}
+	out := newPrinter(os.Stdout, *output)
+
+	var code int
+	args := fs.Args()
+	switch args[0] {
+	case "locate":
+		code = locate(c, out, args[1:])
+	case "batch":
+		code = batch(c, out, args[1:])
+	case "health":
+		code = checkHealth(c, out, args[1:])
+	case "sector":
+		code = sector(c, out, args[1:])
+	default:
+		usage()
+		code = exitUsage
+	}
+	os.Exit(code)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: dnsctl [flags] locate [-system drone|ship] x y z vel")
+	fmt.Fprintln(os.Stderr, "       dnsctl [flags] batch [-system drone|ship] [-format csv|ndjson] [-c n] <file|->")
+	fmt.Fprintln(os.Stderr, "       dnsctl [flags] health")
+	fmt.Fprintln(os.Stderr, "       dnsctl [flags] sector")
+	fmt.Fprintln(os.Stderr, "flags:")
+	fmt.Fprintln(os.Stderr, "  -server url      base URL of the DNS server, $DNSCTL_SERVER")
+	fmt.Fprintln(os.Stderr, "  -api-key key     API key, $DNSCTL_API_KEY")
+	fmt.Fprintln(os.Stderr, "  -token jwt       bearer token, $DNSCTL_TOKEN")
+	fmt.Fprintln(os.Stderr, "  -cert, -key      client certificate and key")
+	fmt.Fprintln(os.Stderr, "  -cacert path     CA bundle verifying the server, -insecure skips verifying")
+	fmt.Fprintln(os.Stderr, "  -timeout d       timeout of every request (10s)")
+	fmt.Fprintln(os.Stderr, "  -o table|json    output format (table)")
+	fmt.Fprintln(os.Stderr, "exit status: 0 ok, 1 failure, 2 usage, 3 rejected, 4 unauthorized, 5 server error, 6 unreachable")
+}
+
+// fail reports err and exits with code
+func fail(err error, code int) {
+	fmt.Fprintln(os.Stderr, "dnsctl:", err)
+	os.Exit(code)
+}
+
+// env returns the value of the environment variable name, or def
+func env(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/timolinn/dns/cmd/api/handlers"
+	"github.com/timolinn/dns/pkg/auth"
+	"github.com/timolinn/dns/pkg/client"
+	"github.com/timolinn/dns/pkg/health"
+)
+
+// newServer serves an App built by handlers.Register and returns its URL
+func newServer(t *testing.T, opts ...handlers.Option) string {
+	shutdown := make(chan os.Signal, 1)
+	logger := log.New(os.Stdout, "TEST : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	srv := httptest.NewServer(handlers.Register(shutdown, logger, opts...))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func dial(t *testing.T, url string, opts ...client.Option) *client.Client {
+	retry := client.RetryPolicy{Retries: 1, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	c, err := client.New(url, append([]client.Option{client.WithRetry(retry)}, opts...)...)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	return c
+}
+
+func TestReadCSV(t *testing.T) {
+	t.Run("should read the columns named by the header", func(t *testing.T) {
+		in := "vel, x, y, z, system\n20, 1, 2, 3,\n4, 5, 6, 7, ship\n"
+		results, err := readCSV(strings.NewReader(in), "drone")
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		want := []result{
+			{Line: 2, System: "drone", X: 1, Y: 2, Z: 3, Vel: 20},
+			{Line: 3, System: "ship", X: 5, Y: 6, Z: 7, Vel: 4},
+		}
+		if len(results) != len(want) {
+			t.Fatalf("Should read %d requests, got %d", len(want), len(results))
+		}
+		for i := range want {
+			if results[i] != want[i] {
+				t.Errorf("Should read %+v, got %+v", want[i], results[i])
+			}
+		}
+	})
+
+	for name, in := range map[string]string{
+		"a header lacking a column": "x,y,z\n1,2,3\n",
+		"an invalid number":         "x,y,z,vel\n1,2,three,4\n",
+		"an empty file":             "",
+	} {
+		t.Run("should reject "+name, func(t *testing.T) {
+			if _, err := readCSV(strings.NewReader(in), "drone"); err == nil {
+				t.Error("want an error")
+			}
+		})
+	}
+
+	t.Run("should name the line of an invalid request", func(t *testing.T) {
+		_, err := readCSV(strings.NewReader("x,y,z,vel\n1,2,3,4\n1,2,x,4\n"), "drone")
+		if err == nil || !strings.Contains(err.Error(), "line 3") {
+			t.Errorf("Should name line 3, got %v", err)
+		}
+	})
+}
+
+func TestReadNDJSON(t *testing.T) {
+	t.Run("should read numbers and strings", func(t *testing.T) {
+		in := `{"x": 1, "y": 2, "z": 3, "vel": 20}` + "\n" + `{"x": "5", "y": "6", "z": "7", "vel": "4", "system": "ship"}`
+		results, err := readNDJSON(strings.NewReader(in), "drone")
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		want := []result{
+			{Line: 1, System: "drone", X: 1, Y: 2, Z: 3, Vel: 20},
+			{Line: 2, System: "ship", X: 5, Y: 6, Z: 7, Vel: 4},
+		}
+		if len(results) != len(want) {
+			t.Fatalf("Should read %d requests, got %d", len(want), len(results))
+		}
+		for i := range want {
+			if results[i] != want[i] {
+				t.Errorf("Should read %+v, got %+v", want[i], results[i])
+			}
+		}
+	})
+
+	for name, in := range map[string]string{
+		"malformed JSON":     `{"x": 1,`,
+		"an invalid number":  `{"x": "one", "y": 2, "z": 3, "vel": 4}`,
+		"a missing velocity": `{"x": 1, "y": 2, "z": 3}`,
+	} {
+		t.Run("should reject "+name, func(t *testing.T) {
+			if _, err := readNDJSON(strings.NewReader(in), "drone"); err == nil {
+				t.Error("want an error")
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, exitOK},
+		{errors.New("malformed response"), exitFailure},
+		{&client.Error{StatusCode: http.StatusBadRequest}, exitRejected},
+		{&client.Error{StatusCode: http.StatusUnprocessableEntity}, exitRejected},
+		{&client.Error{StatusCode: http.StatusUnauthorized}, exitAuth},
+		{&client.Error{StatusCode: http.StatusForbidden}, exitAuth},
+		{&client.Error{StatusCode: http.StatusInternalServerError}, exitServer},
+		{errors.Wrap(&client.Error{StatusCode: http.StatusServiceUnavailable}, "locating"), exitServer},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, exitUnreached},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("Should exit with %d for %v, got %d", tt.want, tt.err, got)
+		}
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	// from the least to the most severe
+	codes := []int{exitOK, exitRejected, exitFailure, exitServer, exitAuth, exitUnreached}
+	for i := 1; i < len(codes); i++ {
+		if severity(codes[i-1]) >= severity(codes[i]) {
+			t.Errorf("Should rank %d above %d", codes[i], codes[i-1])
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	store := auth.NewKeyStore()
+	_, droneKey, err := store.Create("alpha", []string{"drone"}, false)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	url := newServer(t, handlers.WithKeyStore(store))
+
+	t.Run("should locate", func(t *testing.T) {
+		var out bytes.Buffer
+		c := dial(t, url, client.WithAPIKey(droneKey))
+		code := locate(c, newPrinter(&out, "json"), []string{"123.12", "456.56", "789.89", "20"})
+		if code != exitOK {
+			t.Fatalf("Should exit with %d, got %d", exitOK, code)
+		}
+		if !strings.Contains(out.String(), `"location":1389.57`) {
+			t.Errorf("Should print the location, got %s", out.String())
+		}
+	})
+
+	t.Run("should exit with the status of the failure", func(t *testing.T) {
+		var out bytes.Buffer
+		args := []string{"-system", "ship", "1", "2", "3", "4"}
+		if code := locate(dial(t, url), newPrinter(&out, "table"), args); code != exitAuth {
+			t.Errorf("Should exit with %d without credentials, got %d", exitAuth, code)
+		}
+		if code := locate(dial(t, url, client.WithAPIKey(droneKey)), newPrinter(&out, "table"), args); code != exitAuth {
+			t.Errorf("Should exit with %d for a system the key is not bound to, got %d", exitAuth, code)
+		}
+		if code := locate(dial(t, url), newPrinter(&out, "table"), []string{"1", "2"}); code != exitUsage {
+			t.Errorf("Should exit with %d, got %d", exitUsage, code)
+		}
+	})
+
+	t.Run("should exit with the most severe failure of a batch", func(t *testing.T) {
+		f, err := ioutil.TempFile("", "batch-*.csv")
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		defer os.Remove(f.Name())
+		f.WriteString("x,y,z,vel,system\n1,2,3,4,drone\n1,2,3,4,ship\n")
+		f.Close()
+
+		var out bytes.Buffer
+		c := dial(t, url, client.WithAPIKey(droneKey))
+		if code := batch(c, newPrinter(&out, "table"), []string{f.Name()}); code != exitAuth {
+			t.Errorf("Should exit with %d, got %d", exitAuth, code)
+		}
+		if lines := strings.Count(out.String(), "\n"); lines != 3 {
+			t.Errorf("Should print a header and 2 results, got %s", out.String())
+		}
+	})
+}
+
+func TestCheckHealth(t *testing.T) {
+	t.Run("should exit with 0 when ready", func(t *testing.T) {
+		var out bytes.Buffer
+		c := dial(t, newServer(t, handlers.WithHealth(health.New())))
+		if code := checkHealth(c, newPrinter(&out, "table"), nil); code != exitOK {
+			t.Errorf("Should exit with %d, got %d", exitOK, code)
+		}
+		if !strings.HasPrefix(out.String(), "READY") {
+			t.Errorf("Should print the report, got %s", out.String())
+		}
+	})
+
+	t.Run("should exit with 5 when not ready", func(t *testing.T) {
+		checker := health.New()
+		checker.Register("storage", func(context.Context) error { return errors.New("read-only") })
+		var out bytes.Buffer
+		c := dial(t, newServer(t, handlers.WithHealth(checker)))
+		if code := checkHealth(c, newPrinter(&out, "json"), nil); code != exitServer {
+			t.Errorf("Should exit with %d, got %d", exitServer, code)
+		}
+		if !strings.Contains(out.String(), "read-only") {
+			t.Errorf("Should print the failed check, got %s", out.String())
+		}
+	})
+
+	t.Run("should exit with 5 when draining", func(t *testing.T) {
+		checker := health.New()
+		checker.Drain()
+		var out bytes.Buffer
+		c := dial(t, newServer(t, handlers.WithHealth(checker)))
+		if code := checkHealth(c, newPrinter(&out, "table"), nil); code != exitServer {
+			t.Errorf("Should exit with %d, got %d", exitServer, code)
+		}
+	})
+
+	t.Run("should exit with 6 when unreachable", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		srv.Close()
+		var out bytes.Buffer
+		if code := checkHealth(dial(t, srv.URL), newPrinter(&out, "table"), nil); code != exitUnreached {
+			t.Errorf("Should exit with %d, got %d", exitUnreached, code)
+		}
+	})
+}
+
+func TestSector(t *testing.T) {
+	url := newServer(t, handlers.WithLive(handlers.NewLive(handlers.Settings{Sector: 2})))
+
+	t.Run("should print the sector as a table", func(t *testing.T) {
+		var out bytes.Buffer
+		if code := sector(dial(t, url), newPrinter(&out, "table"), nil); code != exitOK {
+			t.Fatalf("Should exit with %d, got %d", exitOK, code)
+		}
+		if fields := strings.Fields(out.String()); len(fields) != 2 || fields[0] != "SECTOR" || fields[1] != "2" {
+			t.Errorf("Should print sector 2, got %s", out.String())
+		}
+	})
+
+	t.Run("should print the sector as JSON", func(t *testing.T) {
+		var out bytes.Buffer
+		if code := sector(dial(t, url), newPrinter(&out, "json"), nil); code != exitOK {
+			t.Fatalf("Should exit with %d, got %d", exitOK, code)
+		}
+		if out.String() != `{"sector":2}`+"\n" {
+			t.Errorf("Should print sector 2, got %s", out.String())
+		}
+	})
+
+	t.Run("should exit with 6 when unreachable", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		srv.Close()
+		var out bytes.Buffer
+		if code := sector(dial(t, srv.URL), newPrinter(&out, "table"), nil); code != exitUnreached {
+			t.Errorf("Should exit with %d, got %d", exitUnreached, code)
+		}
+	})
+}
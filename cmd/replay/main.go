@@ -0,0 +1,182 @@
+// Command replay replays traffic captured by the DNS server with -record
+// and reports the responses whose status or body changed, to prove that
+// a new build still gives the answers the old one gave.
+//
+//	replay -file capture.jsonl -config dns.yaml
+//	replay -file capture.jsonl -target https://dns.example -H 'X-API-Key: dns_...'
+//
+// Without -target the capture is replayed against an in-process App
+// built by handlers.Register, navigating the sector and system types of
+// the server configuration given with -config and DNS_* variables.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/timolinn/dns/cmd/api/handlers"
+	"github.com/timolinn/dns/pkg/capture"
+	"github.com/timolinn/dns/pkg/config"
+)
+
+// headers collects repeated -H flags
+type headers http.Header
+
+func (h headers) String() string {
+	return ""
+}
+
+func (h headers) Set(s string) error {
+	kv := strings.SplitN(s, ":", 2)
+	if len(kv) != 2 {
+		return errors.Errorf("invalid header %q: requires 'Name: value'", s)
+	}
+	http.Header(h).Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	return nil
+}
+
+func main() {
+	file := flag.String("file", "capture.jsonl", "path to the capture to replay")
+	target := flag.String("target", "", "base URL of the server to replay against, replays in-process when empty")
+	timeout := flag.Duration("timeout", 10*time.Second, "sets the timeout of every replayed request")
+	verbose := flag.Bool("v", false, "also report the exchanges that match")
+	path := flag.String("config", os.Getenv(config.EnvConfig), "path to the configuration of the server that recorded the capture, for in-process replays")
+	extra := headers{}
+	flag.Var(extra, "H", "header added to every replayed request eg. 'X-API-Key: dns_...', may be repeated")
+	flag.Parse()
+
+	send := remote(*target, *timeout)
+	if *target == "" {
+		cfg, err := load(*path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "replay:", err)
+			os.Exit(2)
+		}
+		send = inProcess(handlers.WithLive(handlers.NewLive(settings(cfg))))
+	}
+
+	diffs, err := run(*file, *target, send, http.Header(extra), *verbose, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "replay:", err)
+		os.Exit(2)
+	}
+	if diffs > 0 {
+		os.Exit(1)
+	}
+}
+
+// load returns the configuration of the server at path, overridden by
+// the DNS_* variables like the server does. The defaults are returned
+// when path is empty.
+func load(path string) (config.Config, error) {
+	cfg := config.Default()
+	if path != "" {
+		if err := config.LoadFile(&cfg, path); err != nil {
+			return cfg, err
+		}
+	}
+	if err := config.LoadEnv(&cfg, os.Environ()); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Validate()
+}
+
+// settings returns the navigation settings of cfg
+func settings(cfg config.Config) handlers.Settings {
+	s := handlers.Settings{Sector: cfg.Navigation.Sector}
+	for _, system := range cfg.Navigation.Systems {
+		s.Systems = append(s.Systems, handlers.System(system))
+	}
+	return s
+}
+
+// run replays the capture with send, reporting to out, and returns how
+// many responses differ
+func run(file, target string, send sender, extra http.Header, verbose bool, out io.Writer) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, errors.Wrap(err, "opening capture")
+	}
+	defer f.Close()
+
+	var replayed, diffs int
+	err = capture.Read(f, func(e *capture.Exchange) error {
+		replayed++
+		r, err := e.NewRequest(target)
+		if err != nil {
+			return err
+		}
+		for k, vv := range extra {
+			r.Header[k] = vv
+		}
+
+		status, body, err := send(r)
+		if err != nil {
+			return errors.Wrapf(err, "replaying exchange %d", replayed)
+		}
+
+		d := capture.Compare(e.Response, status, body)
+		switch {
+		case d != nil:
+			diffs++
+			fmt.Fprintf(out, "DIFF #%d %s %s\n", replayed, e.Request.Method, e.Request.URI)
+			if d.WantStatus != d.GotStatus {
+				fmt.Fprintf(out, "  status: want %d, got %d\n", d.WantStatus, d.GotStatus)
+			}
+			fmt.Fprintf(out, "  want: %s\n", strings.TrimSpace(string(d.WantBody)))
+			fmt.Fprintf(out, "  got:  %s\n", strings.TrimSpace(string(d.GotBody)))
+		case verbose:
+			fmt.Fprintf(out, "OK   #%d %s %s\n", replayed, e.Request.Method, e.Request.URI)
+		}
+		return nil
+	})
+	if err != nil {
+		return diffs, err
+	}
+
+	fmt.Fprintf(out, "replayed %d exchanges, %d differ\n", replayed, diffs)
+	return diffs, nil
+}
+
+// sender sends a replayed request and returns the response status and body
+type sender func(r *http.Request) (int, []byte, error)
+
+// remote sends requests to a running server
+func remote(target string, timeout time.Duration) sender {
+	client := http.Client{Timeout: timeout}
+	return func(r *http.Request) (int, []byte, error) {
+		resp, err := client.Do(r)
+		if err != nil {
+			return 0, nil, err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, body, err
+	}
+}
+
+// inProcess serves requests with the App of this build, built with opts
+func inProcess(opts ...handlers.Option) sender {
+	shutdown := make(chan os.Signal, 1)
+	go func() {
+		for range shutdown {
+			log.Println("replay: a handler failed and asked the App to shut down")
+		}
+	}()
+	app := handlers.Register(shutdown, log.New(ioutil.Discard, "", 0), opts...)
+
+	return func(r *http.Request) (int, []byte, error) {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+		return w.Code, w.Body.Bytes(), nil
+	}
+}
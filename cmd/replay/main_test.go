@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/timolinn/dns/cmd/api/handlers"
+)
+
+// record captures locate requests of both system types served by an App
+// navigating sector 2 for drones only, and returns the capture path
+func record(t *testing.T, dir string) string {
+	var buf bytes.Buffer
+	live := handlers.NewLive(handlers.Settings{Sector: 2, Systems: []handlers.System{handlers.Drone}})
+	app := handlers.Register(make(chan os.Signal, 1), log.New(ioutil.Discard, "", 0), handlers.WithLive(live), handlers.WithRecording(&buf))
+	for _, system := range []string{"drone", "ship"} {
+		r := httptest.NewRequest(http.MethodPost, "/v1/locate", strings.NewReader(`{"x":"1","y":"2","z":"3","vel":"4"}`))
+		r.Header.Set("X-System-Type", system)
+		app.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	path := filepath.Join(dir, "capture.jsonl")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	defer os.RemoveAll(dir)
+	capture := record(t, dir)
+
+	t.Run("should replay without diffs with the configuration of the server", func(t *testing.T) {
+		path := filepath.Join(dir, "dns.yaml")
+		if err := ioutil.WriteFile(path, []byte("navigation:\n  sector: 2\n  systems: [drone]\n"), 0600); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		cfg, err := load(path)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+
+		var out bytes.Buffer
+		diffs, err := run(capture, "", inProcess(handlers.WithLive(handlers.NewLive(settings(cfg)))), nil, false, &out)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if diffs != 0 {
+			t.Errorf("Should replay without diffs, got %s", out.String())
+		}
+		if !strings.Contains(out.String(), "replayed 2 exchanges, 0 differ") {
+			t.Errorf("Should report the replay, got %s", out.String())
+		}
+	})
+
+	t.Run("should report diffs with another configuration", func(t *testing.T) {
+		cfg, err := load("")
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+
+		var out bytes.Buffer
+		diffs, err := run(capture, "", inProcess(handlers.WithLive(handlers.NewLive(settings(cfg)))), nil, false, &out)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if diffs != 2 {
+			t.Errorf("Should report 2 diffs, got %s", out.String())
+		}
+	})
+
+	t.Run("should fail on a missing capture", func(t *testing.T) {
+		if _, err := run(filepath.Join(dir, "missing.jsonl"), "", inProcess(), nil, false, ioutil.Discard); err == nil {
+			t.Error("want an error")
+		}
+	})
+}
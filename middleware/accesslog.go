@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/timolinn/dns/pkg/buildinfo"
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// Access log formats
+const (
+	// FormatCommon is the NCSA Common Log Format.
+	FormatCommon = "common"
+	// FormatCombined is the Common Log Format with referer and user agent.
+	FormatCombined = "combined"
+	// FormatJSON writes one JSON object per request.
+	FormatJSON = "json"
+)
+
+// clfTime is the timestamp layout of the Common Log Format
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+// AccessEntry describes a served request
+type AccessEntry struct {
+	Time      time.Time `json:"time"`
+	Remote    string    `json:"remote"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	Duration  float64   `json:"duration_ms"`
+	Referer   string    `json:"referer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Version   string    `json:"version"`
+}
+
+// ValidAccessLogFormat reports an error for unknown access log formats
+func ValidAccessLogFormat(format string) error {
+	switch format {
+	case FormatCommon, FormatCombined, FormatJSON:
+		return nil
+	}
+	return errors.Errorf("unknown access log format %q: requires 'common', 'combined' or 'json'", format)
+}
+
+// AccessLog writes one line per request to out in the given format.
+// It is kept apart from the application log written by Logger.
+func AccessLog(out io.Writer, format string) web.Middleware {
+	mid := func(f web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			start := time.Now()
+			cw := countingWriter{ResponseWriter: w}
+			err := f(ctx, &cw, r)
+
+			host, _, splitErr := net.SplitHostPort(r.RemoteAddr)
+			if splitErr != nil {
+				host = r.RemoteAddr
+			}
+			if cw.status == 0 {
+				cw.status = http.StatusOK
+			}
+			e := AccessEntry{
+				Time:      start,
+				Remote:    host,
+				Method:    r.Method,
+				Path:      r.URL.RequestURI(),
+				Proto:     r.Proto,
+				Status:    cw.status,
+				Bytes:     cw.bytes,
+				Duration:  float64(time.Since(start)) / float64(time.Millisecond),
+				Referer:   r.Referer(),
+				UserAgent: r.UserAgent(),
+				Version:   buildinfo.Current(),
+			}
+
+			// a failing access log must not fail the request
+			out.Write(e.format(format))
+			return err
+		}
+		return h
+	}
+	return mid
+}
+
+func (e *AccessEntry) format(format string) []byte {
+	if format == FormatJSON {
+		line, _ := json.Marshal(e)
+		return append(line, '\n')
+	}
+
+	size := "-"
+	if e.Bytes > 0 {
+		size = strconv.FormatInt(e.Bytes, 10)
+	}
+	line := fmt.Sprintf("%s - - [%s] %q %d %s",
+		e.Remote, e.Time.Format(clfTime),
+		e.Method+" "+e.Path+" "+e.Proto,
+		e.Status, size,
+	)
+	if format == FormatCombined {
+		line += fmt.Sprintf(" %q %q", dash(e.Referer), dash(e.UserAgent))
+	}
+	return []byte(line + "\n")
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// countingWriter records the status and size of a response
+type countingWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (cw *countingWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	n, err := cw.ResponseWriter.Write(p)
+	cw.bytes += int64(n)
+	return n, err
+}
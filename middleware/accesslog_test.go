@@ -0,0 +1,54 @@
+package middleware_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/timolinn/dns/middleware"
+	"github.com/timolinn/dns/pkg/web"
+)
+
+func TestAccessLog(t *testing.T) {
+	serve := func(format string) string {
+		var out bytes.Buffer
+		app := web.NewApp(make(chan os.Signal, 1), middleware.AccessLog(&out, format))
+		app.MountHandler(http.MethodPost, "/v1/locate", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			return web.Respond(ctx, w, map[string]float64{"loc": 1389.57}, http.StatusOK)
+		})
+
+		r := httptest.NewRequest(http.MethodPost, "/v1/locate?trace=1", nil)
+		r.RemoteAddr = "10.0.0.7:51234"
+		r.Header.Set("User-Agent", "drone/1.0")
+		app.ServeHTTP(httptest.NewRecorder(), r)
+		return out.String()
+	}
+
+	common := `^10\.0\.0\.7 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "POST /v1/locate\?trace=1 HTTP/1\.1" 200 15\n$`
+	if line := serve(middleware.FormatCommon); !regexp.MustCompile(common).MatchString(line) {
+		t.Errorf("unexpected common log line %q", line)
+	}
+
+	combined := common[:len(common)-3] + ` "-" "drone/1\.0"\n$`
+	if line := serve(middleware.FormatCombined); !regexp.MustCompile(combined).MatchString(line) {
+		t.Errorf("unexpected combined log line %q", line)
+	}
+
+	var e middleware.AccessEntry
+	if err := json.Unmarshal([]byte(serve(middleware.FormatJSON)), &e); err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	if e.Remote != "10.0.0.7" || e.Method != http.MethodPost || e.Path != "/v1/locate?trace=1" ||
+		e.Status != http.StatusOK || e.Bytes != 15 || e.UserAgent != "drone/1.0" || e.Time.IsZero() || e.Version == "" {
+		t.Errorf("unexpected json entry %+v", e)
+	}
+
+	if err := middleware.ValidAccessLogFormat("apache"); err == nil {
+		t.Error("Should reject unknown formats")
+	}
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/timolinn/dns/pkg/auth"
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// Authenticate resolves the principal behind a request using the first
+// authenticator that recognises its credentials, and stores it in the
+// request context. Requests without valid credentials are rejected.
+func Authenticate(authenticators ...auth.Authenticator) web.Middleware {
+	mid := func(f web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			principal, err := auth.Authenticate(r, authenticators...)
+			if err != nil {
+				return web.RespondError(ctx, w, web.NewRequestError(err, http.StatusUnauthorized))
+			}
+
+			return f(auth.NewContext(ctx, principal), w, r)
+		}
+		return h
+	}
+	return mid
+}
+
+// RequireScopes rejects requests whose principal was not granted every
+// one of scopes, it must be mounted after Authenticate. The "{system}"
+// placeholder is replaced by the X-System-Type header, so "locate:{system}"
+// lets a drone key locate drones but not ships.
+func RequireScopes(scopes ...string) web.Middleware {
+	mid := func(f web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			p, ok := auth.FromContext(ctx)
+			if !ok {
+				return web.RespondError(ctx, w, web.NewRequestError(auth.ErrNoCredentials, http.StatusUnauthorized))
+			}
+
+			system := r.Header.Get("X-System-Type")
+			for _, scope := range scopes {
+				scope = strings.Replace(scope, "{system}", system, -1)
+				if !p.HasScope(scope) {
+					return web.RespondError(ctx, w, web.NewRequestError(auth.ErrForbidden, http.StatusForbidden))
+				}
+			}
+			return f(ctx, w, r)
+		}
+		return h
+	}
+	return mid
+}
+
+// BindSystem cross-checks the self-declared X-System-Type header against
+// the system types bound to the principal, it must be mounted after
+// Authenticate. Requests without the header take the principal's system
+// when it is bound to exactly one. With override set the principal's
+// system replaces a mismatching header instead of rejecting the request.
+func BindSystem(override bool) web.Middleware {
+	mid := func(f web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			p, ok := auth.FromContext(ctx)
+			if !ok {
+				return f(ctx, w, r)
+			}
+
+			system, err := p.BindSystem(r.Header.Get("X-System-Type"), override)
+			if err != nil {
+				return web.RespondError(ctx, w, web.NewRequestError(err, http.StatusForbidden))
+			}
+			if system != "" {
+				r.Header.Set("X-System-Type", system)
+			}
+			return f(ctx, w, r)
+		}
+		return h
+	}
+	return mid
+}
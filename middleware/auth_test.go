@@ -0,0 +1,80 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/timolinn/dns/middleware"
+	"github.com/timolinn/dns/pkg/auth"
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// principals authenticates the X-Principal header as one of its principals
+type principals map[string]*auth.Principal
+
+func (p principals) Authenticate(r *http.Request) (*auth.Principal, error) {
+	id := r.Header.Get("X-Principal")
+	if id == "" {
+		return nil, auth.ErrNoCredentials
+	}
+	if principal, ok := p[id]; ok {
+		return principal, nil
+	}
+	return nil, auth.ErrInvalidCredentials
+}
+
+func TestBindSystem(t *testing.T) {
+	authenticator := principals{
+		"drone-7":  {ID: "drone-7", Systems: []string{"drone"}},
+		"tender-1": {ID: "tender-1", Systems: []string{"drone", "ship"}},
+	}
+
+	// echo answers the system type the handler was given
+	echo := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Respond(ctx, w, map[string]string{"system": r.Header.Get("X-System-Type")}, http.StatusOK)
+	}
+
+	cases := []struct {
+		name      string
+		override  bool
+		principal string
+		declared  string
+		status    int
+		system    string
+	}{
+		{"should reject requests without credentials", false, "", "drone", http.StatusUnauthorized, ""},
+		{"should reject unknown credentials", false, "drone-9", "drone", http.StatusUnauthorized, ""},
+		{"should pass a matching system", false, "drone-7", "drone", http.StatusOK, "drone"},
+		{"should fill in the bound system", false, "drone-7", "", http.StatusOK, "drone"},
+		{"should reject a mismatching system", false, "drone-7", "ship", http.StatusForbidden, ""},
+		{"should override a mismatching system", true, "drone-7", "ship", http.StatusOK, "drone"},
+		{"should pass one of several bound systems", false, "tender-1", "ship", http.StatusOK, "ship"},
+		{"should reject a system bound to none of several", true, "tender-1", "ultradrone", http.StatusForbidden, ""},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			app := web.NewApp(make(chan os.Signal, 1))
+			app.MountHandler(http.MethodGet, "/", echo, middleware.Authenticate(authenticator), middleware.BindSystem(test.override))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.principal != "" {
+				r.Header.Set("X-Principal", test.principal)
+			}
+			if test.declared != "" {
+				r.Header.Set("X-System-Type", test.declared)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("Should receive status code %d, got %d", test.status, w.Code)
+			}
+			if test.system != "" && w.Body.String() != `{"system":"`+test.system+`"}` {
+				t.Errorf("Should bind %s, got %s", test.system, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// Encoder is a content coding responses can be compressed with
+type Encoder struct {
+	// Name is the content coding token, eg. "gzip".
+	Name string
+	// New wraps w in a compressing writer.
+	New func(w io.Writer) io.WriteCloser
+}
+
+// Gzip and Deflate are the content codings supported out of the box,
+// other codings such as brotli can be plugged in as extra Encoders.
+var (
+	Gzip = Encoder{
+		Name: "gzip",
+		New:  func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+	}
+	Deflate = Encoder{
+		Name: "deflate",
+		New:  func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+	}
+)
+
+// Compress compresses responses of at least minSize bytes with the
+// encoder preferred by the Accept-Encoding request header. Encoders are
+// listed in server preference order, which breaks ties between codings
+// the client accepts equally. It defaults to gzip and deflate.
+func Compress(minSize int, encoders ...Encoder) web.Middleware {
+	if len(encoders) == 0 {
+		encoders = []Encoder{Gzip, Deflate}
+	}
+
+	mid := func(f web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			enc, ok := negotiate(r.Header.Get("Accept-Encoding"), encoders)
+			if !ok || r.Method == http.MethodHead {
+				return f(ctx, w, r)
+			}
+
+			cw := compressWriter{ResponseWriter: w, encoder: enc, minSize: minSize}
+			err := f(ctx, &cw, r)
+			if cerr := cw.Close(); err == nil {
+				err = cerr
+			}
+			return err
+		}
+		return h
+	}
+	return mid
+}
+
+// negotiate picks the accepted encoder with the highest quality value
+func negotiate(accept string, encoders []Encoder) (Encoder, bool) {
+	if accept == "" {
+		return Encoder{}, false
+	}
+
+	q := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		weight := 1.0
+		for _, p := range fields[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if v, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					weight = v
+				}
+			}
+		}
+		q[name] = weight
+	}
+
+	var best Encoder
+	bestQ := 0.0
+	for _, enc := range encoders {
+		weight, ok := q[enc.Name]
+		if !ok {
+			weight, ok = q["*"]
+		}
+		if ok && weight > bestQ {
+			best, bestQ = enc, weight
+		}
+	}
+	return best, bestQ > 0
+}
+
+// compressWriter buffers the response until it reaches minSize bytes,
+// then switches to compressing it. Smaller responses are sent as is.
+type compressWriter struct {
+	http.ResponseWriter
+	encoder Encoder
+	minSize int
+
+	status int
+	buf    bytes.Buffer
+	enc    io.WriteCloser
+	done   bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	if cw.done {
+		return cw.ResponseWriter.Write(p)
+	}
+
+	cw.buf.Write(p)
+	if cw.buf.Len() < cw.minSize {
+		return len(p), nil
+	}
+	if err := cw.start(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// start writes the header and the buffered bytes, compressing
+// them when the response may carry a compressed body.
+func (cw *compressWriter) start() error {
+	cw.done = true
+	h := cw.Header()
+	if h.Get("Content-Encoding") == "" && bodyAllowed(cw.status) {
+		h.Set("Content-Encoding", cw.encoder.Name)
+		h.Del("Content-Length")
+		cw.enc = cw.encoder.New(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+
+	var err error
+	if cw.enc != nil {
+		_, err = cw.enc.Write(cw.buf.Bytes())
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf.Bytes())
+	}
+	cw.buf.Reset()
+	return err
+}
+
+// Close flushes responses that never reached minSize and terminates
+// the compressed stream of those that did.
+func (cw *compressWriter) Close() error {
+	if cw.enc != nil {
+		return cw.enc.Close()
+	}
+	if cw.done || cw.status == 0 {
+		return nil
+	}
+
+	cw.done = true
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+	_, err := cw.ResponseWriter.Write(cw.buf.Bytes())
+	return err
+}
+
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// CORSConfig configures which browser origins may call DNS
+type CORSConfig struct {
+	// AllowedOrigins lists the allowed origins. "*" allows any origin and
+	// a single wildcard matches subdomains, eg. "https://*.fleet.example".
+	AllowedOrigins []string
+	// AllowedMethods lists the methods preflight requests may ask for.
+	AllowedMethods []string
+	// AllowedHeaders lists the request headers preflight requests may ask for.
+	AllowedHeaders []string
+	// ExposedHeaders lists the response headers browsers may read.
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and authorization headers.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache preflight responses.
+	MaxAge time.Duration
+}
+
+// DefaultCORSConfig allows the methods and headers used by the DNS API
+var DefaultCORSConfig = CORSConfig{
+	AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodDelete},
+	AllowedHeaders: []string{"Content-Type", "X-System-Type", "X-API-Key", "Authorization"},
+	MaxAge:         10 * time.Minute,
+}
+
+// CORS sets the CORS response headers for allowed origins and answers
+// preflight requests, it must be mounted as an application middleware so
+// that it sees the OPTIONS requests web.App answers for every route.
+func CORS(cfg CORSConfig) web.Middleware {
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(cfg.MaxAge / time.Second))
+
+	allowedHeaders := make(map[string]bool)
+	for _, h := range cfg.AllowedHeaders {
+		allowedHeaders[http.CanonicalHeaderKey(h)] = true
+	}
+
+	mid := func(f web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			w.Header().Add("Vary", "Origin")
+			if origin == "" || !cfg.allowsOrigin(origin) {
+				return f(ctx, w, r)
+			}
+
+			// credentials can not be combined with a wildcard
+			// origin, the request origin is echoed instead
+			if cfg.allowsAnyOrigin() && !cfg.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if cfg.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if exposed != "" {
+					w.Header().Set("Access-Control-Expose-Headers", exposed)
+				}
+				return f(ctx, w, r)
+			}
+
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+			if !contains(cfg.AllowedMethods, r.Header.Get("Access-Control-Request-Method")) {
+				return web.Respond(ctx, w, nil, http.StatusNoContent)
+			}
+			requested := r.Header.Get("Access-Control-Request-Headers")
+			for _, h := range strings.Split(requested, ",") {
+				h = strings.TrimSpace(h)
+				if h != "" && !allowedHeaders[http.CanonicalHeaderKey(h)] {
+					return web.Respond(ctx, w, nil, http.StatusNoContent)
+				}
+			}
+
+			w.Header().Set("Access-Control-Allow-Methods", methods)
+			if requested != "" {
+				w.Header().Set("Access-Control-Allow-Headers", requested)
+			}
+			if cfg.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", maxAge)
+			}
+			return web.Respond(ctx, w, nil, http.StatusNoContent)
+		}
+		return h
+	}
+	return mid
+}
+
+func (c *CORSConfig) allowsAnyOrigin() bool {
+	return contains(c.AllowedOrigins, "*")
+}
+
+func (c *CORSConfig) allowsOrigin(origin string) bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" || o == origin {
+			return true
+		}
+		if i := strings.Index(o, "*"); i >= 0 {
+			prefix, suffix := o[:i], o[i+1:]
+			if len(origin) >= len(prefix)+len(suffix) &&
+				strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
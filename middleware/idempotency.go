@@ -0,0 +1,324 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/timolinn/dns/pkg/auth"
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// HeaderIdempotencyKey is the request header carrying idempotency keys
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+var (
+	// ErrIdempotencyKeyReused is reported when a key is sent again
+	// with a different payload.
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different payload")
+
+	// ErrIdempotencyInProgress is reported while the first request
+	// sent with a key is still being processed.
+	ErrIdempotencyInProgress = errors.New("a request with this idempotency key is in progress")
+
+	// ErrIdempotencyKeyInvalid is reported for keys over 255 characters.
+	ErrIdempotencyKeyInvalid = errors.New("idempotency key must not exceed 255 characters")
+
+	// ErrIdempotencyBodyTooLarge is reported for requests carrying a key
+	// and a body over web.MaxDecodedBodySize, which can not be fingerprinted.
+	ErrIdempotencyBodyTooLarge = errors.New("request body too large for an idempotency key")
+
+	// ErrIdempotencyStoreFull is reported when a store has no room left
+	// for another key.
+	ErrIdempotencyStoreFull = errors.New("too many idempotency keys in progress")
+)
+
+// IdempotentResponse is the response stored for an idempotency key
+type IdempotentResponse struct {
+	Fingerprint string
+	Status      int
+	Header      http.Header
+	Body        []byte
+}
+
+// IdempotencyStore keeps the first response sent for each key.
+type IdempotencyStore interface {
+	// Begin reserves key for a request with the given fingerprint. It
+	// returns the stored response when the key was already completed,
+	// ErrIdempotencyInProgress while it is reserved by another request
+	// and nil when the reservation succeeded.
+	Begin(key, fingerprint string) (*IdempotentResponse, error)
+	// Complete stores the response for a reserved key for ttl.
+	Complete(key string, resp IdempotentResponse, ttl time.Duration) error
+	// Abandon releases a reserved key without storing a response,
+	// so that the request may be retried.
+	Abandon(key string) error
+}
+
+// Idempotency replays the first response sent for an Idempotency-Key
+// to repeated requests carrying the same key, for ttl. Keys are scoped
+// to the authenticated principal. Only the headers set by the handler
+// are replayed, those of outer middlewares like X-Request-ID or CORS
+// are set for the repeated request itself. Server errors are not stored
+// so that they can be retried. Store failures once the response was
+// sent are logged, as the request was served.
+func Idempotency(store IdempotencyStore, ttl time.Duration) web.Middleware {
+	mid := func(f web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			v, ok := ctx.Value(web.KeyValues).(*web.Values)
+			if !ok {
+				return errors.New("web value missing from context")
+			}
+
+			key := r.Header.Get(HeaderIdempotencyKey)
+			if key == "" {
+				return f(ctx, w, r)
+			}
+			if len(key) > 255 {
+				return web.RespondError(ctx, w, web.NewRequestError(ErrIdempotencyKeyInvalid, http.StatusBadRequest))
+			}
+			if p, ok := auth.FromContext(ctx); ok {
+				key = p.ID + ":" + key
+			} else {
+				key = ":" + key
+			}
+
+			body, err := ioutil.ReadAll(io.LimitReader(r.Body, web.MaxDecodedBodySize+1))
+			if err != nil {
+				return web.RespondError(ctx, w, web.NewRequestError(web.ErrMalformedRequestData, http.StatusBadRequest))
+			}
+			if int64(len(body)) > web.MaxDecodedBodySize {
+				return web.RespondError(ctx, w, web.NewRequestError(ErrIdempotencyBodyTooLarge, http.StatusRequestEntityTooLarge))
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			fingerprint := fingerprint(r, body)
+
+			stored, err := store.Begin(key, fingerprint)
+			switch {
+			case err == ErrIdempotencyInProgress:
+				return web.RespondError(ctx, w, web.NewRequestError(err, http.StatusConflict))
+			case err == ErrIdempotencyStoreFull:
+				return web.RespondError(ctx, w, web.NewRequestError(err, http.StatusServiceUnavailable))
+			case err != nil:
+				return web.RespondError(ctx, w, err)
+			case stored != nil && stored.Fingerprint != fingerprint:
+				return web.RespondError(ctx, w, web.NewRequestError(ErrIdempotencyKeyReused, http.StatusUnprocessableEntity))
+			case stored != nil:
+				v.StatusCode = stored.Status
+				for k, values := range stored.Header {
+					w.Header()[k] = values
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(stored.Status)
+				// a client gone mid-replay is no error of the server
+				w.Write(stored.Body)
+				return nil
+			}
+
+			before := w.Header().Clone()
+			rec := recorder{ResponseWriter: w}
+			err = f(ctx, &rec, r)
+			if err != nil || rec.status == 0 || rec.status >= http.StatusInternalServerError {
+				if err := store.Abandon(key); err != nil {
+					log.Printf("%s : idempotency : releasing key: %v", v.TraceID, err)
+				}
+				return err
+			}
+			err = store.Complete(key, IdempotentResponse{
+				Fingerprint: fingerprint,
+				Status:      rec.status,
+				Header:      changed(before, rec.header),
+				Body:        rec.body.Bytes(),
+			}, ttl)
+			if err != nil {
+				log.Printf("%s : idempotency : storing response: %v", v.TraceID, err)
+			}
+			return nil
+		}
+		return h
+	}
+	return mid
+}
+
+// fingerprint identifies the payload of a request
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	io.WriteString(h, r.Header.Get("X-System-Type")+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// changed returns the headers of after that are not in before or have
+// other values
+func changed(before, after http.Header) http.Header {
+	h := make(http.Header)
+	for k, values := range after {
+		if !equal(before[k], values) {
+			h[k] = values
+		}
+	}
+	return h
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// recorder passes a response through while keeping a copy of it
+type recorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+		rec.header = rec.Header().Clone()
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// MemoryIdempotencyStore is an in-memory IdempotencyStore holding a
+// bounded number of keys. Once full, the oldest completed responses are
+// evicted to make room, and keys are refused while every one held is in
+// progress. Keys reserved for longer than the lease are released, as the
+// request holding them was lost.
+type MemoryIdempotencyStore struct {
+	size  int
+	lease time.Duration
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	sweep   time.Time
+}
+
+type idempotencyEntry struct {
+	key     string
+	resp    *IdempotentResponse
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore constructs an empty MemoryIdempotencyStore
+// holding up to size keys, each reserved for up to lease
+func NewMemoryIdempotencyStore(size int, lease time.Duration) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		size:    size,
+		lease:   lease,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Begin implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Begin(key, fingerprint string) (*IdempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.expire(now)
+
+	if el, ok := s.entries[key]; ok {
+		e := el.Value.(*idempotencyEntry)
+		if now.Before(e.expires) {
+			if e.resp == nil {
+				return nil, ErrIdempotencyInProgress
+			}
+			return e.resp, nil
+		}
+		s.remove(el)
+	}
+	if len(s.entries) >= s.size && !s.evict() {
+		return nil, ErrIdempotencyStoreFull
+	}
+	s.entries[key] = s.order.PushBack(&idempotencyEntry{key: key, expires: now.Add(s.lease)})
+	return nil, nil
+}
+
+// Complete implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Complete(key string, resp IdempotentResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+	// completed entries expire in the order they complete
+	s.entries[key] = s.order.PushBack(&idempotencyEntry{key: key, resp: &resp, expires: time.Now().Add(ttl)})
+	return nil
+}
+
+// Abandon implements IdempotencyStore
+func (s *MemoryIdempotencyStore) Abandon(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+// Len returns the number of keys held
+func (s *MemoryIdempotencyStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// expire drops entries past their ttl or lease at most once a minute,
+// it must be called with s.mu held.
+func (s *MemoryIdempotencyStore) expire(now time.Time) {
+	if now.Before(s.sweep) {
+		return
+	}
+	s.sweep = now.Add(time.Minute)
+	for _, el := range s.entries {
+		if now.After(el.Value.(*idempotencyEntry).expires) {
+			s.remove(el)
+		}
+	}
+}
+
+// evict drops the oldest completed entry, it reports false when every
+// entry is in progress. It must be called with s.mu held.
+func (s *MemoryIdempotencyStore) evict() bool {
+	for el := s.order.Front(); el != nil; el = el.Next() {
+		if el.Value.(*idempotencyEntry).resp != nil {
+			s.remove(el)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryIdempotencyStore) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.entries, el.Value.(*idempotencyEntry).key)
+}
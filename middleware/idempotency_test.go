@@ -0,0 +1,68 @@
+package middleware_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/timolinn/dns/middleware"
+)
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	resp := middleware.IdempotentResponse{Fingerprint: "f", Status: 200}
+
+	t.Run("should evict the oldest completed responses once full", func(t *testing.T) {
+		store := middleware.NewMemoryIdempotencyStore(2, time.Minute)
+		for _, key := range []string{"a", "b"} {
+			if _, err := store.Begin(key, "f"); err != nil {
+				t.Fatalf("expected nil-err got %s", err)
+			}
+			store.Complete(key, resp, time.Hour)
+		}
+		if _, err := store.Begin("c", "f"); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if store.Len() != 2 {
+			t.Errorf("Should hold 2 keys, got %d", store.Len())
+		}
+		if stored, _ := store.Begin("b", "f"); stored == nil {
+			t.Error("Should keep the newest response")
+		}
+		if stored, err := store.Begin("a", "f"); stored != nil || err != nil {
+			t.Errorf("Should have evicted the oldest response, got %v %v", stored, err)
+		}
+	})
+
+	t.Run("should refuse keys while every key is in progress", func(t *testing.T) {
+		store := middleware.NewMemoryIdempotencyStore(1, time.Minute)
+		store.Begin("a", "f")
+		if _, err := store.Begin("b", "f"); err != middleware.ErrIdempotencyStoreFull {
+			t.Errorf("Should return ErrIdempotencyStoreFull, got %v", err)
+		}
+		store.Abandon("a")
+		if _, err := store.Begin("b", "f"); err != nil {
+			t.Errorf("expected nil-err got %s", err)
+		}
+	})
+
+	t.Run("should release keys held past the lease", func(t *testing.T) {
+		store := middleware.NewMemoryIdempotencyStore(10, 10*time.Millisecond)
+		store.Begin("a", "f")
+		if _, err := store.Begin("a", "f"); err != middleware.ErrIdempotencyInProgress {
+			t.Fatalf("Should return ErrIdempotencyInProgress, got %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if _, err := store.Begin("a", "f"); err != nil {
+			t.Errorf("Should release the key, got %v", err)
+		}
+	})
+
+	t.Run("should expire completed responses", func(t *testing.T) {
+		store := middleware.NewMemoryIdempotencyStore(10, time.Minute)
+		store.Begin("a", "f")
+		store.Complete("a", resp, 10*time.Millisecond)
+		time.Sleep(20 * time.Millisecond)
+		if stored, err := store.Begin("a", "f"); stored != nil || err != nil {
+			t.Errorf("Should forget the response, got %v %v", stored, err)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// Level is the verbosity of the request log written by Logger
+type Level int32
+
+// Log levels, from the most to the least verbose
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+// ParseLevel parses the name of a level
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, errors.Errorf("unknown log level %q: requires 'debug', 'info', 'warn' or 'error'", s)
+}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return "unknown"
+	}
+	return levelNames[l]
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (l *Level) UnmarshalText(text []byte) error {
+	parsed, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
+}
+
+// LevelVar is a Level that may be changed while requests are logged.
+// The zero value logs everything.
+type LevelVar struct {
+	level int32
+}
+
+// Level returns the current level
+func (lv *LevelVar) Level() Level {
+	return Level(atomic.LoadInt32(&lv.level))
+}
+
+// Set changes the level
+func (lv *LevelVar) Set(l Level) {
+	atomic.StoreInt32(&lv.level, int32(l))
+}
+
+// Enabled reports whether messages of level l are logged
+func (lv *LevelVar) Enabled(l Level) bool {
+	return lv == nil || l >= lv.Level()
+}
@@ -0,0 +1,266 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// ErrOverloaded is reported to requests shed by a Limiter
+var ErrOverloaded = errors.New("service overloaded, retry later")
+
+// limiters publishes the state of every Limiter under /debug/vars
+var limiters = expvar.NewMap("limiters")
+
+// LimiterConfig configures an adaptive concurrency Limiter
+type LimiterConfig struct {
+	// InitialLimit, MinLimit and MaxLimit bound the number of
+	// requests processed concurrently.
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+	// QueueSize is how many requests may wait for a slot, and
+	// QueueTimeout how long each of them may wait.
+	QueueSize    int
+	QueueTimeout time.Duration
+	// TargetLatency is the latency above which the limit is decreased.
+	TargetLatency time.Duration
+	// Backoff is the factor the limit is multiplied by on each decrease.
+	Backoff float64
+	// RetryAfter is advertised to shed requests.
+	RetryAfter time.Duration
+}
+
+// DefaultLimiterConfig is a conservative starting point
+var DefaultLimiterConfig = LimiterConfig{
+	InitialLimit:  20,
+	MinLimit:      2,
+	MaxLimit:      200,
+	QueueSize:     50,
+	QueueTimeout:  time.Second,
+	TargetLatency: 250 * time.Millisecond,
+	Backoff:       0.9,
+	RetryAfter:    time.Second,
+}
+
+// LimiterStats is a snapshot of the state of a Limiter
+type LimiterStats struct {
+	Name     string `json:"name"`
+	Limit    int    `json:"limit"`
+	InFlight int    `json:"in_flight"`
+	Queued   int    `json:"queued"`
+	Shed     int64  `json:"shed"`
+}
+
+// Limiter bounds the number of requests processed concurrently. The limit
+// adapts to observed latency: it grows additively while requests complete
+// under the target latency and shrinks multiplicatively when they do not
+// (AIMD). Requests over the limit wait in a bounded FIFO queue, and are
+// shed with a 503 when the queue is full or their wait times out.
+type Limiter struct {
+	name string
+	cfg  LimiterConfig
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	queue    []chan struct{}
+	shed     int64
+}
+
+// NewLimiter constructs a Limiter, its state is published
+// under name in the "limiters" expvar.
+func NewLimiter(name string, cfg LimiterConfig) *Limiter {
+	l := &Limiter{name: name}
+	l.configure(cfg)
+	l.limit = math.Max(float64(l.cfg.MinLimit), math.Min(float64(l.cfg.MaxLimit), float64(cfg.InitialLimit)))
+
+	limiters.Set(name, expvar.Func(func() interface{} { return l.Stats() }))
+	return l
+}
+
+// SetConfig replaces the configuration of the limiter, keeping its
+// current limit within the new bounds.
+func (l *Limiter) SetConfig(cfg LimiterConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.configure(cfg)
+	l.limit = math.Max(float64(l.cfg.MinLimit), math.Min(float64(l.cfg.MaxLimit), l.limit))
+	l.grant()
+}
+
+func (l *Limiter) configure(cfg LimiterConfig) {
+	if cfg.MinLimit < 1 {
+		cfg.MinLimit = 1
+	}
+	if cfg.MaxLimit < cfg.MinLimit {
+		cfg.MaxLimit = cfg.MinLimit
+	}
+	if cfg.Backoff <= 0 || cfg.Backoff >= 1 {
+		cfg.Backoff = DefaultLimiterConfig.Backoff
+	}
+	l.cfg = cfg
+}
+
+// Stats returns a snapshot of the limiter state
+func (l *Limiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return LimiterStats{
+		Name:     l.name,
+		Limit:    int(l.limit),
+		InFlight: l.inflight,
+		Queued:   len(l.queue),
+		Shed:     l.shed,
+	}
+}
+
+// Middleware returns the middleware enforcing the limit
+func (l *Limiter) Middleware() web.Middleware {
+	mid := func(f web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			v, ok := ctx.Value(web.KeyValues).(*web.Values)
+			if !ok {
+				return errors.New("web value missing from context")
+			}
+
+			if ok, retryAfter := l.acquire(ctx); !ok {
+				retry := int(math.Ceil(retryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(retry))
+				return web.RespondError(ctx, w, web.NewRequestError(ErrOverloaded, http.StatusServiceUnavailable))
+			}
+
+			// the slot is released when the handler panics too, as
+			// a failure, so that panics do not leak slots. A handler
+			// abandoned at its deadline keeps it, see Timeout.
+			start, failed := time.Now(), true
+			s := &slot{holders: 1, release: func() { l.release(time.Since(start), failed) }}
+			defer s.done()
+			err := f(context.WithValue(ctx, slotKey{}, s), w, r)
+			failed = err != nil || v.StatusCode >= http.StatusInternalServerError
+			return err
+		}
+		return h
+	}
+	return mid
+}
+
+// Acquire takes a slot for work that is not an HTTP request, like a gRPC
+// call, waiting in the queue when none is free. It returns ErrOverloaded
+// when the work is shed, otherwise the func releasing the slot once the
+// work is done, told whether it failed.
+func (l *Limiter) Acquire(ctx context.Context) (func(failed bool), error) {
+	if ok, _ := l.acquire(ctx); !ok {
+		return nil, ErrOverloaded
+	}
+	start := time.Now()
+	return func(failed bool) {
+		l.release(time.Since(start), failed)
+	}, nil
+}
+
+// slotKey is the context key of the slot held by a request
+type slotKey struct{}
+
+// slot is the limiter slot held by a request, released once every
+// holder is done with it
+type slot struct {
+	mu      sync.Mutex
+	holders int
+	release func()
+}
+
+// hold adds a holder of the slot of ctx, if any, and returns the func
+// to call once it is done
+func hold(ctx context.Context) func() {
+	s, ok := ctx.Value(slotKey{}).(*slot)
+	if !ok {
+		return func() {}
+	}
+	s.mu.Lock()
+	s.holders++
+	s.mu.Unlock()
+	return s.done
+}
+
+func (s *slot) done() {
+	s.mu.Lock()
+	s.holders--
+	last := s.holders == 0
+	s.mu.Unlock()
+	if last {
+		s.release()
+	}
+}
+
+// acquire takes a slot, waiting in the queue when none is free. It
+// reports false when the request is shed, along with when to retry.
+func (l *Limiter) acquire(ctx context.Context) (bool, time.Duration) {
+	l.mu.Lock()
+	retryAfter, wait := l.cfg.RetryAfter, l.cfg.QueueTimeout
+	if l.inflight < int(l.limit) && len(l.queue) == 0 {
+		l.inflight++
+		l.mu.Unlock()
+		return true, 0
+	}
+	if len(l.queue) >= l.cfg.QueueSize {
+		l.shed++
+		l.mu.Unlock()
+		return false, retryAfter
+	}
+	ready := make(chan struct{})
+	l.queue = append(l.queue, ready)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ready:
+		return true, 0
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, c := range l.queue {
+		if c == ready {
+			l.queue = append(l.queue[:i], l.queue[i+1:]...)
+			l.shed++
+			return false, retryAfter
+		}
+	}
+	// the slot was granted while giving up, keep it
+	return true, 0
+}
+
+// release frees a slot and adapts the limit to the observed latency
+func (l *Limiter) release(latency time.Duration, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inflight--
+	if failed || latency > l.cfg.TargetLatency {
+		l.limit = math.Max(float64(l.cfg.MinLimit), l.limit*l.cfg.Backoff)
+	} else {
+		l.limit = math.Min(float64(l.cfg.MaxLimit), l.limit+1/l.limit)
+	}
+	l.grant()
+}
+
+// grant hands free slots to queued requests, it must be called with l.mu held.
+func (l *Limiter) grant() {
+	for len(l.queue) > 0 && l.inflight < int(l.limit) {
+		l.inflight++
+		close(l.queue[0])
+		l.queue = l.queue[1:]
+	}
+}
@@ -0,0 +1,131 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/timolinn/dns/middleware"
+	"github.com/timolinn/dns/pkg/web"
+)
+
+func TestLimiter(t *testing.T) {
+	cfg := middleware.DefaultLimiterConfig
+	cfg.InitialLimit, cfg.MinLimit, cfg.MaxLimit = 1, 1, 1
+	cfg.QueueSize, cfg.QueueTimeout = 1, 50*time.Millisecond
+	limiter := middleware.NewLimiter("test", cfg)
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	slow := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		started <- struct{}{}
+		<-release
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+
+	app := web.NewApp(make(chan os.Signal, 1))
+	app.MountHandler(http.MethodGet, "/", slow, limiter.Middleware())
+
+	serve := func() *http.Response {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w.Result()
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serve()
+	}()
+	<-started
+
+	t.Run("should shed requests once the queue times out", func(t *testing.T) {
+		result := serve()
+		if result.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Should receive status code %d, got %d", http.StatusServiceUnavailable, result.StatusCode)
+		}
+		if result.Header.Get("Retry-After") != "1" {
+			t.Errorf("want Retry-After 1, got %q", result.Header.Get("Retry-After"))
+		}
+		if stats := limiter.Stats(); stats.InFlight != 1 || stats.Queued != 0 || stats.Shed != 1 {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("should serve queued requests once a slot frees", func(t *testing.T) {
+		cfg.QueueTimeout = 5 * time.Second
+		limiter.SetConfig(cfg)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if result := serve(); result.StatusCode != http.StatusNoContent {
+				t.Errorf("Should receive status code %d, got %d", http.StatusNoContent, result.StatusCode)
+			}
+		}()
+		for limiter.Stats().Queued == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+		wg.Wait()
+
+		if stats := limiter.Stats(); stats.InFlight != 0 || stats.Queued != 0 {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("should limit work acquiring slots", func(t *testing.T) {
+		done, err := limiter.Acquire(context.Background())
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		cfg.QueueSize, cfg.QueueTimeout = 0, 0
+		limiter.SetConfig(cfg)
+		if _, err := limiter.Acquire(context.Background()); err != middleware.ErrOverloaded {
+			t.Errorf("want %v, got %v", middleware.ErrOverloaded, err)
+		}
+		done(false)
+		if stats := limiter.Stats(); stats.InFlight != 0 {
+			t.Errorf("unexpected stats %+v", stats)
+		}
+	})
+}
+
+func TestLimiterPanics(t *testing.T) {
+	cfg := middleware.DefaultLimiterConfig
+	cfg.InitialLimit, cfg.MinLimit, cfg.MaxLimit = 2, 1, 2
+	cfg.QueueSize, cfg.QueueTimeout = 0, 0
+	limiter := middleware.NewLimiter("panics", cfg)
+
+	app := web.NewApp(make(chan os.Signal, 1))
+	app.MountHandler(http.MethodGet, "/panic", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		panic("navigator failed")
+	}, limiter.Middleware())
+	app.MountHandler(http.MethodGet, "/", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}, limiter.Middleware())
+
+	serve := func(path string) (w *httptest.ResponseRecorder, panicked bool) {
+		defer func() { panicked = recover() != nil }()
+		w = httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w, false
+	}
+
+	for i := 0; i < 5; i++ {
+		if w, panicked := serve("/panic"); !panicked {
+			t.Fatalf("Should admit request %d, got status code %d", i+1, w.Code)
+		}
+	}
+	if stats := limiter.Stats(); stats.InFlight != 0 || stats.Limit != 1 {
+		t.Errorf("Should release the slots of panicking handlers as failures, got %+v", stats)
+	}
+	if w, _ := serve("/"); w.Code != http.StatusNoContent {
+		t.Errorf("Should receive status code %d, got %d", http.StatusNoContent, w.Code)
+	}
+}
@@ -10,8 +10,10 @@ import (
 	"github.com/timolinn/dns/pkg/web"
 )
 
-// Logger logs arbitrary data
-func Logger(logger *log.Logger) web.Middleware {
+// Logger logs a line per request. Server errors are logged at the
+// error level, client errors at the warn level and other requests at
+// the info level; a nil level logs every request.
+func Logger(logger *log.Logger, level *LevelVar) web.Middleware {
 	// actual middleware
 	mid := func(f web.Handler) web.Handler {
 		// define web Handler
@@ -25,8 +27,19 @@ func Logger(logger *log.Logger) web.Middleware {
 
 			err := f(ctx, w, r)
 
+			l := LevelInfo
+			switch {
+			case v.StatusCode >= http.StatusInternalServerError:
+				l = LevelError
+			case v.StatusCode >= http.StatusBadRequest:
+				l = LevelWarn
+			}
+			if !level.Enabled(l) {
+				return err
+			}
+
 			logger.Printf("%s : (%d) : %s %s -> %s (%s)",
-				"unique-trace-id", // TODO: use uuids for unique ID for tracing
+				v.TraceID,
 				v.StatusCode,
 				r.Method, r.URL.Path,
 				r.RemoteAddr,
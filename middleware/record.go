@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/timolinn/dns/pkg/capture"
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// adminPrefix prefixes the routes left out of captures, their responses
+// carry secrets like the API keys created or rotated
+const adminPrefix = "/v1/admin/"
+
+// Record captures every request and the response sent back to out,
+// so that the traffic can be replayed against another build. Request
+// bodies are captured up to web.MaxDecodedBodySize bytes, credentials
+// are redacted and the admin routes are not captured.
+func Record(out *capture.Writer) web.Middleware {
+	mid := func(f web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if strings.HasPrefix(r.URL.Path, adminPrefix) {
+				return f(ctx, w, r)
+			}
+			start := time.Now()
+
+			var body []byte
+			if r.Body != nil {
+				var err error
+				body, err = ioutil.ReadAll(io.LimitReader(r.Body, web.MaxDecodedBodySize))
+				if err != nil {
+					return web.RespondError(ctx, w, web.NewRequestError(err, http.StatusBadRequest))
+				}
+				r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+			}
+
+			rec := recorder{ResponseWriter: w}
+			err := f(ctx, &rec, r)
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+				rec.header = w.Header().Clone()
+			}
+
+			// a failing capture must not fail the request
+			out.Write(capture.Exchange{
+				Time:     start,
+				Duration: float64(time.Since(start)) / float64(time.Millisecond),
+				Request: capture.Request{
+					Method: r.Method,
+					URI:    r.URL.RequestURI(),
+					Header: r.Header,
+					Body:   body,
+				},
+				Response: capture.Response{
+					Status: rec.status,
+					Header: rec.header,
+					Body:   rec.body.Bytes(),
+				},
+			})
+			return err
+		}
+		return h
+	}
+	return mid
+}
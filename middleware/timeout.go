@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// Timeout puts a deadline on the context passed to the handler. Handlers
+// and the computations they start are expected to give up once the
+// context is done, a handler that gave up without responding gets a 504
+// response in its place. A handler still running at the deadline is not
+// waited for: the 504 is sent right away and whatever the handler writes
+// or reads from the request body afterwards fails, so that it is not cut
+// off by the server's write timeout instead. It keeps the slot of its
+// Limiter until it returns, so that abandoned handlers still count
+// against the limit. A request canceled by the client gets a 503.
+func Timeout(d time.Duration) web.Middleware {
+	mid := func(f web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			v, ok := ctx.Value(web.KeyValues).(*web.Values)
+			if !ok {
+				return errors.New("web value missing from context")
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			// the handler gets its own values, a buffered writer and a
+			// guarded body, so that the timeout response can be sent
+			// while it still runs
+			hv := *v
+			tw := &timeoutWriter{header: w.Header().Clone()}
+			hr := *r
+			var body *timeoutBody
+			if r.Body != nil {
+				body = &timeoutBody{body: r.Body}
+				hr.Body = body
+			}
+			done := make(chan error, 1)
+			panicked := make(chan interface{}, 1)
+			finished := make(chan struct{})
+			go func() {
+				defer close(finished)
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+					}
+				}()
+				done <- f(context.WithValue(ctx, web.KeyValues, &hv), tw, &hr)
+			}()
+
+			select {
+			case p := <-panicked:
+				panic(p)
+
+			case err := <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				v.StatusCode = hv.StatusCode
+				if err != nil {
+					return err
+				}
+				if !tw.wroteHeader && ctx.Err() != nil {
+					return web.RespondError(ctx, w, ctx.Err())
+				}
+				tw.flush(w)
+				return nil
+
+			case <-ctx.Done():
+				tw.mu.Lock()
+				tw.timedOut = true
+				tw.mu.Unlock()
+				release := hold(ctx)
+				go func() {
+					<-finished
+					release()
+				}()
+
+				err := web.RespondError(ctx, w, ctx.Err())
+				if fl, ok := w.(http.Flusher); ok {
+					fl.Flush()
+				}
+				// the server takes the body back once this returns, a
+				// read in progress is waited for
+				if body != nil {
+					body.stop()
+				}
+				return err
+			}
+		}
+		return h
+	}
+	return mid
+}
+
+// timeoutBody is the request body of a handler running under a deadline,
+// reads made once it timed out fail
+type timeoutBody struct {
+	mu       sync.Mutex
+	body     io.ReadCloser
+	timedOut bool
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return b.body.Read(p)
+}
+
+func (b *timeoutBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timedOut {
+		return nil
+	}
+	return b.body.Close()
+}
+
+// stop fails the reads made from now on, once a read in progress ends
+func (b *timeoutBody) stop() {
+	b.mu.Lock()
+	b.timedOut = true
+	b.mu.Unlock()
+}
+
+// timeoutWriter buffers the response of a handler running under a
+// deadline until it returns, writes made once it timed out fail
+type timeoutWriter struct {
+	mu          sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	code        int
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.code, tw.wroteHeader = code, true
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.code, tw.wroteHeader = http.StatusOK, true
+	}
+	return tw.buf.Write(b)
+}
+
+// flush sends the buffered response to w, tw.mu must be held. A client
+// gone by then is no error of the handler, write errors are dropped.
+func (tw *timeoutWriter) flush(w http.ResponseWriter) {
+	dst := w.Header()
+	for k := range dst {
+		if _, ok := tw.header[k]; !ok {
+			delete(dst, k)
+		}
+	}
+	for k, vs := range tw.header {
+		dst[k] = vs
+	}
+	if !tw.wroteHeader {
+		return
+	}
+	w.WriteHeader(tw.code)
+	w.Write(tw.buf.Bytes())
+}
@@ -0,0 +1,135 @@
+package middleware_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timolinn/dns/middleware"
+	"github.com/timolinn/dns/pkg/web"
+)
+
+func TestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	late := make(chan error, 1)
+
+	app := web.NewApp(make(chan os.Signal, 1))
+	app.MountHandler(http.MethodGet, "/fast", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("X-Fleet", "alpha")
+		return web.Respond(ctx, w, map[string]string{"status": "ok"}, http.StatusCreated)
+	}, middleware.Timeout(time.Second))
+	app.MountHandler(http.MethodGet, "/stuck", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		// ignores its context, like a computation that never checks it
+		<-release
+		_, err := w.Write([]byte("too late"))
+		late <- err
+		return nil
+	}, middleware.Timeout(20*time.Millisecond))
+	app.MountHandler(http.MethodGet, "/giveup", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		<-ctx.Done()
+		return nil
+	}, middleware.Timeout(20*time.Millisecond))
+
+	serve := func(ctx context.Context, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx))
+		return w
+	}
+
+	t.Run("should pass responses in time through", func(t *testing.T) {
+		w := serve(context.Background(), "/fast")
+		if w.Code != http.StatusCreated || w.Body.String() != `{"status":"ok"}` {
+			t.Errorf("Should receive the handler response, got %d %s", w.Code, w.Body.String())
+		}
+		if w.Header().Get("X-Fleet") != "alpha" || w.Header().Get(web.HeaderRequestID) == "" {
+			t.Errorf("Should receive the handler and app headers, got %v", w.Header())
+		}
+	})
+
+	t.Run("should answer for a handler running past its deadline", func(t *testing.T) {
+		started := time.Now()
+		w := serve(context.Background(), "/stuck")
+		if w.Code != http.StatusGatewayTimeout {
+			t.Fatalf("Should receive status code %d, got %d", http.StatusGatewayTimeout, w.Code)
+		}
+		if elapsed := time.Since(started); elapsed > time.Second {
+			t.Errorf("Should not wait for the handler, took %v", elapsed)
+		}
+
+		release <- struct{}{}
+		if err := <-late; err != http.ErrHandlerTimeout {
+			t.Errorf("Should fail late writes with ErrHandlerTimeout, got %v", err)
+		}
+		if w.Body.String() != `{"error":"request timed out","fields":null}` {
+			t.Errorf("Should not send late writes, got %s", w.Body.String())
+		}
+	})
+
+	t.Run("should answer for a handler giving up without responding", func(t *testing.T) {
+		if w := serve(context.Background(), "/giveup"); w.Code != http.StatusGatewayTimeout {
+			t.Errorf("Should receive status code %d, got %d", http.StatusGatewayTimeout, w.Code)
+		}
+	})
+
+	t.Run("should answer 503 to canceled requests", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if w := serve(ctx, "/giveup"); w.Code != http.StatusServiceUnavailable {
+			t.Errorf("Should receive status code %d, got %d", http.StatusServiceUnavailable, w.Code)
+		}
+	})
+}
+
+func TestTimeoutAbandoned(t *testing.T) {
+	cfg := middleware.DefaultLimiterConfig
+	cfg.QueueSize, cfg.QueueTimeout = 0, 0
+	limiter := middleware.NewLimiter("abandoned", cfg)
+	release := make(chan struct{})
+	ended := make(chan struct{}, 1)
+	late := make(chan error, 1)
+
+	app := web.NewApp(make(chan os.Signal, 1))
+	app.MountHandler(http.MethodPost, "/stuck", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		defer func() { ended <- struct{}{} }()
+		<-release
+		_, err := ioutil.ReadAll(r.Body)
+		late <- err
+		return nil
+	}, limiter.Middleware(), middleware.Timeout(20*time.Millisecond))
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/stuck", strings.NewReader(`{"x":"1"}`)))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("Should receive status code %d, got %d", http.StatusGatewayTimeout, w.Code)
+	}
+
+	t.Run("should hold the limiter slot while the handler runs", func(t *testing.T) {
+		if stats := limiter.Stats(); stats.InFlight != 1 {
+			t.Errorf("Should count the abandoned handler, got %+v", stats)
+		}
+	})
+
+	t.Run("should fail late reads of the body", func(t *testing.T) {
+		close(release)
+		if err := <-late; err != http.ErrHandlerTimeout {
+			t.Errorf("Should fail late reads with ErrHandlerTimeout, got %v", err)
+		}
+	})
+
+	t.Run("should release the limiter slot once the handler ends", func(t *testing.T) {
+		<-ended
+		deadline := time.Now().Add(time.Second)
+		for limiter.Stats().InFlight != 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if stats := limiter.Stats(); stats.InFlight != 0 {
+			t.Errorf("Should release the slot, got %+v", stats)
+		}
+	})
+}
@@ -0,0 +1,388 @@
+// Package audit keeps an append-only, tamper evident trail of the
+// locations DNS handed out. Every record is chained to the previous one
+// by a SHA-256 checksum, so edited or deleted records are detected.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// genesis is the checksum the first record is chained to
+var genesis = hex.EncodeToString(make([]byte, sha256.Size))
+
+// ErrCorrupted is returned when the checksum chain of a log is broken
+var ErrCorrupted = errors.New("audit log corrupted")
+
+// Record describes a locate request and what DNS answered
+type Record struct {
+	Seq       uint64          `json:"seq"`
+	Time      time.Time       `json:"time"`
+	RequestID string          `json:"request_id"`
+	Version   string          `json:"version,omitempty"`
+	Principal string          `json:"principal,omitempty"`
+	Fleet     string          `json:"fleet,omitempty"`
+	Remote    string          `json:"remote"`
+	System    string          `json:"system"`
+	Sector    float64         `json:"sector"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	Output    json.RawMessage `json:"output,omitempty"`
+	Status    int             `json:"status"`
+	Error     string          `json:"error,omitempty"`
+	Prev      string          `json:"prev"`
+	Checksum  string          `json:"checksum"`
+}
+
+// sum computes the checksum of the record chained to its predecessor
+func (r Record) sum() string {
+	r.Checksum = ""
+	data, _ := json.Marshal(r)
+	h := sha256.New()
+	h.Write([]byte(r.Prev))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Filter selects records, zero values match everything
+type Filter struct {
+	Principal string
+	System    string
+	Sector    *float64
+	From      time.Time
+	To        time.Time
+	Limit     int
+}
+
+// Match reports whether r is selected by the filter
+func (f *Filter) Match(r *Record) bool {
+	switch {
+	case f.Principal != "" && r.Principal != f.Principal:
+		return false
+	case f.System != "" && r.System != f.System:
+		return false
+	case f.Sector != nil && r.Sector != *f.Sector:
+		return false
+	case !f.From.IsZero() && r.Time.Before(f.From):
+		return false
+	case !f.To.IsZero() && !r.Time.Before(f.To):
+		return false
+	}
+	return true
+}
+
+// ParseFilter builds a filter from the principal, system, sector, from
+// and to (RFC 3339) and limit parameters returned by get. The limit
+// defaults to 1000 records.
+func ParseFilter(get func(string) string) (Filter, error) {
+	f := Filter{
+		Principal: get("principal"),
+		System:    get("system"),
+		Limit:     1000,
+	}
+
+	var err error
+	if s := get("sector"); s != "" {
+		sector, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return f, errors.New("invalid sector")
+		}
+		f.Sector = &sector
+	}
+	if s := get("from"); s != "" {
+		if f.From, err = time.Parse(time.RFC3339, s); err != nil {
+			return f, errors.New("invalid from time: requires RFC 3339")
+		}
+	}
+	if s := get("to"); s != "" {
+		if f.To, err = time.Parse(time.RFC3339, s); err != nil {
+			return f, errors.New("invalid to time: requires RFC 3339")
+		}
+	}
+	if s := get("limit"); s != "" {
+		if f.Limit, err = strconv.Atoi(s); err != nil || f.Limit < 0 {
+			return f, errors.New("invalid limit")
+		}
+	}
+	return f, nil
+}
+
+// Log is an append-only audit log stored in a local file, one
+// JSON record per line. It is safe for concurrent use, and several
+// processes may append to the same file, like a server upgraded and
+// the one it replaces: the file is locked while written and records
+// appended by another process are chained to.
+type Log struct {
+	mu        sync.Mutex
+	path      string
+	file      *os.File
+	sync      bool
+	seq       uint64
+	last      string
+	size      int64
+	truncated int64
+}
+
+// Open opens the audit log at path, verifying the checksum chain of
+// the records it already holds. A final record left incomplete by a
+// crash is truncated. With sync set every record is flushed to disk
+// before Append returns.
+func Open(path string, sync bool) (*Log, error) {
+	l := Log{path: path, sync: sync, last: genesis}
+
+	var err error
+	l.file, err = os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening audit log")
+	}
+	if err := l.lock(); err != nil {
+		l.file.Close()
+		return nil, err
+	}
+	defer l.unlock()
+	info, err := l.file.Stat()
+	if err != nil {
+		l.file.Close()
+		return nil, errors.Wrap(err, "opening audit log")
+	}
+
+	l.size, err = scan(l.file, 0, genesis, func(r *Record) error {
+		l.seq, l.last = r.Seq, r.Checksum
+		return nil
+	})
+	if err != nil {
+		l.file.Close()
+		return nil, err
+	}
+	if l.truncated = info.Size() - l.size; l.truncated > 0 {
+		if err := l.file.Truncate(l.size); err != nil {
+			l.file.Close()
+			return nil, errors.Wrap(err, "truncating incomplete audit record")
+		}
+	}
+	return &l, nil
+}
+
+// Truncated returns the size in bytes of the incomplete final record
+// Open truncated, if any
+func (l *Log) Truncated() int64 {
+	return l.truncated
+}
+
+// Append chains r to the log and writes it. A record that could not
+// be written or synced whole is truncated, leaving the log as it was.
+func (l *Log) Append(r Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.lock(); err != nil {
+		return err
+	}
+	defer l.unlock()
+	if err := l.follow(); err != nil {
+		return err
+	}
+
+	r.Seq, r.Prev = l.seq+1, l.last
+	r.Checksum = r.sum()
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return errors.Wrap(err, "encoding audit record")
+	}
+	n, err := l.file.Write(append(data, '\n'))
+	if err != nil {
+		return l.undo(errors.Wrap(err, "writing audit record"))
+	}
+	if l.sync {
+		if err := l.file.Sync(); err != nil {
+			return l.undo(errors.Wrap(err, "syncing audit log"))
+		}
+	}
+	l.size += int64(n)
+	l.seq, l.last = r.Seq, r.Checksum
+	return nil
+}
+
+// undo truncates what was written of a record that failed with err, so
+// that the next one is not appended to a partial line
+func (l *Log) undo(err error) error {
+	if terr := l.file.Truncate(l.size); terr != nil {
+		return errors.Wrapf(err, "truncating audit record: %s", terr)
+	}
+	return err
+}
+
+// follow chains the log to the records other processes appended since
+// it last wrote, it must be called with the file locked.
+func (l *Log) follow() error {
+	info, err := l.file.Stat()
+	if err != nil {
+		return errors.Wrap(err, "reading audit log")
+	}
+	switch size := info.Size(); {
+	case size < l.size:
+		return errors.Wrap(ErrCorrupted, "audit log truncated")
+	case size > l.size:
+		n, err := scan(io.NewSectionReader(l.file, l.size, size-l.size), l.seq, l.last, func(r *Record) error {
+			l.seq, l.last = r.Seq, r.Checksum
+			return nil
+		})
+		l.size += n
+		return err
+	}
+	return nil
+}
+
+// lock locks the file against other processes, until unlock is called
+func (l *Log) lock() error {
+	return errors.Wrap(syscall.Flock(int(l.file.Fd()), syscall.LOCK_EX), "locking audit log")
+}
+
+func (l *Log) unlock() {
+	syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+}
+
+// Query returns the most recent records matching f, oldest first. It
+// reads the records appended when it was called apart from the writer,
+// from the end of the file, so that appending is not held up by queries
+// and a limited query does not read the whole log.
+func (l *Log) Query(f Filter) ([]Record, error) {
+	l.mu.Lock()
+	size := l.size
+	l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening audit log")
+	}
+	defer file.Close()
+
+	records := []Record{}
+	err = scanBack(file, size, func(r *Record) error {
+		if f.Match(r) {
+			records = append(records, *r)
+		}
+		if f.Limit > 0 && len(records) >= f.Limit {
+			return io.EOF
+		}
+		return nil
+	})
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, nil
+}
+
+// Close closes the log file
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// Scan reads the records of the audit log at path in order, verifying
+// the checksum chain as it goes. It stops at the first error returned
+// by fn, and returns ErrCorrupted when the chain is broken. A final
+// record still being written, or left incomplete by a crash, is skipped.
+func Scan(path string, fn func(*Record) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "opening audit log")
+	}
+	defer f.Close()
+
+	_, err = scan(f, 0, genesis, fn)
+	return err
+}
+
+// scan reads the records of rd like Scan, chaining the first one to
+// the record seq of checksum prev, and returns the offset following the
+// last complete record
+func scan(rd io.Reader, seq uint64, prev string, fn func(*Record) error) (int64, error) {
+	var offset int64
+	br := bufio.NewReader(rd)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			// a line without its newline is a record not fully written
+			return offset, nil
+		}
+		if err != nil {
+			return offset, errors.Wrap(err, "reading audit log")
+		}
+
+		var r Record
+		if err := json.Unmarshal(line, &r); err != nil {
+			return offset, errors.Wrapf(ErrCorrupted, "record %d: %s", seq+1, err)
+		}
+		if r.Seq != seq+1 || r.Prev != prev || r.sum() != r.Checksum {
+			return offset, errors.Wrapf(ErrCorrupted, "record %d", seq+1)
+		}
+		if err := fn(&r); err != nil {
+			return offset, err
+		}
+		prev, seq = r.Checksum, r.Seq
+		offset += int64(len(line))
+	}
+}
+
+// scanBack reads the complete records in the first size bytes of ra
+// newest first, checking each one is chained to the one before it. It
+// stops at the first error returned by fn.
+func scanBack(ra io.ReaderAt, size int64, fn func(*Record) error) error {
+	const chunk = 64 << 10
+
+	var next *Record
+	var pending []byte // the lines following pos not read yet
+	for pos := size; pos > 0 || len(pending) > 0; {
+		i := -1
+		if len(pending) > 1 {
+			i = bytes.LastIndexByte(pending[:len(pending)-1], '\n')
+		}
+		if i < 0 && pos > 0 {
+			n := int64(chunk)
+			if n > pos {
+				n = pos
+			}
+			pos -= n
+			buf := make([]byte, n, n+int64(len(pending)))
+			if _, err := ra.ReadAt(buf, pos); err != nil {
+				return errors.Wrap(err, "reading audit log")
+			}
+			pending = append(buf, pending...)
+			continue
+		}
+		line := pending[i+1:]
+		pending = pending[:i+1]
+
+		var r Record
+		if err := json.Unmarshal(line, &r); err != nil {
+			return errors.Wrapf(ErrCorrupted, "record at offset %d: %s", pos+int64(len(pending)), err)
+		}
+		switch {
+		case r.sum() != r.Checksum,
+			next != nil && (next.Seq != r.Seq+1 || next.Prev != r.Checksum),
+			pos == 0 && len(pending) == 0 && (r.Seq != 1 || r.Prev != genesis):
+			return errors.Wrapf(ErrCorrupted, "record %d", r.Seq)
+		}
+		if err := fn(&r); err != nil {
+			return err
+		}
+		next = &r
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package audit_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/timolinn/dns/pkg/audit"
+)
+
+func TestLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	l, err := audit.Open(path, false)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	for _, p := range []string{"drone-1", "drone-2", "drone-1"} {
+		if err := l.Append(audit.Record{Principal: p, System: "drone", Status: 200}); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+	}
+	l.Close()
+
+	t.Run("should continue the chain when reopened", func(t *testing.T) {
+		l, err := audit.Open(path, false)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		defer l.Close()
+		if err := l.Append(audit.Record{Principal: "drone-3", System: "ship"}); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+
+		records, err := l.Query(audit.Filter{})
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if len(records) != 4 || records[3].Seq != 4 {
+			t.Errorf("want 4 records, got %d", len(records))
+		}
+	})
+
+	t.Run("should filter records", func(t *testing.T) {
+		l, err := audit.Open(path, false)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		defer l.Close()
+
+		records, _ := l.Query(audit.Filter{Principal: "drone-1"})
+		if len(records) != 2 {
+			t.Errorf("want 2 records, got %d", len(records))
+		}
+		records, _ = l.Query(audit.Filter{System: "drone", Limit: 1})
+		if len(records) != 1 || records[0].Principal != "drone-1" || records[0].Seq != 3 {
+			t.Errorf("unexpected records %+v", records)
+		}
+	})
+
+	t.Run("should truncate an incomplete final record", func(t *testing.T) {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		f.Write([]byte(`{"seq":5,"time":"2020-06-01T`))
+		f.Close()
+
+		if err := audit.Scan(path, func(*audit.Record) error { return nil }); err != nil {
+			t.Errorf("Should skip the incomplete record, got %v", err)
+		}
+		l, err := audit.Open(path, false)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		defer l.Close()
+		if l.Truncated() == 0 {
+			t.Error("Should report the truncated record")
+		}
+		if err := l.Append(audit.Record{Principal: "drone-4", System: "drone"}); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		records, err := l.Query(audit.Filter{})
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if len(records) != 5 || records[4].Principal != "drone-4" {
+			t.Errorf("want 5 records ending with drone-4, got %+v", records)
+		}
+	})
+
+	t.Run("should chain records appended by another writer", func(t *testing.T) {
+		old, err := audit.Open(path, false)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		defer old.Close()
+		// like a server upgraded while the old one drains
+		upgraded, err := audit.Open(path, false)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		defer upgraded.Close()
+
+		count := func() uint64 {
+			var seq uint64
+			if err := audit.Scan(path, func(r *audit.Record) error { seq = r.Seq; return nil }); err != nil {
+				t.Fatalf("expected nil-err got %s", err)
+			}
+			return seq
+		}
+		before := count()
+		for i := 0; i < 3; i++ {
+			if err := old.Append(audit.Record{Principal: "drone-4"}); err != nil {
+				t.Fatalf("expected nil-err got %s", err)
+			}
+			if err := upgraded.Append(audit.Record{Principal: "drone-5"}); err != nil {
+				t.Fatalf("expected nil-err got %s", err)
+			}
+		}
+
+		if after := count(); after != before+6 {
+			t.Errorf("want %d records, got %d", before+6, after)
+		}
+	})
+
+	t.Run("should detect tampering", func(t *testing.T) {
+		data, _ := ioutil.ReadFile(path)
+		tampered := strings.Replace(string(data), "drone-2", "drone-9", 1)
+		ioutil.WriteFile(path, []byte(tampered), 0600)
+
+		if _, err := audit.Open(path, false); errors.Cause(err) != audit.ErrCorrupted {
+			t.Errorf("want %v, got %v", audit.ErrCorrupted, err)
+		}
+	})
+}
+
+func TestQuery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l, err := audit.Open(filepath.Join(dir, "audit.log"), false)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	defer l.Close()
+	// spans several of the chunks read from the end of the file
+	const n = 2000
+	for i := 0; i < n; i++ {
+		system := "drone"
+		if i%2 == 1 {
+			system = "ship"
+		}
+		if err := l.Append(audit.Record{Principal: "drone-1", System: system, Status: 200}); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+	}
+
+	t.Run("should return the most recent records oldest first", func(t *testing.T) {
+		records, err := l.Query(audit.Filter{System: "ship", Limit: 3})
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if len(records) != 3 || records[0].Seq != n-4 || records[1].Seq != n-2 || records[2].Seq != n {
+			t.Errorf("want records %d, %d and %d, got %+v", n-4, n-2, n, records)
+		}
+	})
+
+	t.Run("should return every record without a limit", func(t *testing.T) {
+		records, err := l.Query(audit.Filter{})
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if len(records) != n {
+			t.Fatalf("want %d records, got %d", n, len(records))
+		}
+		for i, r := range records {
+			if r.Seq != uint64(i+1) {
+				t.Fatalf("want record %d, got %d", i+1, r.Seq)
+			}
+		}
+	})
+}
+
+func TestAppendFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	l, err := audit.Open(path, false)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	defer l.Close()
+	if err := l.Append(audit.Record{Principal: "drone-1", System: "drone"}); err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+
+	// a file size limit cuts the next record short, like a full disk
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_FSIZE, &limit); err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	signal.Ignore(syscall.SIGXFSZ)
+	defer signal.Reset(syscall.SIGXFSZ)
+	short := limit
+	short.Cur = uint64(info.Size() + 10)
+	if err := syscall.Setrlimit(syscall.RLIMIT_FSIZE, &short); err != nil {
+		t.Skipf("cannot limit the file size: %s", err)
+	}
+	err = l.Append(audit.Record{Principal: "drone-2", System: "drone"})
+	if err := syscall.Setrlimit(syscall.RLIMIT_FSIZE, &limit); err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	if err == nil {
+		t.Fatal("want an error")
+	}
+
+	t.Run("should truncate the partial record", func(t *testing.T) {
+		after, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if after.Size() != info.Size() {
+			t.Errorf("want %d bytes, got %d", info.Size(), after.Size())
+		}
+	})
+
+	t.Run("should chain the next record to the last one written", func(t *testing.T) {
+		if err := l.Append(audit.Record{Principal: "drone-3", System: "drone"}); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		records, err := l.Query(audit.Filter{})
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if len(records) != 2 || records[1].Seq != 2 || records[1].Principal != "drone-3" {
+			t.Errorf("want drone-3 as record 2, got %+v", records)
+		}
+		if err := audit.Scan(path, func(*audit.Record) error { return nil }); err != nil {
+			t.Errorf("expected nil-err got %s", err)
+		}
+	})
+}
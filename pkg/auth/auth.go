@@ -0,0 +1,107 @@
+// Package auth identifies the drones, ships and operators
+// calling DNS and describes what each of them is allowed to do
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+type key int
+
+// keyPrincipal is how the authenticated principal is stored/retrieved.
+const keyPrincipal key = 1
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request
+	// does not carry the kind of credentials it understands.
+	ErrNoCredentials = errors.New("missing credentials")
+
+	// ErrInvalidCredentials is returned when credentials are present
+	// but unknown, revoked or otherwise unusable.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	// ErrForbidden is returned when a principal is not allowed to perform a request.
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Principal is the authenticated identity behind a request
+type Principal struct {
+	ID      string   `json:"id"`
+	Fleet   string   `json:"fleet"`
+	Systems []string `json:"systems"`
+	Scopes  []string `json:"scopes"`
+}
+
+// HasScope reports whether the principal was granted scope. Granted
+// scopes may end in a wildcard, "locate:*" grants "locate:drone" and
+// "locate:ship" while "*" grants everything.
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == "*" {
+			return true
+		}
+		if strings.HasSuffix(s, ":*") && strings.HasPrefix(scope, s[:len(s)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// BindSystem cross-checks the system type a request declares against
+// the system types bound to the principal, and returns the system type
+// of the request. Requests declaring none take the principal's system
+// when it is bound to exactly one. With override set the system of a
+// principal bound to exactly one replaces a mismatching declaration
+// instead of failing with ErrForbidden.
+func (p *Principal) BindSystem(declared string, override bool) (string, error) {
+	if len(p.Systems) == 0 {
+		return declared, nil
+	}
+	for _, s := range p.Systems {
+		if s == declared {
+			return declared, nil
+		}
+	}
+	// a principal bound to several systems has none to override with
+	if declared != "" && (!override || len(p.Systems) > 1) {
+		return "", ErrForbidden
+	}
+	if len(p.Systems) == 1 {
+		return p.Systems[0], nil
+	}
+	return declared, nil
+}
+
+// Authenticator resolves the principal behind a request. It returns
+// ErrNoCredentials when the request carries no credentials it handles
+// so that other authenticators may be tried.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Authenticate resolves the principal behind r using the first of
+// authenticators that recognises its credentials
+func Authenticate(r *http.Request, authenticators ...Authenticator) (*Principal, error) {
+	for _, a := range authenticators {
+		p, err := a.Authenticate(r)
+		if err == ErrNoCredentials {
+			continue
+		}
+		return p, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// NewContext returns a copy of ctx carrying the principal
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, keyPrincipal, p)
+}
+
+// FromContext returns the principal stored in ctx, if any
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(keyPrincipal).(*Principal)
+	return p, ok
+}
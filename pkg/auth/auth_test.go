@@ -0,0 +1,43 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/timolinn/dns/pkg/auth"
+)
+
+func TestBindSystem(t *testing.T) {
+	drone := &auth.Principal{ID: "drone-7", Systems: []string{"drone"}}
+	both := &auth.Principal{ID: "tender-1", Systems: []string{"drone", "ship"}}
+	operator := &auth.Principal{ID: "operator"}
+
+	cases := []struct {
+		name      string
+		principal *auth.Principal
+		declared  string
+		override  bool
+		want      string
+		err       error
+	}{
+		{"should keep a matching declaration", drone, "drone", false, "drone", nil},
+		{"should take the only bound system", drone, "", false, "drone", nil},
+		{"should reject a mismatching declaration", drone, "ship", false, "", auth.ErrForbidden},
+		{"should override a mismatching declaration", drone, "ship", true, "drone", nil},
+		{"should keep a declaration matching one of several", both, "ship", false, "ship", nil},
+		{"should leave the system to declare to principals bound to several", both, "", false, "", nil},
+		{"should reject a declaration matching none of several", both, "ultradrone", false, "", auth.ErrForbidden},
+		{"should not override with one of several systems", both, "ultradrone", true, "", auth.ErrForbidden},
+		{"should leave unbound principals alone", operator, "ship", false, "ship", nil},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.principal.BindSystem(test.declared, test.override)
+			if err != test.err {
+				t.Fatalf("Should return %v, got %v", test.err, err)
+			}
+			if got != test.want {
+				t.Errorf("Should bind %q, got %q", test.want, got)
+			}
+		})
+	}
+}
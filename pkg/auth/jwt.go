@@ -0,0 +1,301 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Supported JWT signing algorithms
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+var (
+	// ErrTokenExpired is returned for tokens past their exp claim.
+	ErrTokenExpired = errors.New("token expired")
+
+	// ErrTokenNotYetValid is returned for tokens before their nbf claim.
+	ErrTokenNotYetValid = errors.New("token not yet valid")
+)
+
+// JWK is a single JSON Web Key as found in a JWKS document
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+}
+
+// verificationKey is a parsed JWK ready to check signatures
+type verificationKey struct {
+	kid string
+	alg string
+	key interface{}
+}
+
+// Claims are the JWT claims DNS understands
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	Fleet     string   `json:"fleet"`
+	Systems   []string `json:"systems"`
+	Scope     string   `json:"scope"`
+	Scp       []string `json:"scp"`
+}
+
+// audience decodes the aud claim which may be a string or a list
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// JWTConfig configures how bearer tokens are verified
+type JWTConfig struct {
+	// Issuer, when set, must match the iss claim.
+	Issuer string
+	// Audience, when set, must be listed in the aud claim.
+	Audience string
+	// Leeway tolerates clock skew when checking exp and nbf.
+	Leeway time.Duration
+}
+
+// JWTVerifier authenticates requests carrying a bearer JWT signed
+// with one of the keys of a JWKS. It implements Authenticator.
+type JWTVerifier struct {
+	cfg  JWTConfig
+	keys []verificationKey
+	now  func() time.Time
+}
+
+// NewJWTVerifier constructs a JWTVerifier from a JWKS document
+func NewJWTVerifier(jwks []byte, cfg JWTConfig) (*JWTVerifier, error) {
+	var set struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := json.Unmarshal(jwks, &set); err != nil {
+		return nil, errors.Wrap(err, "decoding jwks")
+	}
+	if len(set.Keys) == 0 {
+		return nil, errors.New("jwks contains no keys")
+	}
+
+	v := JWTVerifier{cfg: cfg, now: time.Now}
+	for i, jwk := range set.Keys {
+		k, err := parseJWK(jwk)
+		if err != nil {
+			return nil, errors.Wrapf(err, "jwks key %d", i)
+		}
+		v.keys = append(v.keys, k)
+	}
+	return &v, nil
+}
+
+// LoadJWTVerifier constructs a JWTVerifier from a JWKS file
+func LoadJWTVerifier(path string, cfg JWTConfig) (*JWTVerifier, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading jwks")
+	}
+	return NewJWTVerifier(data, cfg)
+}
+
+// NewSecretJWTVerifier constructs a JWTVerifier of HS256 tokens
+// signed with a shared secret
+func NewSecretJWTVerifier(secret []byte, cfg JWTConfig) (*JWTVerifier, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("empty jwt secret")
+	}
+	return &JWTVerifier{
+		cfg:  cfg,
+		keys: []verificationKey{{alg: HS256, key: secret}},
+		now:  time.Now,
+	}, nil
+}
+
+// Authenticate resolves the principal for the bearer token
+// carried in the Authorization header.
+func (v *JWTVerifier) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "bearer ") {
+		return nil, ErrNoCredentials
+	}
+	claims, err := v.Verify(strings.TrimSpace(header[7:]))
+	if err != nil {
+		return nil, err
+	}
+	return claims.Principal(), nil
+}
+
+// Verify checks the signature and validity of a token and returns its claims
+func (v *JWTVerifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidCredentials
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, k := range v.keys {
+		// the algorithm is bound to the key, never taken from the token
+		// alone, so an RSA public key can not be abused as an HMAC secret
+		if k.alg != header.Alg || (header.Kid != "" && k.kid != header.Kid) {
+			continue
+		}
+		if k.verify(signed, sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrInvalidCredentials
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if err := v.validate(&claims); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+func (v *JWTVerifier) validate(c *Claims) error {
+	now := v.now()
+	if c.ExpiresAt == 0 || now.After(time.Unix(c.ExpiresAt, 0).Add(v.cfg.Leeway)) {
+		return ErrTokenExpired
+	}
+	if c.NotBefore != 0 && now.Before(time.Unix(c.NotBefore, 0).Add(-v.cfg.Leeway)) {
+		return ErrTokenNotYetValid
+	}
+	if v.cfg.Issuer != "" && c.Issuer != v.cfg.Issuer {
+		return ErrInvalidCredentials
+	}
+	if v.cfg.Audience != "" {
+		for _, aud := range c.Audience {
+			if aud == v.cfg.Audience {
+				return nil
+			}
+		}
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// Principal maps the claims to a principal. Scopes are read from the
+// space separated scope claim and from the scp list claim.
+func (c *Claims) Principal() *Principal {
+	scopes := append(strings.Fields(c.Scope), c.Scp...)
+	return &Principal{
+		ID:      c.Subject,
+		Fleet:   c.Fleet,
+		Systems: c.Systems,
+		Scopes:  scopes,
+	}
+}
+
+func (k verificationKey) verify(signed, sig []byte) bool {
+	switch key := k.key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), sig)
+	case *rsa.PublicKey:
+		sum := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, signed, sig)
+	}
+	return false
+}
+
+func parseJWK(jwk JWK) (verificationKey, error) {
+	k := verificationKey{kid: jwk.Kid}
+	switch jwk.Kty {
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil || len(secret) == 0 {
+			return k, errors.New("invalid oct key")
+		}
+		k.alg, k.key = HS256, secret
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return k, errors.New("invalid RSA modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return k, errors.New("invalid RSA exponent")
+		}
+		k.alg = RS256
+		k.key = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if jwk.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return k, errors.New("invalid Ed25519 key")
+		}
+		k.alg, k.key = EdDSA, ed25519.PublicKey(x)
+	default:
+		return k, errors.Errorf("unsupported key type %q", jwk.Kty)
+	}
+
+	if jwk.Alg != "" && jwk.Alg != k.alg {
+		return k, errors.Errorf("algorithm %s does not match key type %s", jwk.Alg, jwk.Kty)
+	}
+	return k, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
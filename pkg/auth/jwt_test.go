@@ -0,0 +1,138 @@
+package auth_test
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/timolinn/dns/pkg/auth"
+)
+
+var b64 = base64.RawURLEncoding
+
+func sign(t *testing.T, alg, kid string, claims map[string]interface{}, key interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sum := sha256.Sum256([]byte(signed))
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, sum[:]); err != nil {
+			t.Fatalf("signing token: %s", err)
+		}
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(signed))
+	}
+	return signed + "." + b64.EncodeToString(sig)
+}
+
+func TestJWTVerifier(t *testing.T) {
+	secret := []byte("super-secret-fleet-control-key")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+
+	jwks := fmt.Sprintf(`{"keys":[
+		{"kty":"oct","kid":"hs","k":%q},
+		{"kty":"RSA","kid":"rs","n":%q,"e":%q},
+		{"kty":"OKP","kid":"ed","crv":"Ed25519","x":%q}
+	]}`,
+		b64.EncodeToString(secret),
+		b64.EncodeToString(rsaKey.N.Bytes()), b64.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+		b64.EncodeToString(edPub),
+	)
+
+	verifier, err := auth.NewJWTVerifier([]byte(jwks), auth.JWTConfig{Issuer: "fleet-control", Audience: "dns"})
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"sub":   "drone-7",
+			"iss":   "fleet-control",
+			"aud":   []string{"dns"},
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"fleet": "alpha",
+			"scope": "locate:drone admin:sectors",
+		}
+	}
+	expired := valid()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	wrongAudience := valid()
+	wrongAudience["aud"] = "billing"
+
+	cases := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"should accept HS256 tokens", sign(t, auth.HS256, "hs", valid(), secret), nil},
+		{"should accept RS256 tokens", sign(t, auth.RS256, "rs", valid(), rsaKey), nil},
+		{"should accept EdDSA tokens", sign(t, auth.EdDSA, "ed", valid(), edKey), nil},
+		{"should accept tokens without kid", sign(t, auth.EdDSA, "", valid(), edKey), nil},
+		{"should reject expired tokens", sign(t, auth.HS256, "hs", expired, secret), auth.ErrTokenExpired},
+		{"should reject other audiences", sign(t, auth.HS256, "hs", wrongAudience, secret), auth.ErrInvalidCredentials},
+		{"should reject unknown secrets", sign(t, auth.HS256, "hs", valid(), []byte("guess")), auth.ErrInvalidCredentials},
+		{"should reject HS256 signed with the RSA key id", sign(t, auth.HS256, "rs", valid(), secret), auth.ErrInvalidCredentials},
+		{"should reject unsigned tokens", sign(t, "none", "", valid(), nil), auth.ErrInvalidCredentials},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			claims, err := verifier.Verify(test.token)
+			if err != test.err {
+				t.Fatalf("want err=%v, got %v", test.err, err)
+			}
+			if err != nil {
+				return
+			}
+			p := claims.Principal()
+			if p.ID != "drone-7" || p.Fleet != "alpha" {
+				t.Errorf("unexpected principal %+v", p)
+			}
+			if !p.HasScope("locate:drone") || p.HasScope("locate:ship") {
+				t.Errorf("unexpected scopes %v", p.Scopes)
+			}
+		})
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	p := auth.Principal{Scopes: []string{"locate:*", "admin:keys"}}
+	cases := []struct {
+		scope string
+		want  bool
+	}{
+		{"locate:drone", true},
+		{"locate:ship", true},
+		{"admin:keys", true},
+		{"admin:sectors", false},
+		{"locate", false},
+	}
+	for _, test := range cases {
+		if got := p.HasScope(test.scope); got != test.want {
+			t.Errorf("HasScope(%q): want=%v, got=%v", test.scope, test.want, got)
+		}
+	}
+}
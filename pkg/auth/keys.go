@@ -0,0 +1,371 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HeaderAPIKey is the request header carrying API keys
+const HeaderAPIKey = "X-API-Key"
+
+// keyPrefix is prepended to every issued API key so that
+// leaked keys are easy to recognise in logs and source code.
+const keyPrefix = "dns"
+
+var (
+	// ErrKeyNotFound is returned when no key matches the given ID.
+	ErrKeyNotFound = errors.New("api key not found")
+
+	// ErrKeyRevoked is returned when operating on a revoked key.
+	ErrKeyRevoked = errors.New("api key revoked")
+)
+
+// Key describes an issued API key. The secret itself is never
+// stored, only its SHA-256 hash.
+type Key struct {
+	ID        string     `json:"id"`
+	Hash      string     `json:"hash,omitempty"`
+	Fleet     string     `json:"fleet"`
+	Systems   []string   `json:"systems"`
+	Admin     bool       `json:"admin"`
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Revoked reports whether the key has been revoked
+func (k *Key) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// Principal returns the identity authenticated by the key. A key is
+// granted "locate:<system>" for each system it is bound to, and every
+// admin scope when it is an administrator key.
+func (k *Key) Principal() *Principal {
+	var scopes []string
+	for _, s := range k.Systems {
+		scopes = append(scopes, "locate:"+s)
+	}
+	if k.Admin {
+		scopes = append(scopes, "admin:*")
+	}
+	return &Principal{
+		ID:      k.ID,
+		Fleet:   k.Fleet,
+		Systems: k.Systems,
+		Scopes:  scopes,
+	}
+}
+
+// KeyStore holds hashed API keys, optionally persisting them
+// to a JSON file. It implements Authenticator.
+type KeyStore struct {
+	mu   sync.RWMutex
+	path string
+	keys map[string]*Key
+}
+
+// NewKeyStore constructs an in-memory KeyStore
+func NewKeyStore() *KeyStore {
+	return &KeyStore{keys: make(map[string]*Key)}
+}
+
+// OpenKeyStore constructs a KeyStore persisted at path,
+// loading any keys the file already holds.
+func OpenKeyStore(path string) (*KeyStore, error) {
+	s := NewKeyStore()
+	s.path = path
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload replaces the keys of a persisted store with the keys its file
+// holds, picking up keys changed by another process. The keys are left
+// untouched when the file cannot be read.
+func (s *KeyStore) Reload() error {
+	if s.path == "" {
+		return nil
+	}
+
+	// the file is read under the lock, so that a key created or
+	// rotated meanwhile is not lost to an older copy of the file
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// load replaces the keys with the keys the file holds, it must be
+// called with s.mu held.
+func (s *KeyStore) load() error {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		data = []byte("[]")
+	} else if err != nil {
+		return errors.Wrap(err, "reading key store")
+	}
+
+	var list []*Key
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.Wrapf(err, "decoding key store %s", s.path)
+	}
+	keys := make(map[string]*Key, len(list))
+	for _, k := range list {
+		keys[k.ID] = k
+	}
+	s.keys = keys
+	return nil
+}
+
+// Len returns the number of keys in the store, including revoked ones
+func (s *KeyStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys)
+}
+
+// Create issues a new API key bound to a fleet and a set of systems.
+// The plaintext key is returned once and cannot be recovered later.
+func (s *KeyStore) Create(fleet string, systems []string, admin bool) (Key, string, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return Key{}, "", err
+	}
+	secret, plain, err := newSecret(id)
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	k := &Key{
+		ID:        id,
+		Hash:      secret,
+		Fleet:     fleet,
+		Systems:   systems,
+		Admin:     admin,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lock()
+	if err != nil {
+		return Key{}, "", err
+	}
+	defer unlock()
+	s.keys[id] = k
+	if err := s.save(); err != nil {
+		delete(s.keys, id)
+		return Key{}, "", err
+	}
+	return k.public(), plain, nil
+}
+
+// List returns all keys ordered by creation time, without their hashes
+func (s *KeyStore) List() []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k.public())
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys
+}
+
+// Rotate replaces the secret of a key, invalidating the old one immediately
+func (s *KeyStore) Rotate(id string) (Key, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lock()
+	if err != nil {
+		return Key{}, "", err
+	}
+	defer unlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return Key{}, "", ErrKeyNotFound
+	}
+	if k.Revoked() {
+		return Key{}, "", ErrKeyRevoked
+	}
+
+	hash, plain, err := newSecret(id)
+	if err != nil {
+		return Key{}, "", err
+	}
+	old, oldRotated := k.Hash, k.RotatedAt
+	now := time.Now().UTC()
+	k.Hash, k.RotatedAt = hash, &now
+	if err := s.save(); err != nil {
+		k.Hash, k.RotatedAt = old, oldRotated
+		return Key{}, "", err
+	}
+	return k.public(), plain, nil
+}
+
+// Revoke permanently disables a key
+func (s *KeyStore) Revoke(id string) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lock()
+	if err != nil {
+		return Key{}, err
+	}
+	defer unlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return Key{}, ErrKeyNotFound
+	}
+	if k.Revoked() {
+		return k.public(), nil
+	}
+
+	now := time.Now().UTC()
+	k.RevokedAt = &now
+	if err := s.save(); err != nil {
+		k.RevokedAt = nil
+		return Key{}, err
+	}
+	return k.public(), nil
+}
+
+// Authenticate resolves the principal for the API key carried in the
+// X-API-Key header.
+func (s *KeyStore) Authenticate(r *http.Request) (*Principal, error) {
+	token := r.Header.Get(HeaderAPIKey)
+	if token == "" {
+		return nil, ErrNoCredentials
+	}
+	return s.Verify(token)
+}
+
+// Verify resolves the principal for a plaintext API key
+func (s *KeyStore) Verify(token string) (*Principal, error) {
+	parts := strings.SplitN(token, "_", 3)
+	if len(parts) != 3 || parts[0] != keyPrefix {
+		return nil, ErrInvalidCredentials
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	k, ok := s.keys[parts[1]]
+	if !ok || k.Revoked() {
+		return nil, ErrInvalidCredentials
+	}
+	if subtle.ConstantTimeCompare([]byte(hashKey(token)), []byte(k.Hash)) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	return k.Principal(), nil
+}
+
+// public returns a copy of the key that is safe to hand out
+func (k *Key) public() Key {
+	c := *k
+	c.Hash = ""
+	return c
+}
+
+// lock locks the file of a persisted store against other processes
+// changing it, like the keys command and a running server, and reads
+// it again so that their changes are kept. It must be called with s.mu
+// held and returns the func unlocking the file.
+func (s *KeyStore) lock() (func(), error) {
+	if s.path == "" {
+		return func() {}, nil
+	}
+	// the key file is replaced on save, a file beside it is locked
+	f, err := os.OpenFile(s.path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "locking key store")
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "locking key store")
+	}
+	if err := s.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() { f.Close() }, nil
+}
+
+// save writes the store to disk, it must be called with s.mu held.
+func (s *KeyStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	keys := make([]*Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a crash never
+	// leaves a half written key store behind
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), ".keys-*")
+	if err != nil {
+		return errors.Wrap(err, "saving key store")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "saving key store")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "saving key store")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), s.path), "saving key store")
+}
+
+// newSecret generates a plaintext key for id and returns its hash
+func newSecret(id string) (hash, plain string, err error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", "", err
+	}
+	plain = strings.Join([]string{keyPrefix, id, secret}, "_")
+	return hashKey(plain), plain, nil
+}
+
+func hashKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "generating random key")
+	}
+	return hex.EncodeToString(b), nil
+}
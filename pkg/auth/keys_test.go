@@ -0,0 +1,49 @@
+package auth_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/timolinn/dns/pkg/auth"
+)
+
+func TestKeyStoreShared(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "keys.json")
+
+	// like a running server and the keys command
+	server, err := auth.OpenKeyStore(path)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	cli, err := auth.OpenKeyStore(path)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+
+	created, _, err := cli.Create("alpha", []string{"drone"}, false)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	if _, _, err := server.Create("beta", []string{"ship"}, false); err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	if _, err := server.Revoke(created.ID); err != nil {
+		t.Errorf("Should revoke the key created by the other store, got %v", err)
+	}
+
+	reopened, err := auth.OpenKeyStore(path)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	keys := reopened.List()
+	if len(keys) != 2 || !keys[0].Revoked() || keys[1].Fleet != "beta" {
+		t.Errorf("want both keys saved, got %+v", keys)
+	}
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CertScheme is the URI SAN scheme identifying drones and ships,
+// certificates carry dns://<fleet>/<system>/<id>
+const CertScheme = "dns"
+
+// CertAuthenticator identifies callers by the client certificate they
+// presented during a mutual TLS handshake. It implements Authenticator.
+//
+// The identity is read from the first dns:// URI SAN of the certificate,
+// falling back to the subject where the common name is the ID, the
+// organization the fleet and organizational units the system types.
+type CertAuthenticator struct {
+	// Systems lists the system types a certificate may claim,
+	// any other organizational unit is ignored.
+	Systems []string
+}
+
+// Authenticate resolves the principal for the verified client certificate
+func (c *CertAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, ErrNoCredentials
+	}
+	p := c.principal(r.TLS.VerifiedChains[0][0])
+	if p.ID == "" || len(p.Systems) == 0 {
+		return nil, ErrInvalidCredentials
+	}
+	return p, nil
+}
+
+func (c *CertAuthenticator) principal(cert *x509.Certificate) *Principal {
+	for _, u := range cert.URIs {
+		if p, ok := c.fromURI(u); ok {
+			return p
+		}
+	}
+
+	p := Principal{ID: cert.Subject.CommonName}
+	if len(cert.Subject.Organization) > 0 {
+		p.Fleet = cert.Subject.Organization[0]
+	}
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		if c.known(ou) {
+			p.Systems = append(p.Systems, ou)
+			p.Scopes = append(p.Scopes, "locate:"+ou)
+		}
+	}
+	return &p
+}
+
+func (c *CertAuthenticator) fromURI(u *url.URL) (*Principal, bool) {
+	if u.Scheme != CertScheme {
+		return nil, false
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) != 2 || !c.known(parts[0]) || parts[1] == "" {
+		return nil, false
+	}
+	return &Principal{
+		ID:      parts[1],
+		Fleet:   u.Host,
+		Systems: []string{parts[0]},
+		Scopes:  []string{"locate:" + parts[0]},
+	}, true
+}
+
+func (c *CertAuthenticator) known(system string) bool {
+	for _, s := range c.Systems {
+		if s == system {
+			return true
+		}
+	}
+	return false
+}
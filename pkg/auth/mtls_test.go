@@ -0,0 +1,104 @@
+package auth_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/timolinn/dns/pkg/auth"
+)
+
+func TestCertAuthenticator(t *testing.T) {
+	a := &auth.CertAuthenticator{Systems: []string{"drone", "ship"}}
+
+	uri := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		return u
+	}
+
+	cases := []struct {
+		name    string
+		cert    *x509.Certificate
+		id      string
+		fleet   string
+		systems []string
+		err     error
+	}{
+		{
+			name:    "should read the identity from the URI SAN",
+			cert:    &x509.Certificate{URIs: []*url.URL{uri("spiffe://fleet/x"), uri("dns://alpha/drone/drone-7")}},
+			id:      "drone-7",
+			fleet:   "alpha",
+			systems: []string{"drone"},
+		},
+		{
+			name: "should fall back to the subject",
+			cert: &x509.Certificate{Subject: pkix.Name{
+				CommonName:         "ship-3",
+				Organization:       []string{"beta"},
+				OrganizationalUnit: []string{"ship", "engineering"},
+			}},
+			id:      "ship-3",
+			fleet:   "beta",
+			systems: []string{"ship"},
+		},
+		{
+			name: "should skip URI SANs of unknown systems",
+			cert: &x509.Certificate{
+				URIs:    []*url.URL{uri("dns://alpha/ultradrone/x-1")},
+				Subject: pkix.Name{CommonName: "drone-8", OrganizationalUnit: []string{"drone"}},
+			},
+			id:      "drone-8",
+			systems: []string{"drone"},
+		},
+		{
+			name: "should reject certificates without a known system",
+			cert: &x509.Certificate{Subject: pkix.Name{CommonName: "laptop", OrganizationalUnit: []string{"it"}}},
+			err:  auth.ErrInvalidCredentials,
+		},
+		{
+			name: "should reject certificates without an ID",
+			cert: &x509.Certificate{Subject: pkix.Name{OrganizationalUnit: []string{"drone"}}},
+			err:  auth.ErrInvalidCredentials,
+		},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{test.cert}}}
+			p, err := a.Authenticate(r)
+			if err != test.err {
+				t.Fatalf("Should return %v, got %v", test.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if p.ID != test.id || p.Fleet != test.fleet {
+				t.Errorf("Should identify %s of %q, got %s of %q", test.id, test.fleet, p.ID, p.Fleet)
+			}
+			if len(p.Systems) != len(test.systems) || p.Systems[0] != test.systems[0] {
+				t.Errorf("Should bind %v, got %v", test.systems, p.Systems)
+			}
+			if !p.HasScope("locate:" + test.systems[0]) {
+				t.Errorf("Should grant locate:%s, got %v", test.systems[0], p.Scopes)
+			}
+		})
+	}
+
+	t.Run("should ignore connections without a verified certificate", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/", nil)
+		if _, err := a.Authenticate(r); err != auth.ErrNoCredentials {
+			t.Errorf("Should return ErrNoCredentials, got %v", err)
+		}
+		r.TLS = &tls.ConnectionState{}
+		if _, err := a.Authenticate(r); err != auth.ErrNoCredentials {
+			t.Errorf("Should return ErrNoCredentials, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+// Package buildinfo describes the build of the running binary, so that
+// logs, metrics and responses tell which build answered.
+//
+// The version, commit and build date are set at link time:
+//
+//	go build -ldflags "-X github.com/timolinn/dns/pkg/buildinfo.Version=v1.4.0 \
+//		-X github.com/timolinn/dns/pkg/buildinfo.Commit=$(git rev-parse HEAD) \
+//		-X github.com/timolinn/dns/pkg/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/api
+//
+// Settings left unset are read from the build information embedded by
+// the Go toolchain, when available.
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// Set at link time with -ldflags -X
+var (
+	Version = ""
+	Commit  = ""
+	Date    = ""
+)
+
+// Info describes a build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information of the running binary
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		if info.Commit == "" {
+			info.Commit, info.Date, info.Modified = vcs(bi, info.Date)
+		}
+	}
+	if info.Version == "" {
+		info.Version = "develop"
+	}
+	return info
+}
+
+var (
+	current     string
+	currentOnce sync.Once
+)
+
+// Current returns the version of the running binary, like Get but read
+// once. It labels the log entries and audit records of this build,
+// telling apart the processes of an upgrade.
+func Current() string {
+	currentOnce.Do(func() { current = Get().Version })
+	return current
+}
+
+// String formats the build information on one line
+func (i Info) String() string {
+	var details []string
+	if i.Commit != "" {
+		commit := "commit " + i.Commit
+		if i.Modified {
+			commit += " (modified)"
+		}
+		details = append(details, commit)
+	}
+	if i.Date != "" {
+		details = append(details, "built "+i.Date)
+	}
+	details = append(details, i.GoVersion)
+	return fmt.Sprintf("%s (%s)", i.Version, strings.Join(details, ", "))
+}
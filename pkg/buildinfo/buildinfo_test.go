@@ -0,0 +1,37 @@
+package buildinfo_test
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/timolinn/dns/pkg/buildinfo"
+)
+
+func TestGet(t *testing.T) {
+	t.Run("should default the version", func(t *testing.T) {
+		info := buildinfo.Get()
+		if info.Version == "" {
+			t.Errorf("want a version")
+		}
+		if info.GoVersion != runtime.Version() {
+			t.Errorf("want go version %s, got %s", runtime.Version(), info.GoVersion)
+		}
+	})
+
+	t.Run("should prefer the settings given at link time", func(t *testing.T) {
+		buildinfo.Version, buildinfo.Commit, buildinfo.Date = "v1.4.0", "0a1b2c3", "2020-06-01T12:00:00Z"
+		defer func() { buildinfo.Version, buildinfo.Commit, buildinfo.Date = "", "", "" }()
+
+		info := buildinfo.Get()
+		want := "v1.4.0 (commit 0a1b2c3, built 2020-06-01T12:00:00Z, " + runtime.Version() + ")"
+		if got := info.String(); got != want {
+			t.Errorf("want %q, got %q", want, got)
+		}
+	})
+}
+
+func TestCurrent(t *testing.T) {
+	if got, want := buildinfo.Current(), buildinfo.Get().Version; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
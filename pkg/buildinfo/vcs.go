@@ -0,0 +1,27 @@
+//go:build go1.18
+// +build go1.18
+
+package buildinfo
+
+import "runtime/debug"
+
+// vcs returns the revision, commit time and dirty flag recorded by
+// the Go toolchain when building from a repository. The date given
+// at link time, if any, is kept.
+func vcs(bi *debug.BuildInfo, date string) (string, string, bool) {
+	var commit string
+	var modified bool
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			commit = s.Value
+		case "vcs.time":
+			if date == "" {
+				date = s.Value
+			}
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	return commit, date, modified
+}
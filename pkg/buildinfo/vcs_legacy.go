@@ -0,0 +1,12 @@
+//go:build !go1.18
+// +build !go1.18
+
+package buildinfo
+
+import "runtime/debug"
+
+// vcs reports nothing, toolchains before Go 1.18 do not record the
+// repository state, see the ldflags in the package documentation.
+func vcs(bi *debug.BuildInfo, date string) (string, string, bool) {
+	return "", date, false
+}
@@ -0,0 +1,186 @@
+// Package capture stores HTTP request and response pairs served by DNS
+// so that they can be replayed against another build and compared.
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// redacted replaces credentials in captured headers
+const redacted = "REDACTED"
+
+// credentials are the request headers never written to a capture
+var credentials = []string{"Authorization", "X-API-Key", "Cookie"}
+
+// Body is a request or response payload. Text is written as is,
+// other payloads are base64 encoded.
+type Body []byte
+
+// MarshalJSON implements json.Marshaler
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(struct {
+			Text string `json:"text"`
+		}{string(b)})
+	}
+	return json.Marshal(struct {
+		Base64 []byte `json:"base64"`
+	}{b})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Text   *string `json:"text"`
+		Base64 []byte  `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Text != nil {
+		*b = Body(*v.Text)
+		return nil
+	}
+	*b = v.Base64
+	return nil
+}
+
+// Request is a captured request
+type Request struct {
+	Method string      `json:"method"`
+	URI    string      `json:"uri"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// Response is a captured response
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// Exchange is a request and the response DNS sent back
+type Exchange struct {
+	Time     time.Time `json:"time"`
+	Duration float64   `json:"duration_ms"`
+	Request  Request   `json:"request"`
+	Response Response  `json:"response"`
+}
+
+// NewRequest rebuilds the captured request. Credentials were
+// redacted from the capture and must be set again by the caller.
+func (e *Exchange) NewRequest(target string) (*http.Request, error) {
+	r, err := http.NewRequest(e.Request.Method, target+e.Request.URI, bytes.NewReader(e.Request.Body))
+	if err != nil {
+		return nil, errors.Wrap(err, "rebuilding captured request")
+	}
+	for k, vv := range e.Request.Header {
+		for _, v := range vv {
+			if v != redacted {
+				r.Header.Add(k, v)
+			}
+		}
+	}
+	// leave content negotiation to the replaying client so that
+	// responses are compared uncompressed
+	r.Header.Del("Accept-Encoding")
+	return r, nil
+}
+
+// Writer appends exchanges to a capture, one JSON object per line.
+// It is safe for concurrent use.
+type Writer struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewWriter returns a Writer appending to out
+func NewWriter(out io.Writer) *Writer {
+	return &Writer{out: out}
+}
+
+// Write appends e to the capture, redacting the credentials it holds
+func (w *Writer) Write(e Exchange) error {
+	e.Request.Header = e.Request.Header.Clone()
+	for _, k := range credentials {
+		if _, ok := e.Request.Header[http.CanonicalHeaderKey(k)]; ok {
+			e.Request.Header.Set(k, redacted)
+		}
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return errors.Wrap(err, "encoding exchange")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.out.Write(append(data, '\n'))
+	return errors.Wrap(err, "writing exchange")
+}
+
+// Read calls fn with every exchange of the capture read from r, in
+// order. It stops at the first error returned by fn.
+func Read(r io.Reader, fn func(*Exchange) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 8<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return errors.Wrapf(err, "decoding exchange on line %d", line)
+		}
+		if err := fn(&e); err != nil {
+			return err
+		}
+	}
+	return errors.Wrap(scanner.Err(), "reading capture")
+}
+
+// Diff describes how a replayed response differs from the captured one
+type Diff struct {
+	WantStatus int
+	GotStatus  int
+	WantBody   Body
+	GotBody    Body
+}
+
+// Compare compares a replayed response to the captured one. JSON
+// bodies are compared by value, other bodies byte for byte. It
+// returns nil when they match.
+func Compare(want Response, status int, body []byte) *Diff {
+	d := Diff{
+		WantStatus: want.Status,
+		GotStatus:  status,
+		WantBody:   want.Body,
+		GotBody:    body,
+	}
+	if d.WantStatus == d.GotStatus && sameBody(d.WantBody, d.GotBody) {
+		return nil
+	}
+	return &d
+}
+
+func sameBody(want, got []byte) bool {
+	if bytes.Equal(want, got) {
+		return true
+	}
+	var w, g interface{}
+	if json.Unmarshal(want, &w) != nil || json.Unmarshal(got, &g) != nil {
+		return false
+	}
+	return reflect.DeepEqual(w, g)
+}
@@ -0,0 +1,60 @@
+package capture_test
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/timolinn/dns/pkg/capture"
+)
+
+func TestCapture(t *testing.T) {
+	t.Run("should round trip exchanges and redact credentials", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := capture.NewWriter(&buf)
+		header := http.Header{"X-Api-Key": {"dns_secret"}, "X-System-Type": {"drone"}}
+		w.Write(capture.Exchange{
+			Request:  capture.Request{Method: "POST", URI: "/v1/locate", Header: header, Body: capture.Body(`{"x":"1"}`)},
+			Response: capture.Response{Status: 200, Body: capture.Body{0x1f, 0x8b, 0xff}},
+		})
+		if bytes.Contains(buf.Bytes(), []byte("dns_secret")) {
+			t.Errorf("capture leaks credentials: %s", buf.String())
+		}
+		if header.Get("X-API-Key") != "dns_secret" {
+			t.Errorf("Writer.Write() modified the request header")
+		}
+
+		var got []capture.Exchange
+		err := capture.Read(&buf, func(e *capture.Exchange) error {
+			got = append(got, *e)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if len(got) != 1 || string(got[0].Request.Body) != `{"x":"1"}` || !bytes.Equal(got[0].Response.Body, []byte{0x1f, 0x8b, 0xff}) {
+			t.Fatalf("unexpected exchanges %+v", got)
+		}
+
+		r, err := got[0].NewRequest("http://localhost")
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if r.Header.Get("X-API-Key") != "" || r.Header.Get("X-System-Type") != "drone" {
+			t.Errorf("unexpected replayed header %v", r.Header)
+		}
+	})
+
+	t.Run("should compare JSON bodies by value", func(t *testing.T) {
+		want := capture.Response{Status: 200, Body: capture.Body(`{"loc":8,"sector":1}`)}
+		if d := capture.Compare(want, 200, []byte(`{ "sector": 1, "loc": 8 }`)); d != nil {
+			t.Errorf("want no diff, got %+v", d)
+		}
+		if d := capture.Compare(want, 200, []byte(`{"loc":9,"sector":1}`)); d == nil {
+			t.Errorf("want a body diff")
+		}
+		if d := capture.Compare(want, 400, want.Body); d == nil || d.GotStatus != 400 {
+			t.Errorf("want a status diff, got %+v", d)
+		}
+	})
+}
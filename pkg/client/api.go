@@ -0,0 +1,266 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/timolinn/dns/pkg/audit"
+	"github.com/timolinn/dns/pkg/auth"
+	"github.com/timolinn/dns/pkg/buildinfo"
+	"github.com/timolinn/dns/pkg/health"
+)
+
+// System is the type of the system locating its databank
+type System string
+
+// System types served by DNS
+const (
+	Drone System = "drone"
+	Ship  System = "ship"
+)
+
+// CoordsVelocity are the coordinates and velocity of a system
+type CoordsVelocity struct {
+	X   float64 `json:"x,string"`
+	Y   float64 `json:"y,string"`
+	Z   float64 `json:"z,string"`
+	Vel float64 `json:"vel,string"`
+}
+
+// NewKey describes an API key to create
+type NewKey struct {
+	Fleet   string   `json:"fleet"`
+	Systems []string `json:"systems"`
+	Admin   bool     `json:"admin"`
+}
+
+// IssuedKey is a created or rotated API key along with its secret,
+// which the server does not return again
+type IssuedKey struct {
+	auth.Key
+	Secret string `json:"key"`
+}
+
+// CacheStats are the statistics of the navigator cache
+type CacheStats struct {
+	Size      int   `json:"size"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Shared    int64 `json:"shared"`
+	Evictions int64 `json:"evictions"`
+}
+
+// LimiterStats are the statistics of a route limiter
+type LimiterStats struct {
+	Name     string `json:"name"`
+	Limit    int    `json:"limit"`
+	InFlight int    `json:"in_flight"`
+	Queued   int    `json:"queued"`
+	Shed     int64  `json:"shed"`
+}
+
+// Settings are the settings a server applies while it serves
+type Settings struct {
+	Sector   float64  `json:"sector"`
+	Systems  []System `json:"systems"`
+	LogLevel string   `json:"log_level"`
+}
+
+// Locate returns the databank location of a system at cv. Locating is
+// a pure computation, so the call is retried under an Idempotency-Key.
+func (c *Client) Locate(ctx context.Context, system System, cv CoordsVelocity) (float64, error) {
+	header := idempotencyKey()
+	header.Set("X-System-Type", string(system))
+
+	var resp map[string]float64
+	cl := call{method: http.MethodPost, path: "/v1/locate", header: header, in: cv, idempotent: true}
+	if err := c.do(ctx, cl, &resp); err != nil {
+		return 0, err
+	}
+	// drones are answered loc, ships location
+	for _, key := range []string{"loc", "location"} {
+		if loc, ok := resp[key]; ok {
+			return loc, nil
+		}
+	}
+	return 0, errors.New("response without location")
+}
+
+// welcome prefixes the sector in the greeting served at the root
+const welcome = "Welcome to DNS on Sector "
+
+// Sector returns the sector the server navigates, read from its greeting
+func (c *Client) Sector(ctx context.Context) (float64, error) {
+	var data []byte
+	if err := c.get(ctx, "/", nil, &data); err != nil {
+		return 0, err
+	}
+	text := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(text, welcome) {
+		return 0, errors.Errorf("unexpected greeting %q", text)
+	}
+	sector, err := strconv.ParseFloat(strings.TrimPrefix(text, welcome), 64)
+	return sector, errors.Wrapf(err, "parsing sector of %q", text)
+}
+
+// Live checks that the server is alive
+func (c *Client) Live(ctx context.Context) error {
+	return c.get(ctx, "/healthz", nil, nil)
+}
+
+// Ready returns the readiness report of the server. The report is
+// also returned along with the *Error of a server that is not ready,
+// which is not retried.
+func (c *Client) Ready(ctx context.Context) (health.Report, error) {
+	var report health.Report
+	err := c.do(ctx, call{method: http.MethodGet, path: "/readyz", failureBody: true}, &report)
+	return report, err
+}
+
+// Version returns the build information of the server
+func (c *Client) Version(ctx context.Context) (buildinfo.Info, error) {
+	var info buildinfo.Info
+	err := c.get(ctx, "/version", nil, &info)
+	return info, err
+}
+
+// Keys lists the API keys of the server
+func (c *Client) Keys(ctx context.Context) ([]auth.Key, error) {
+	var keys []auth.Key
+	err := c.get(ctx, "/v1/admin/keys", nil, &keys)
+	return keys, err
+}
+
+// CreateKey creates an API key. It is not retried, as a retry could
+// create a second key.
+func (c *Client) CreateKey(ctx context.Context, nk NewKey) (IssuedKey, error) {
+	var key IssuedKey
+	err := c.do(ctx, call{method: http.MethodPost, path: "/v1/admin/keys", in: nk}, &key)
+	return key, err
+}
+
+// RotateKey replaces the secret of the key id. It is not retried, as
+// a retry would replace the secret again.
+func (c *Client) RotateKey(ctx context.Context, id string) (IssuedKey, error) {
+	var key IssuedKey
+	err := c.do(ctx, call{method: http.MethodPost, path: "/v1/admin/keys/" + id + "/rotate"}, &key)
+	return key, err
+}
+
+// RevokeKey revokes the key id
+func (c *Client) RevokeKey(ctx context.Context, id string) (auth.Key, error) {
+	var key auth.Key
+	err := c.do(ctx, call{method: http.MethodDelete, path: "/v1/admin/keys/" + id, idempotent: true}, &key)
+	return key, err
+}
+
+// CacheStats returns the statistics of the navigator cache
+func (c *Client) CacheStats(ctx context.Context) (CacheStats, error) {
+	var stats CacheStats
+	err := c.get(ctx, "/v1/admin/cache", nil, &stats)
+	return stats, err
+}
+
+// PurgeCache empties the navigator cache
+func (c *Client) PurgeCache(ctx context.Context) error {
+	return c.do(ctx, call{method: http.MethodDelete, path: "/v1/admin/cache", idempotent: true}, nil)
+}
+
+// Audit returns the audit records selected by f
+func (c *Client) Audit(ctx context.Context, f audit.Filter) ([]audit.Record, error) {
+	q := make(url.Values)
+	set := func(key, value string) {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	set("principal", f.Principal)
+	set("system", f.System)
+	if f.Sector != nil {
+		set("sector", strconv.FormatFloat(*f.Sector, 'f', -1, 64))
+	}
+	if !f.From.IsZero() {
+		set("from", f.From.Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		set("to", f.To.Format(time.RFC3339))
+	}
+	if f.Limit > 0 {
+		set("limit", strconv.Itoa(f.Limit))
+	}
+
+	var records []audit.Record
+	err := c.get(ctx, "/v1/admin/audit", q, &records)
+	return records, err
+}
+
+// Limits returns the statistics of the route limiters
+func (c *Client) Limits(ctx context.Context) ([]LimiterStats, error) {
+	var stats []LimiterStats
+	err := c.get(ctx, "/v1/admin/limits", nil, &stats)
+	return stats, err
+}
+
+// Reload reloads the configuration of the server and returns the
+// settings it applies. It is not retried.
+func (c *Client) Reload(ctx context.Context) (Settings, error) {
+	var s Settings
+	err := c.do(ctx, call{method: http.MethodPost, path: "/v1/admin/reload"}, &s)
+	return s, err
+}
+
+// The calls below are served by the admin listener, they require a
+// Client of its address authenticated WithToken of the admin token.
+
+// Config returns the effective configuration of the server
+func (c *Client) Config(ctx context.Context) (json.RawMessage, error) {
+	var cfg json.RawMessage
+	err := c.get(ctx, "/config", nil, &cfg)
+	return cfg, err
+}
+
+// LogLevel returns the log level of the server
+func (c *Client) LogLevel(ctx context.Context) (string, error) {
+	var l struct {
+		Level string `json:"level"`
+	}
+	err := c.get(ctx, "/log/level", nil, &l)
+	return l.Level, err
+}
+
+// SetLogLevel changes the log level of the server
+func (c *Client) SetLogLevel(ctx context.Context, level string) error {
+	in := struct {
+		Level string `json:"level"`
+	}{level}
+	return c.do(ctx, call{method: http.MethodPut, path: "/log/level", in: in, idempotent: true}, nil)
+}
+
+// Features reports which optional features the server enables
+func (c *Client) Features(ctx context.Context) (map[string]bool, error) {
+	var features map[string]bool
+	err := c.get(ctx, "/features", nil, &features)
+	return features, err
+}
+
+// SetFeature enables or disables the named feature, and returns the
+// features the server enables
+func (c *Client) SetFeature(ctx context.Context, name string, enabled bool) (map[string]bool, error) {
+	in := struct {
+		Enabled bool `json:"enabled"`
+	}{enabled}
+	var features map[string]bool
+	err := c.do(ctx, call{method: http.MethodPut, path: "/features/" + name, in: in, idempotent: true}, &features)
+	return features, err
+}
+
+// get fetches path into out
+func (c *Client) get(ctx context.Context, path string, q url.Values, out interface{}) error {
+	return c.do(ctx, call{method: http.MethodGet, path: path, query: q, idempotent: true}, out)
+}
@@ -0,0 +1,269 @@
+// Package client is a Go client of the DNS API. It encodes requests the
+// way the server expects them, retries idempotent calls that failed for
+// a transient reason with jittered exponential backoff, and decodes the
+// ErrorResponse of failed requests into an *Error.
+//
+//	c, err := client.New("https://dns.example", client.WithAPIKey(key))
+//	if err != nil {
+//		return err
+//	}
+//	loc, err := c.Locate(ctx, client.Drone, client.CoordsVelocity{X: 123.12, Y: 456.56, Z: 789.89, Vel: 20})
+//	if errors.Is(err, client.ErrInvalidRequest) {
+//		...
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/timolinn/dns/pkg/auth"
+)
+
+// RetryPolicy configures how idempotent calls are retried when the
+// server cannot be reached or answers 429, 502, 503 or 504.
+type RetryPolicy struct {
+	// Retries is the number of attempts after the first one.
+	Retries int
+	// MinBackoff is the longest wait before the first retry, the wait
+	// doubles with every retry up to MaxBackoff. Waits are picked at
+	// random up to that bound, so that clients failing together do
+	// not retry together.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is the retry policy of a Client
+var DefaultRetryPolicy = RetryPolicy{
+	Retries:    3,
+	MinBackoff: 100 * time.Millisecond,
+	MaxBackoff: 2 * time.Second,
+}
+
+// Client calls a DNS server, it is safe for concurrent use
+type Client struct {
+	base   *url.URL
+	http   *http.Client
+	header http.Header
+	retry  RetryPolicy
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with hc, eg. to configure TLS
+// client certificates or timeouts. http.DefaultClient is used otherwise.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.http = hc
+	}
+}
+
+// WithAPIKey authenticates every request with an API key
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.header.Set(auth.HeaderAPIKey, key)
+	}
+}
+
+// WithToken authenticates every request with a bearer JWT
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// WithRetry replaces the DefaultRetryPolicy, a zero RetryPolicy
+// disables retries
+func WithRetry(p RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+// New constructs a Client of the server at baseURL
+func New(baseURL string, opts ...Option) (*Client, error) {
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing base URL")
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, errors.Errorf("base URL %q: requires an http or https URL", baseURL)
+	}
+
+	c := &Client{
+		base:   base,
+		http:   http.DefaultClient,
+		header: make(http.Header),
+		retry:  DefaultRetryPolicy,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// call describes a request to the API
+type call struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	in     interface{}
+
+	// idempotent calls are retried
+	idempotent bool
+	// failureBody decodes the body of failed requests into out too
+	failureBody bool
+}
+
+// do sends the call, retrying it per the retry policy when it is
+// idempotent, and decodes the response into out, or copies it when out
+// is a *[]byte. Failed requests are returned as an *Error.
+func (c *Client) do(ctx context.Context, cl call, out interface{}) error {
+	var body []byte
+	if cl.in != nil {
+		var err error
+		if body, err = json.Marshal(cl.in); err != nil {
+			return errors.Wrap(err, "encoding request")
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, cl, body)
+		retry := cl.idempotent && attempt < c.retry.Retries && ctx.Err() == nil
+		if err != nil {
+			if !retry {
+				return err
+			}
+			if err := c.backoff(ctx, attempt, 0); err != nil {
+				return err
+			}
+			continue
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return errors.Wrap(err, "reading response")
+		}
+		if resp.StatusCode < http.StatusBadRequest {
+			if out == nil || len(data) == 0 {
+				return nil
+			}
+			// the body of responses that are not JSON is returned as is
+			if raw, ok := out.(*[]byte); ok {
+				*raw = data
+				return nil
+			}
+			return errors.Wrap(json.Unmarshal(data, out), "decoding response")
+		}
+
+		if retry && transient(resp.StatusCode) {
+			if err := c.backoff(ctx, attempt, retryAfter(resp)); err != nil {
+				return err
+			}
+			continue
+		}
+		if cl.failureBody && out != nil {
+			json.Unmarshal(data, out)
+		}
+		return newError(resp, data)
+	}
+}
+
+// send sends one attempt of the call
+func (c *Client) send(ctx context.Context, cl call, body []byte) (*http.Response, error) {
+	u := *c.base
+	u.Path += cl.path
+	u.RawQuery = cl.query.Encode()
+
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(cl.method, u.String(), r)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	for k, v := range cl.header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.http.Do(req)
+}
+
+// backoff waits before retrying the attempt, for the longest of a
+// jittered exponential backoff and the wait the server asked for
+func (c *Client) backoff(ctx context.Context, attempt int, serverWait time.Duration) error {
+	bound := c.retry.MinBackoff << uint(attempt)
+	if bound > c.retry.MaxBackoff || bound <= 0 {
+		bound = c.retry.MaxBackoff
+	}
+	var wait time.Duration
+	if bound > 0 {
+		c.mu.Lock()
+		wait = time.Duration(c.rand.Int63n(int64(bound) + 1))
+		c.mu.Unlock()
+	}
+	if serverWait > wait {
+		wait = serverWait
+	}
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// transient reports whether a request answered status may succeed
+// when retried
+func transient(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns the wait asked for in the Retry-After header,
+// given in seconds
+func retryAfter(resp *http.Response) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// idempotencyKey returns a header carrying a new Idempotency-Key, so
+// that a retried POST is answered once by servers that honor it
+func idempotencyKey() http.Header {
+	return http.Header{"Idempotency-Key": {uuid.NewV4().String()}}
+}
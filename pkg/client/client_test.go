@@ -0,0 +1,253 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/timolinn/dns/cmd/api/handlers"
+	"github.com/timolinn/dns/pkg/auth"
+	"github.com/timolinn/dns/pkg/client"
+	"github.com/timolinn/dns/pkg/health"
+)
+
+var cv = client.CoordsVelocity{X: 123.12, Y: 456.56, Z: 789.89, Vel: 20}
+
+// fastRetry keeps the retry tests short
+var fastRetry = client.RetryPolicy{Retries: 3, MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+// newServer serves an App built by handlers.Register, failing the first
+// failures requests with a 503 before they reach it
+func newServer(t *testing.T, failures int32, opts ...handlers.Option) (*httptest.Server, *int32) {
+	shutdown := make(chan os.Signal, 1)
+	logger := log.New(os.Stdout, "TEST : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	app := handlers.Register(shutdown, logger, opts...)
+
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) <= failures {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"shedding load"}`))
+			return
+		}
+		app.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &attempts
+}
+
+func newClient(t *testing.T, url string, opts ...client.Option) *client.Client {
+	c, err := client.New(url, append([]client.Option{client.WithRetry(fastRetry)}, opts...)...)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	return c
+}
+
+func TestLocate(t *testing.T) {
+	srv, _ := newServer(t, 0)
+	c := newClient(t, srv.URL)
+	ctx := context.Background()
+
+	for _, system := range []client.System{client.Drone, client.Ship} {
+		t.Run("should locate a "+string(system), func(t *testing.T) {
+			loc, err := c.Locate(ctx, system, cv)
+			if err != nil {
+				t.Fatalf("expected nil-err got %s", err)
+			}
+			if loc != 1389.57 {
+				t.Errorf("Should locate 1389.57, got %v", loc)
+			}
+		})
+	}
+
+	t.Run("should decode validation errors", func(t *testing.T) {
+		_, err := c.Locate(ctx, client.Drone, client.CoordsVelocity{X: 1, Y: 2, Z: 3})
+		var e *client.Error
+		if !errors.As(err, &e) {
+			t.Fatalf("Should return an *Error, got %v", err)
+		}
+		if e.StatusCode != http.StatusUnprocessableEntity || !errors.Is(err, client.ErrInvalidRequest) {
+			t.Errorf("Should receive status code %d, got %d", http.StatusUnprocessableEntity, e.StatusCode)
+		}
+		if msg, ok := e.Field("vel"); !ok || msg == "" {
+			t.Errorf("Should report the vel field, got %+v", e.Fields)
+		}
+		if e.RequestID == "" {
+			t.Error("Should report the request ID")
+		}
+	})
+
+	t.Run("should reject unknown systems", func(t *testing.T) {
+		_, err := c.Locate(ctx, "ultradrone", cv)
+		if !errors.Is(err, client.ErrInvalidRequest) {
+			t.Errorf("Should return ErrInvalidRequest, got %v", err)
+		}
+	})
+}
+
+func TestAuth(t *testing.T) {
+	store := auth.NewKeyStore()
+	_, adminKey, err := store.Create("admin", nil, true)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	_, droneKey, err := store.Create("alpha", []string{"drone"}, false)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	srv, _ := newServer(t, 0, handlers.WithKeyStore(store))
+	ctx := context.Background()
+
+	t.Run("should map auth failures to errors", func(t *testing.T) {
+		if _, err := newClient(t, srv.URL).Locate(ctx, client.Drone, cv); !errors.Is(err, client.ErrUnauthorized) {
+			t.Errorf("Should return ErrUnauthorized, got %v", err)
+		}
+		drone := newClient(t, srv.URL, client.WithAPIKey(droneKey))
+		if _, err := drone.Locate(ctx, client.Ship, cv); !errors.Is(err, client.ErrForbidden) {
+			t.Errorf("Should return ErrForbidden, got %v", err)
+		}
+		if _, err := drone.Keys(ctx); !errors.Is(err, client.ErrForbidden) {
+			t.Errorf("Should return ErrForbidden, got %v", err)
+		}
+	})
+
+	t.Run("should manage keys", func(t *testing.T) {
+		admin := newClient(t, srv.URL, client.WithAPIKey(adminKey))
+		issued, err := admin.CreateKey(ctx, client.NewKey{Fleet: "beta", Systems: []string{"ship"}})
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		ship := newClient(t, srv.URL, client.WithAPIKey(issued.Secret))
+		if _, err := ship.Locate(ctx, client.Ship, cv); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+
+		keys, err := admin.Keys(ctx)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if len(keys) != 3 {
+			t.Errorf("Should list 3 keys, got %d", len(keys))
+		}
+
+		rotated, err := admin.RotateKey(ctx, issued.ID)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if _, err := ship.Locate(ctx, client.Ship, cv); !errors.Is(err, client.ErrUnauthorized) {
+			t.Errorf("Should reject the rotated secret, got %v", err)
+		}
+
+		if _, err := admin.RevokeKey(ctx, rotated.ID); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if _, err := admin.RotateKey(ctx, rotated.ID); !errors.Is(err, client.ErrConflict) {
+			t.Errorf("Should return ErrConflict, got %v", err)
+		}
+		if _, err := admin.RotateKey(ctx, "unknown"); !errors.Is(err, client.ErrNotFound) {
+			t.Errorf("Should return ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should retry idempotent calls", func(t *testing.T) {
+		srv, attempts := newServer(t, 2)
+		if _, err := newClient(t, srv.URL).Locate(ctx, client.Drone, cv); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if got := atomic.LoadInt32(attempts); got != 3 {
+			t.Errorf("Should make 3 attempts, got %d", got)
+		}
+	})
+
+	t.Run("should give up after the retries", func(t *testing.T) {
+		srv, attempts := newServer(t, 10)
+		err := newClient(t, srv.URL).Live(ctx)
+		if !errors.Is(err, client.ErrUnavailable) {
+			t.Errorf("Should return ErrUnavailable, got %v", err)
+		}
+		if got := atomic.LoadInt32(attempts); got != 4 {
+			t.Errorf("Should make 4 attempts, got %d", got)
+		}
+	})
+
+	t.Run("should not retry creating keys", func(t *testing.T) {
+		store := auth.NewKeyStore()
+		_, adminKey, _ := store.Create("admin", nil, true)
+		srv, attempts := newServer(t, 1, handlers.WithKeyStore(store))
+		_, err := newClient(t, srv.URL, client.WithAPIKey(adminKey)).CreateKey(ctx, client.NewKey{Fleet: "beta"})
+		if !errors.Is(err, client.ErrUnavailable) {
+			t.Errorf("Should return ErrUnavailable, got %v", err)
+		}
+		if got := atomic.LoadInt32(attempts); got != 1 {
+			t.Errorf("Should make 1 attempt, got %d", got)
+		}
+	})
+
+	t.Run("should stop retrying once the context is done", func(t *testing.T) {
+		srv, _ := newServer(t, 10)
+		slow := client.RetryPolicy{Retries: 3, MinBackoff: time.Minute, MaxBackoff: time.Minute}
+		c := newClient(t, srv.URL, client.WithRetry(slow))
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		if err := c.Live(ctx); err != context.DeadlineExceeded {
+			t.Errorf("Should return context.DeadlineExceeded, got %v", err)
+		}
+	})
+}
+
+func TestSector(t *testing.T) {
+	live := handlers.NewLive(handlers.Settings{Sector: 2.5})
+	srv, _ := newServer(t, 1, handlers.WithLive(live))
+	c := newClient(t, srv.URL)
+
+	sector, err := c.Sector(context.Background())
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	if sector != 2.5 {
+		t.Errorf("Should return sector 2.5, got %v", sector)
+	}
+}
+
+func TestProbes(t *testing.T) {
+	checker := health.New()
+	srv, _ := newServer(t, 0, handlers.WithHealth(checker))
+	c := newClient(t, srv.URL)
+	ctx := context.Background()
+
+	if err := c.Live(ctx); err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	if report, err := c.Ready(ctx); err != nil || !report.Ready() {
+		t.Fatalf("Should be ready, got %v", err)
+	}
+
+	checker.Drain()
+	report, err := c.Ready(ctx)
+	if !errors.Is(err, client.ErrUnavailable) {
+		t.Errorf("Should return ErrUnavailable, got %v", err)
+	}
+	if report.Ready() || report.Status == "" {
+		t.Errorf("Should return the report of a draining server, got %+v", report)
+	}
+
+	info, err := c.Version(ctx)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	if info.Version == "" {
+		t.Error("Should report the server version")
+	}
+}
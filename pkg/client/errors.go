@@ -0,0 +1,95 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/timolinn/dns/pkg/web"
+)
+
+// Errors matched by an *Error with errors.Is, by its status code
+var (
+	ErrInvalidRequest = errors.New("invalid request") // 400 and 422
+	ErrUnauthorized   = errors.New("unauthorized")    // 401
+	ErrForbidden      = errors.New("forbidden")       // 403
+	ErrNotFound       = errors.New("not found")       // 404
+	ErrConflict       = errors.New("conflict")        // 409
+	ErrRateLimited    = errors.New("rate limited")    // 429
+	ErrUnavailable    = errors.New("unavailable")     // 503
+)
+
+// FieldError is the error of a request field failing validation
+type FieldError = web.FieldError
+
+// Error is a request the server answered with an error status
+type Error struct {
+	StatusCode int
+	Message    string
+	Fields     []FieldError
+	RequestID  string
+}
+
+// newError decodes the ErrorResponse body of resp, falling back to the
+// body as text when it is not one
+func newError(resp *http.Response, body []byte) *Error {
+	e := &Error{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get(web.HeaderRequestID),
+	}
+	var er web.ErrorResponse
+	if err := json.Unmarshal(body, &er); err == nil && er.Error != "" {
+		e.Message, e.Fields = er.Error, er.Fields
+		return e
+	}
+	e.Message = strings.TrimSpace(string(body))
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%d %s", e.StatusCode, e.Message)
+	var fields []string
+	for _, f := range e.Fields {
+		fields = append(fields, f.Error)
+	}
+	if len(fields) > 0 {
+		msg += ": " + strings.Join(fields, ", ")
+	}
+	return msg
+}
+
+// Is matches the sentinel error of the status code
+func (e *Error) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return target == ErrInvalidRequest
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusForbidden:
+		return target == ErrForbidden
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusConflict:
+		return target == ErrConflict
+	case http.StatusTooManyRequests:
+		return target == ErrRateLimited
+	case http.StatusServiceUnavailable:
+		return target == ErrUnavailable
+	}
+	return false
+}
+
+// Field returns the error of the named request field, if any
+func (e *Error) Field(name string) (string, bool) {
+	for _, f := range e.Fields {
+		if f.Field == name {
+			return f.Error, true
+		}
+	}
+	return "", false
+}
@@ -1 +1,471 @@
+// Package config describes the settings of the DNS server and loads them
+// in layers: defaults, then a YAML, TOML or JSON file, then DNS_*
+// environment variables, then command line flags, each layer overriding
+// the previous one.
 package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/timolinn/dns/middleware"
+	"github.com/timolinn/dns/pkg/listener"
+	"github.com/timolinn/dns/pkg/tlsutil"
+)
+
+// redacted replaces secrets in printed configurations
+const redacted = "REDACTED"
+
+// Systems lists the system types DNS knows how to navigate
+var Systems = []string{"drone", "ship"}
+
+// Config holds every setting of the DNS server. Each field is named by
+// its json tag in files, by DNS_ followed by the upper cased path of
+// json tags in the environment, eg. DNS_SERVER_ADDR, and by its flag
+// tag on the command line. Fields tagged secret are redacted when the
+// configuration is printed.
+type Config struct {
+	Server      Server      `json:"server"`
+	Auth        Auth        `json:"auth"`
+	Navigation  Navigation  `json:"navigation"`
+	Timeouts    Timeouts    `json:"timeouts"`
+	Limits      Limits      `json:"limits"`
+	CORS        CORS        `json:"cors"`
+	Compression Compression `json:"compression"`
+	Idempotency Idempotency `json:"idempotency"`
+	Log         Log         `json:"log"`
+	Admin       Admin       `json:"admin"`
+	GRPC        GRPC        `json:"grpc"`
+}
+
+// Server configures the HTTP listener
+type Server struct {
+	Addr           string   `json:"addr" flag:"addr" usage:"define server address"`
+	Listen         []string `json:"listen" flag:"listen" usage:"comma separated listeners replacing -addr: tcp://, tls://, unix://, fd:// or systemd://"`
+	ReadTimeout    Duration `json:"read_timeout" flag:"readtimeout" usage:"sets the read timeout, bare numbers are seconds"`
+	WriteTimeout   Duration `json:"write_timeout" flag:"writetimeout" usage:"sets the write timeout, bare numbers are seconds"`
+	ReadyDelay     Duration `json:"ready_delay" flag:"ready-delay" usage:"keeps serving this long once readiness fails on shutdown, before draining"`
+	DrainTimeout   Duration `json:"drain_timeout" flag:"drain-timeout" usage:"waits this long for in-flight requests on shutdown before closing connections"`
+	UpgradeTimeout Duration `json:"upgrade_timeout" flag:"upgrade-timeout" usage:"waits this long for the new process to report ready on SIGUSR2"`
+	TLS            TLS      `json:"tls"`
+}
+
+// TLS configures HTTPS and client certificates
+type TLS struct {
+	Cert       string `json:"cert" flag:"tls-cert" usage:"path to the TLS certificate, enables HTTPS"`
+	Key        string `json:"key" flag:"tls-key" usage:"path to the TLS private key"`
+	ClientCA   string `json:"client_ca" flag:"client-ca" usage:"path to the CA bundle verifying client certificates"`
+	ClientAuth string `json:"client_auth" flag:"client-auth" usage:"client certificate policy: 'none', 'request' or 'require'"`
+
+	MinVersion   string   `json:"min_version" flag:"tls-min-version" usage:"minimum TLS version: '1.0', '1.1', '1.2' or '1.3'"`
+	CipherSuites []string `json:"cipher_suites" flag:"tls-cipher-suites" usage:"comma separated IANA names of the TLS 1.2 cipher suites allowed, Go's defaults when empty"`
+	HTTP2        bool     `json:"http2" flag:"http2" usage:"serve HTTP/2 over TLS"`
+	Reload       Duration `json:"reload" flag:"tls-reload" usage:"checks the certificate files for changes at this interval, 0 disables it"`
+}
+
+// Auth configures how callers are authenticated
+type Auth struct {
+	Keys           string `json:"keys" flag:"keys" usage:"path to the API key store, enables API key authentication"`
+	JWKS           string `json:"jwks" flag:"jwks" usage:"path to a JWKS file, enables JWT bearer authentication"`
+	JWTSecret      string `json:"jwt_secret" flag:"jwt-secret" secret:"true" usage:"shared secret of HS256 JWT bearer tokens, enables JWT bearer authentication"`
+	JWTIssuer      string `json:"jwt_issuer" flag:"jwt-issuer" usage:"required issuer of JWT bearer tokens"`
+	JWTAudience    string `json:"jwt_audience" flag:"jwt-audience" usage:"required audience of JWT bearer tokens"`
+	SystemOverride bool   `json:"system_override" flag:"system-override" usage:"let the caller identity override a mismatching X-System-Type header"`
+}
+
+// Navigation configures the sector served and the systems navigated
+type Navigation struct {
+	Sector    float64  `json:"sector" flag:"sector" usage:"sets the ID of the sector served"`
+	Systems   []string `json:"systems" flag:"systems" usage:"comma separated system types served"`
+	CacheSize int      `json:"cache_size" flag:"cache-size" usage:"sets how many navigation results are memoized, 0 disables the cache"`
+	CacheTTL  Duration `json:"cache_ttl" flag:"cache-ttl" usage:"sets how long navigation results are memoized"`
+}
+
+// Timeouts configures request deadlines
+type Timeouts struct {
+	Request Duration `json:"request" flag:"timeout" usage:"sets the deadline of every request, keep it below the write timeout"`
+	Routes  Routes   `json:"routes" flag:"route-timeouts" usage:"comma separated per route deadlines eg. '/v1/locate=2s'"`
+}
+
+// Limits configures the adaptive concurrency limit of each route
+type Limits struct {
+	Enabled bool     `json:"enabled" flag:"limit" usage:"shed load with an adaptive concurrency limit per route"`
+	Initial int      `json:"initial" flag:"limit-initial" usage:"sets the initial concurrency limit of each route"`
+	Max     int      `json:"max" flag:"limit-max" usage:"sets the maximum concurrency limit of each route"`
+	Queue   int      `json:"queue" flag:"limit-queue" usage:"sets how many requests may queue on each route"`
+	Latency Duration `json:"latency" flag:"limit-latency" usage:"sets the latency above which the concurrency limit decreases"`
+}
+
+// CORS configures which browser origins may call DNS
+type CORS struct {
+	Origins     []string `json:"origins" flag:"cors-origins" usage:"comma separated origins allowed to call DNS from a browser, enables CORS"`
+	Methods     []string `json:"methods" flag:"cors-methods" usage:"comma separated methods allowed by CORS"`
+	Headers     []string `json:"headers" flag:"cors-headers" usage:"comma separated request headers allowed by CORS"`
+	Credentials bool     `json:"credentials" flag:"cors-credentials" usage:"allow CORS requests with credentials"`
+}
+
+// Compression configures response compression
+type Compression struct {
+	Enabled bool `json:"enabled" flag:"compress" usage:"compress responses for clients that accept gzip or deflate"`
+	MinSize int  `json:"min_size" flag:"compress-min" usage:"sets the minimum response size in bytes to compress"`
+}
+
+// Idempotency configures the replay of Idempotency-Key requests
+type Idempotency struct {
+	TTL     Duration `json:"ttl" flag:"idempotency-ttl" usage:"sets how long responses are replayed for a repeated Idempotency-Key, 0 disables it"`
+	MaxKeys int      `json:"max_keys" flag:"idempotency-max-keys" usage:"sets how many Idempotency-Keys are kept, the oldest responses are dropped first"`
+	Lease   Duration `json:"lease" flag:"idempotency-lease" usage:"sets how long a key stays reserved by a request that did not complete"`
+}
+
+// Log configures the request log and the logs kept apart from it
+type Log struct {
+	Level  string    `json:"level" flag:"log-level" usage:"request log level: 'debug', 'info', 'warn' or 'error'"`
+	Access AccessLog `json:"access"`
+	Audit  AuditLog  `json:"audit"`
+	Record string    `json:"record" flag:"record" usage:"path to a capture file recording every request and response for cmd/replay"`
+}
+
+// AccessLog configures the access log and its rotation
+type AccessLog struct {
+	Path       string   `json:"path" flag:"access-log" usage:"path to the access log file, '-' writes it to stdout"`
+	Format     string   `json:"format" flag:"access-log-format" usage:"access log format: 'common', 'combined' or 'json'"`
+	MaxSize    int      `json:"max_size" flag:"access-log-max-size" usage:"rotates the access log once it reaches this many megabytes"`
+	Rotate     Duration `json:"rotate" flag:"access-log-rotate" usage:"rotates the access log at this interval, 0 disables it"`
+	Compress   bool     `json:"compress" flag:"access-log-compress" usage:"gzips rotated access logs"`
+	MaxBackups int      `json:"max_backups" flag:"access-log-max-backups" usage:"sets how many rotated access logs are kept, 0 keeps them all"`
+	MaxAge     Duration `json:"max_age" flag:"access-log-max-age" usage:"removes rotated access logs older than this, 0 keeps them all"`
+}
+
+// AuditLog configures the audit trail of locate requests
+type AuditLog struct {
+	Path string `json:"path" flag:"audit-log" usage:"path to the append-only audit log of locate requests"`
+	Sync bool   `json:"sync" flag:"audit-sync" usage:"flush every audit record to disk before answering"`
+}
+
+// Admin configures the admin listener
+type Admin struct {
+	Addr  string `json:"addr" flag:"admin-addr" usage:"address of the admin listener serving pprof, expvar and runtime controls, disabled when empty"`
+	Token string `json:"token" flag:"admin-token" secret:"true" usage:"bearer token required by the admin listener"`
+}
+
+// GRPC configures the gRPC listener
+type GRPC struct {
+	Addr     string `json:"addr" flag:"grpc-addr" usage:"address of the gRPC listener serving the Navigation and health services, disabled when empty"`
+	MaxBatch int    `json:"max_batch" flag:"grpc-max-batch" usage:"sets how many requests a gRPC BatchLocate call may hold"`
+}
+
+// Listeners returns the listeners the server accepts connections on,
+// those of server.listen or else server.addr, serving TLS when
+// server.tls.cert is set.
+func (s Server) Listeners() []listener.Spec {
+	if len(s.Listen) == 0 {
+		if s.TLS.Cert != "" {
+			return []listener.Spec{{Scheme: listener.TLS, Address: s.Addr}}
+		}
+		return []listener.Spec{{Scheme: listener.TCP, Address: s.Addr}}
+	}
+	var specs []listener.Spec
+	for _, l := range s.Listen {
+		// the specs were checked when the configuration was validated
+		spec, _ := listener.Parse(l)
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// Default returns the configuration used when nothing is overridden
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:           ":8080",
+			ReadTimeout:    Duration(5 * time.Second),
+			WriteTimeout:   Duration(10 * time.Second),
+			DrainTimeout:   Duration(5 * time.Second),
+			UpgradeTimeout: Duration(30 * time.Second),
+			TLS: TLS{
+				ClientAuth: "request",
+				MinVersion: "1.2",
+				HTTP2:      true,
+				Reload:     Duration(10 * time.Second),
+			},
+		},
+		Navigation: Navigation{
+			Sector:    1,
+			Systems:   append([]string(nil), Systems...),
+			CacheSize: 0,
+			CacheTTL:  Duration(5 * time.Minute),
+		},
+		Limits: Limits{
+			Initial: middleware.DefaultLimiterConfig.InitialLimit,
+			Max:     middleware.DefaultLimiterConfig.MaxLimit,
+			Queue:   middleware.DefaultLimiterConfig.QueueSize,
+			Latency: Duration(middleware.DefaultLimiterConfig.TargetLatency),
+		},
+		CORS: CORS{
+			Methods: append([]string(nil), middleware.DefaultCORSConfig.AllowedMethods...),
+			Headers: append([]string(nil), middleware.DefaultCORSConfig.AllowedHeaders...),
+		},
+		Compression: Compression{Enabled: false, MinSize: 1024},
+		Idempotency: Idempotency{TTL: 0, MaxKeys: 100000, Lease: Duration(time.Minute)},
+		Log: Log{
+			Level: "info",
+			Access: AccessLog{
+				Format:     middleware.FormatCombined,
+				MaxSize:    100,
+				Rotate:     Duration(24 * time.Hour),
+				Compress:   true,
+				MaxBackups: 7,
+				MaxAge:     Duration(30 * 24 * time.Hour),
+			},
+			Audit: AuditLog{Sync: true},
+		},
+		GRPC: GRPC{MaxBatch: 1000},
+	}
+}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Validate reports every invalid setting at once, as a *ValidationError
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, field, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr", "must not be empty")
+	check(c.Server.ReadTimeout >= 0, "server.read_timeout", "must not be negative")
+	check(c.Server.WriteTimeout >= 0, "server.write_timeout", "must not be negative")
+	check(c.Server.ReadyDelay >= 0, "server.ready_delay", "must not be negative")
+	check(c.Server.DrainTimeout > 0, "server.drain_timeout", "must be positive")
+	check(c.Server.UpgradeTimeout > 0, "server.upgrade_timeout", "must be positive")
+
+	tls := c.Server.TLS
+	check(tls.Cert == "" || tls.Key != "", "server.tls.key", "is required with server.tls.cert")
+	check(tls.Key == "" || tls.Cert != "", "server.tls.cert", "is required with server.tls.key")
+	check(tls.ClientCA == "" || tls.Cert != "", "server.tls.client_ca", "requires server.tls.cert and server.tls.key")
+	if _, err := tlsutil.ClientAuth(tls.ClientAuth); err != nil {
+		check(false, "server.tls.client_auth", "must be 'none', 'request' or 'require', got %q", tls.ClientAuth)
+	}
+	if _, err := tlsutil.ParseVersion(tls.MinVersion); err != nil {
+		check(false, "server.tls.min_version", "must be '1.0', '1.1', '1.2' or '1.3', got %q", tls.MinVersion)
+	}
+	if suites, err := tlsutil.CipherSuites(tls.CipherSuites); err != nil {
+		check(false, "server.tls.cipher_suites", "%s", err)
+	} else if len(suites) > 0 && tls.HTTP2 && !tlsutil.HTTP2CipherSuite(suites) {
+		check(false, "server.tls.cipher_suites", "must include TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 or TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 for HTTP/2")
+	}
+	check(tls.Reload >= 0, "server.tls.reload", "must not be negative")
+	served := len(c.Server.Listen) == 0 || c.GRPC.Addr != ""
+	for _, l := range c.Server.Listen {
+		spec, err := listener.Parse(l)
+		if err != nil {
+			check(false, "server.listen", "%s", err)
+			continue
+		}
+		check(spec.Scheme != listener.TLS || tls.Cert != "", "server.listen", "%s requires server.tls.cert and server.tls.key", spec)
+		served = served || spec.Scheme == listener.TLS
+	}
+	// a certificate nothing serves is most likely a forgotten tls:// listener
+	check(tls.Cert == "" || served, "server.tls.cert", "is served by no listener: server.listen requires a tls:// listener")
+
+	jwt := c.Auth.JWKS != "" || c.Auth.JWTSecret != ""
+	check(c.Auth.JWKS == "" || c.Auth.JWTSecret == "", "auth.jwt_secret", "cannot be combined with auth.jwks")
+	check(c.Auth.JWTIssuer == "" || jwt, "auth.jwt_issuer", "requires auth.jwks or auth.jwt_secret")
+	check(c.Auth.JWTAudience == "" || jwt, "auth.jwt_audience", "requires auth.jwks or auth.jwt_secret")
+
+	check(c.Navigation.Sector > 0, "navigation.sector", "must be positive, got %v", c.Navigation.Sector)
+	check(len(c.Navigation.Systems) > 0, "navigation.systems", "must list at least one system type")
+	for _, s := range c.Navigation.Systems {
+		check(contains(Systems, s), "navigation.systems", "unknown system type %q: requires one of %s", s, strings.Join(Systems, ", "))
+	}
+	check(c.Navigation.CacheSize >= 0, "navigation.cache_size", "must not be negative")
+	check(c.Navigation.CacheSize == 0 || c.Navigation.CacheTTL > 0, "navigation.cache_ttl", "must be positive when the cache is enabled")
+
+	check(c.Timeouts.Request >= 0, "timeouts.request", "must not be negative")
+	for path, d := range c.Timeouts.Routes {
+		check(strings.HasPrefix(path, "/"), "timeouts.routes", "route %q must start with '/'", path)
+		check(d > 0, "timeouts.routes", "deadline of %s must be positive", path)
+	}
+
+	if c.Limits.Enabled {
+		check(c.Limits.Max >= 1, "limits.max", "must be at least 1")
+		check(c.Limits.Initial >= 1 && c.Limits.Initial <= c.Limits.Max, "limits.initial", "must be between 1 and limits.max (%d)", c.Limits.Max)
+		check(c.Limits.Queue >= 0, "limits.queue", "must not be negative")
+		check(c.Limits.Latency > 0, "limits.latency", "must be positive")
+	}
+
+	check(c.Compression.MinSize >= 0, "compression.min_size", "must not be negative")
+	check(c.Idempotency.TTL >= 0, "idempotency.ttl", "must not be negative")
+	if c.Idempotency.TTL > 0 {
+		check(c.Idempotency.MaxKeys > 0, "idempotency.max_keys", "must be positive when idempotency keys are enabled")
+		check(c.Idempotency.Lease > 0, "idempotency.lease", "must be positive when idempotency keys are enabled")
+	}
+
+	if _, err := middleware.ParseLevel(c.Log.Level); err != nil {
+		check(false, "log.level", "must be 'debug', 'info', 'warn' or 'error', got %q", c.Log.Level)
+	}
+
+	access := c.Log.Access
+	if err := middleware.ValidAccessLogFormat(access.Format); err != nil {
+		check(false, "log.access.format", "must be 'common', 'combined' or 'json', got %q", access.Format)
+	}
+	check(access.MaxSize >= 0, "log.access.max_size", "must not be negative")
+	check(access.Rotate >= 0, "log.access.rotate", "must not be negative")
+	check(access.MaxBackups >= 0, "log.access.max_backups", "must not be negative")
+	check(access.MaxAge >= 0, "log.access.max_age", "must not be negative")
+
+	check(c.Admin.Addr == "" || len(c.Admin.Token) >= 16, "admin.token", "must be at least 16 characters when admin.addr is set")
+	check(c.Admin.Addr == "" || c.Admin.Addr != c.Server.Addr, "admin.addr", "must differ from server.addr")
+
+	check(c.GRPC.Addr == "" || c.GRPC.Addr != c.Server.Addr, "grpc.addr", "must differ from server.addr")
+	check(c.GRPC.Addr == "" || c.GRPC.Addr != c.Admin.Addr, "grpc.addr", "must differ from admin.addr")
+	check(c.GRPC.MaxBatch > 0, "grpc.max_batch", "must be positive")
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration safe to print, with
+// the value of every field tagged secret replaced.
+func (c Config) Redacted() Config {
+	redact(reflect.ValueOf(&c).Elem())
+	return c
+}
+
+func redact(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		f, field := v.Type().Field(i), v.Field(i)
+		switch {
+		case f.Type.Kind() == reflect.Struct:
+			redact(field)
+		case f.Tag.Get("secret") == "true" && field.Kind() == reflect.String && field.String() != "":
+			field.SetString(redacted)
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Duration is a time.Duration written as a Go duration string, eg. "1m30s".
+// Bare numbers are read as seconds.
+type Duration time.Duration
+
+// Duration returns d as a time.Duration
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (d *Duration) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		*d = Duration(secs * float64(time.Second))
+		return nil
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.Errorf("invalid duration %q: requires eg. '500ms', '2s' or '1h'", s)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// UnmarshalJSON accepts durations as strings or numbers of seconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return d.UnmarshalText([]byte(s))
+	}
+	return d.UnmarshalText(data)
+}
+
+// Routes maps route paths to their deadline. On the command line and
+// in the environment it is written as a comma separated list of
+// path=duration pairs.
+type Routes map[string]Duration
+
+func (r Routes) String() string {
+	pairs := make([]string, 0, len(r))
+	for path, d := range r {
+		pairs = append(pairs, path+"="+d.String())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Durations returns the deadlines as time.Durations
+func (r Routes) Durations() map[string]time.Duration {
+	m := make(map[string]time.Duration, len(r))
+	for path, d := range r {
+		m[path] = d.Duration()
+	}
+	return m
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (r *Routes) UnmarshalText(text []byte) error {
+	routes := make(Routes)
+	for _, pair := range strings.Split(string(text), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return errors.Errorf("invalid route timeout %q: requires 'path=duration'", pair)
+		}
+		var d Duration
+		if err := d.UnmarshalText([]byte(kv[1])); err != nil {
+			return errors.Wrapf(err, "invalid route timeout %q", pair)
+		}
+		routes[strings.TrimSpace(kv[0])] = d
+	}
+	*r = routes
+	return nil
+}
+
+// UnmarshalJSON accepts an object of path to duration or a string
+func (r *Routes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return r.UnmarshalText([]byte(s))
+	}
+	var m map[string]Duration
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	*r = m
+	return nil
+}
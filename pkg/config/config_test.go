@@ -0,0 +1,193 @@
+package config_test
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timolinn/dns/pkg/config"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	load := func(args ...string) (config.Config, error) {
+		fs := flag.NewFlagSet("dns", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		return config.Load(fs, args)
+	}
+
+	t.Run("should apply defaults, file, environment and flags in order", func(t *testing.T) {
+		path := write("dns.yaml", `
+server:
+  addr: ":9090"
+  read_timeout: 3
+navigation:
+  sector: 2
+  cache_size: 10
+timeouts:
+  routes:
+    /v1/locate: 500ms
+`)
+		os.Setenv("DNS_NAVIGATION_SECTOR", "3")
+		os.Setenv("DNS_NAVIGATION_CACHE_SIZE", "20")
+		defer os.Unsetenv("DNS_NAVIGATION_SECTOR")
+		defer os.Unsetenv("DNS_NAVIGATION_CACHE_SIZE")
+
+		cfg, err := load("-config", path, "-sector", "4")
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if cfg.Server.Addr != ":9090" || cfg.Server.ReadTimeout.Duration() != 3*time.Second {
+			t.Errorf("file not applied: %+v", cfg.Server)
+		}
+		if cfg.Server.WriteTimeout.Duration() != 10*time.Second {
+			t.Errorf("default not kept: %+v", cfg.Server)
+		}
+		if cfg.Navigation.CacheSize != 20 {
+			t.Errorf("environment not applied: %+v", cfg.Navigation)
+		}
+		if cfg.Navigation.Sector != 4 {
+			t.Errorf("flag not applied: %+v", cfg.Navigation)
+		}
+		if cfg.Timeouts.Routes["/v1/locate"].Duration() != 500*time.Millisecond {
+			t.Errorf("unexpected route timeouts %v", cfg.Timeouts.Routes)
+		}
+	})
+
+	t.Run("should leave compression, idempotency and the cache off unless enabled", func(t *testing.T) {
+		cfg, err := load()
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if cfg.Compression.Enabled || cfg.Idempotency.TTL != 0 || cfg.Navigation.CacheSize != 0 {
+			t.Errorf("want the features off, got %+v %+v %+v", cfg.Compression, cfg.Idempotency, cfg.Navigation)
+		}
+
+		cfg, err = load("-compress", "-idempotency-ttl", "24h", "-cache-size", "10000")
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if !cfg.Compression.Enabled || cfg.Idempotency.TTL.Duration() != 24*time.Hour || cfg.Navigation.CacheSize != 10000 {
+			t.Errorf("want the features on, got %+v %+v %+v", cfg.Compression, cfg.Idempotency, cfg.Navigation)
+		}
+	})
+
+	t.Run("should read TOML and JSON files", func(t *testing.T) {
+		for name, content := range map[string]string{
+			"dns.toml": "[navigation]\nsystems = [\"drone\"]\n",
+			"dns.json": `{"navigation": {"systems": ["drone"]}}`,
+		} {
+			cfg, err := load("-config", write(name, content))
+			if err != nil {
+				t.Fatalf("%s: expected nil-err got %s", name, err)
+			}
+			if strings.Join(cfg.Navigation.Systems, ",") != "drone" {
+				t.Errorf("%s: unexpected systems %v", name, cfg.Navigation.Systems)
+			}
+		}
+	})
+
+	t.Run("should reject unknown settings", func(t *testing.T) {
+		_, err := load("-config", write("typo.yaml", "server:\n  adr: ':80'\n"))
+		if err == nil || !strings.Contains(err.Error(), `unknown field "adr"`) {
+			t.Errorf("want unknown field error, got %v", err)
+		}
+	})
+
+	t.Run("should report every invalid setting", func(t *testing.T) {
+		_, err := load("-systems", "tank", "-tls-cert", "cert.pem", "-access-log-format", "xml", "-listen", "udp://:53", "-grpc-max-batch", "0")
+		verr, ok := err.(*config.ValidationError)
+		if !ok {
+			t.Fatalf("want *config.ValidationError, got %v", err)
+		}
+		if len(verr.Problems) != 6 {
+			t.Errorf("want 6 problems, got %q", verr.Problems)
+		}
+	})
+
+	t.Run("should report a certificate served by no listener", func(t *testing.T) {
+		_, err := load("-tls-cert", "cert.pem", "-tls-key", "key.pem", "-listen", "tcp://:80,unix:///run/dns.sock")
+		verr, ok := err.(*config.ValidationError)
+		if !ok || len(verr.Problems) != 1 || !strings.HasPrefix(verr.Problems[0], "server.tls.cert:") {
+			t.Fatalf("want a server.tls.cert problem, got %v", err)
+		}
+
+		for _, args := range [][]string{
+			{"-tls-cert", "cert.pem", "-tls-key", "key.pem"},
+			{"-tls-cert", "cert.pem", "-tls-key", "key.pem", "-listen", "tcp://:80,tls://:443"},
+			{"-tls-cert", "cert.pem", "-tls-key", "key.pem", "-listen", "tcp://:80", "-grpc-addr", ":9090"},
+		} {
+			if _, err := load(args...); err != nil {
+				t.Errorf("%q: expected nil-err got %s", args, err)
+			}
+		}
+	})
+
+	t.Run("should return an invalid configuration", func(t *testing.T) {
+		cfg, err := load("-systems", "tank")
+		if _, ok := err.(*config.ValidationError); !ok {
+			t.Fatalf("want *config.ValidationError, got %v", err)
+		}
+		if strings.Join(cfg.Navigation.Systems, ",") != "tank" {
+			t.Errorf("flag not applied: %+v", cfg.Navigation)
+		}
+	})
+}
+
+func TestChanged(t *testing.T) {
+	a, b := config.Default(), config.Default()
+	if changed := config.Changed(a, b); len(changed) != 0 {
+		t.Errorf("want no changes, got %q", changed)
+	}
+	b.Navigation.Sector = 2
+	b.Limits.Enabled = true
+	b.Timeouts.Routes = map[string]config.Duration{"/v1/locate": config.Duration(time.Second)}
+	changed := config.Changed(a, b)
+	if strings.Join(changed, ",") != "navigation.sector,timeouts.routes,limits.enabled" {
+		t.Errorf("unexpected changes %q", changed)
+	}
+}
+
+func TestUnknownEnv(t *testing.T) {
+	environ := []string{
+		"DNS_NAVIGATION_SECTOR=2",
+		"DNS_NAVIGATON_SECTOR=3",
+		"DNS_CONFIG=dns.yaml",
+		"DNS_READY_FD=3",
+		"DNS_SERVER_ADR=:80",
+		"DNSCTL_API_KEY=secret",
+		"HOME=/root",
+	}
+	unknown := config.UnknownEnv(environ, "DNS_READY_FD")
+	if strings.Join(unknown, ",") != "DNS_NAVIGATON_SECTOR,DNS_SERVER_ADR" {
+		t.Errorf("unexpected unknown variables %q", unknown)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "hunter2"
+
+	if got := cfg.Redacted().Auth.JWTSecret; got != "REDACTED" {
+		t.Errorf("want secret redacted, got %q", got)
+	}
+	if cfg.Auth.JWTSecret != "hunter2" {
+		t.Errorf("Redacted() modified the configuration")
+	}
+}
@@ -0,0 +1,291 @@
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix prefixes the environment variables overriding settings
+const EnvPrefix = "DNS_"
+
+// EnvConfig names the environment variable holding the path of
+// the configuration file, when -config is not given.
+const EnvConfig = EnvPrefix + "CONFIG"
+
+// Load registers a flag for every setting and a -config flag on fs,
+// parses args and returns the configuration built from the defaults,
+// the configuration file, the DNS_* environment variables and the flags
+// set in args, in that order. The configuration is validated, it is
+// returned along with the *ValidationError when it is invalid, so that
+// it can still be printed.
+func Load(fs *flag.FlagSet, args []string) (Config, error) {
+	cfg := Default()
+
+	path := fs.String("config", os.Getenv(EnvConfig), "path to a YAML, TOML or JSON configuration file")
+	var set []*flagValue
+	fields(reflect.ValueOf(&cfg).Elem(), "", func(f field) {
+		if name := f.tag.Get("flag"); name != "" {
+			fv := &flagValue{field: f.value, def: f.value.Interface()}
+			fs.Var(fv, name, f.tag.Get("usage"))
+			set = append(set, fv)
+		}
+	})
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *path != "" {
+		if err := LoadFile(&cfg, *path); err != nil {
+			return cfg, err
+		}
+	}
+	if err := LoadEnv(&cfg, os.Environ()); err != nil {
+		return cfg, err
+	}
+	for _, fv := range set {
+		if fv.parsed.IsValid() {
+			fv.field.Set(fv.parsed)
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// LoadFile overrides cfg with the settings of a YAML (.yaml, .yml), TOML
+// (.toml) or JSON (.json) file. Settings missing from the file are left
+// untouched and unknown settings are rejected.
+func LoadFile(cfg *Config, path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "reading configuration")
+	}
+
+	var doc interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+		doc = stringKeys(doc)
+	case ".toml":
+		var m map[string]interface{}
+		_, err = toml.Decode(string(data), &m)
+		doc = m
+	case ".json":
+		err = json.Unmarshal(data, &doc)
+	default:
+		return errors.Errorf("unknown configuration format %q: requires .yaml, .yml, .toml or .json", ext)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "parsing %s", path)
+	}
+	if doc == nil {
+		return nil
+	}
+
+	// every format is decoded through JSON so that the json
+	// tags and unmarshalers of Config apply to all of them
+	data, err = json.Marshal(doc)
+	if err != nil {
+		return errors.Wrapf(err, "parsing %s", path)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return errors.Errorf("decoding %s: %s", path, strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return nil
+}
+
+// LoadEnv overrides cfg with the DNS_* variables of environ, a list
+// of KEY=value pairs as returned by os.Environ.
+func LoadEnv(cfg *Config, environ []string) error {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		if i := strings.Index(kv, "="); i > 0 && strings.HasPrefix(kv, EnvPrefix) {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	var err error
+	fields(reflect.ValueOf(cfg).Elem(), "", func(f field) {
+		name := EnvPrefix + strings.ToUpper(strings.Replace(f.path, ".", "_", -1))
+		s, ok := env[name]
+		if !ok || err != nil {
+			return
+		}
+		v, perr := parse(f.value.Type(), s)
+		if perr != nil {
+			err = errors.Wrapf(perr, "invalid %s", name)
+			return
+		}
+		f.value.Set(v)
+	})
+	return err
+}
+
+// UnknownEnv returns the DNS_* variables of environ that set no
+// setting, most likely misspelled ones, leaving out DNS_CONFIG and
+// the variables named by ignore.
+func UnknownEnv(environ []string, ignore ...string) []string {
+	known := map[string]bool{EnvConfig: true}
+	for _, name := range ignore {
+		known[name] = true
+	}
+	var cfg Config
+	fields(reflect.ValueOf(&cfg).Elem(), "", func(f field) {
+		known[EnvPrefix+strings.ToUpper(strings.Replace(f.path, ".", "_", -1))] = true
+	})
+
+	var unknown []string
+	for _, kv := range environ {
+		i := strings.Index(kv, "=")
+		if i > 0 && strings.HasPrefix(kv, EnvPrefix) && !known[kv[:i]] {
+			unknown = append(unknown, kv[:i])
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// Changed returns the paths of the settings that differ between a and
+// b, eg. navigation.sector, in the order they are declared.
+func Changed(a, b Config) []string {
+	values := make(map[string]interface{})
+	fields(reflect.ValueOf(&a).Elem(), "", func(f field) {
+		values[f.path] = f.value.Interface()
+	})
+	var changed []string
+	fields(reflect.ValueOf(&b).Elem(), "", func(f field) {
+		if !reflect.DeepEqual(values[f.path], f.value.Interface()) {
+			changed = append(changed, f.path)
+		}
+	})
+	return changed
+}
+
+// field is a setting of Config
+type field struct {
+	path  string
+	tag   reflect.StructTag
+	value reflect.Value
+}
+
+// fields calls fn with every setting of the struct v, depth first.
+// Settings are identified by the dotted path of their json tags.
+func fields(v reflect.Value, prefix string, fn func(field)) {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		path := prefix + strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.Type.Kind() == reflect.Struct {
+			fields(v.Field(i), path+".", fn)
+			continue
+		}
+		fn(field{path: path, tag: f.Tag, value: v.Field(i)})
+	}
+}
+
+// parse parses s as a value of type t
+func parse(t reflect.Type, s string) (reflect.Value, error) {
+	v := reflect.New(t)
+	if u, ok := v.Interface().(encoding.TextUnmarshaler); ok {
+		return v.Elem(), u.UnmarshalText([]byte(s))
+	}
+
+	s = strings.TrimSpace(s)
+	switch t.Kind() {
+	case reflect.String:
+		v.Elem().SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return v, errors.Errorf("invalid boolean %q", s)
+		}
+		v.Elem().SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return v, errors.Errorf("invalid integer %q", s)
+		}
+		v.Elem().SetInt(int64(n))
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return v, errors.Errorf("invalid number %q", s)
+		}
+		v.Elem().SetFloat(n)
+	case reflect.Slice:
+		list := []string{}
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Elem().Set(reflect.ValueOf(list))
+	default:
+		return v, errors.Errorf("unsupported setting type %s", t)
+	}
+	return v.Elem(), nil
+}
+
+// flagValue is the flag.Value of a setting. The parsed value is only
+// applied once the lower layers are loaded, so that flags win.
+type flagValue struct {
+	field  reflect.Value
+	def    interface{}
+	parsed reflect.Value
+}
+
+func (fv *flagValue) String() string {
+	if fv == nil || fv.def == nil {
+		return ""
+	}
+	if list, ok := fv.def.([]string); ok {
+		return strings.Join(list, ",")
+	}
+	return fmt.Sprint(fv.def)
+}
+
+func (fv *flagValue) Set(s string) error {
+	v, err := parse(fv.field.Type(), s)
+	if err != nil {
+		return err
+	}
+	fv.parsed = v
+	return nil
+}
+
+// IsBoolFlag lets boolean settings be set with a bare -flag
+func (fv *flagValue) IsBoolFlag() bool {
+	return fv.field.Kind() == reflect.Bool
+}
+
+// stringKeys converts the map[interface{}]interface{} decoded
+// by YAML into JSON compatible map[string]interface{}.
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = stringKeys(val)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = stringKeys(v[i])
+		}
+	}
+	return v
+}
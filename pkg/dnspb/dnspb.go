@@ -0,0 +1,6 @@
+// Package dnspb holds the protobuf messages and gRPC stubs of the DNS
+// gRPC API, generated from proto/dns/v1 by protoc-gen-go v1.27.1 and
+// protoc-gen-go-grpc v1.2.0.
+package dnspb
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=github.com/timolinn/dns --go-grpc_out=../.. --go-grpc_opt=module=github.com/timolinn/dns dns/v1/navigation.proto
@@ -0,0 +1,742 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.27.1
+// 	protoc        (unknown)
+// source: dns/v1/navigation.proto
+
+package dnspb
+
+import (
+	status "google.golang.org/genproto/googleapis/rpc/status"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// System is the type of the system locating its databank
+type System int32
+
+const (
+	// SYSTEM_UNSPECIFIED takes the system type bound to the caller's
+	// credentials, when they are bound to exactly one.
+	System_SYSTEM_UNSPECIFIED System = 0
+	System_SYSTEM_DRONE       System = 1
+	System_SYSTEM_SHIP        System = 2
+)
+
+// Enum value maps for System.
+var (
+	System_name = map[int32]string{
+		0: "SYSTEM_UNSPECIFIED",
+		1: "SYSTEM_DRONE",
+		2: "SYSTEM_SHIP",
+	}
+	System_value = map[string]int32{
+		"SYSTEM_UNSPECIFIED": 0,
+		"SYSTEM_DRONE":       1,
+		"SYSTEM_SHIP":        2,
+	}
+)
+
+func (x System) Enum() *System {
+	p := new(System)
+	*p = x
+	return p
+}
+
+func (x System) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (System) Descriptor() protoreflect.EnumDescriptor {
+	return file_dns_v1_navigation_proto_enumTypes[0].Descriptor()
+}
+
+func (System) Type() protoreflect.EnumType {
+	return &file_dns_v1_navigation_proto_enumTypes[0]
+}
+
+func (x System) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use System.Descriptor instead.
+func (System) EnumDescriptor() ([]byte, []int) {
+	return file_dns_v1_navigation_proto_rawDescGZIP(), []int{0}
+}
+
+// Coordinates are the position and velocity of a system, every value
+// is required to be non-zero.
+type Coordinates struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	X   float64 `protobuf:"fixed64,1,opt,name=x,proto3" json:"x,omitempty"`
+	Y   float64 `protobuf:"fixed64,2,opt,name=y,proto3" json:"y,omitempty"`
+	Z   float64 `protobuf:"fixed64,3,opt,name=z,proto3" json:"z,omitempty"`
+	Vel float64 `protobuf:"fixed64,4,opt,name=vel,proto3" json:"vel,omitempty"`
+}
+
+func (x *Coordinates) Reset() {
+	*x = Coordinates{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dns_v1_navigation_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Coordinates) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Coordinates) ProtoMessage() {}
+
+func (x *Coordinates) ProtoReflect() protoreflect.Message {
+	mi := &file_dns_v1_navigation_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Coordinates.ProtoReflect.Descriptor instead.
+func (*Coordinates) Descriptor() ([]byte, []int) {
+	return file_dns_v1_navigation_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Coordinates) GetX() float64 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *Coordinates) GetY() float64 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+func (x *Coordinates) GetZ() float64 {
+	if x != nil {
+		return x.Z
+	}
+	return 0
+}
+
+func (x *Coordinates) GetVel() float64 {
+	if x != nil {
+		return x.Vel
+	}
+	return 0
+}
+
+type LocateRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	System      System       `protobuf:"varint,1,opt,name=system,proto3,enum=dns.v1.System" json:"system,omitempty"`
+	Coordinates *Coordinates `protobuf:"bytes,2,opt,name=coordinates,proto3" json:"coordinates,omitempty"`
+}
+
+func (x *LocateRequest) Reset() {
+	*x = LocateRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dns_v1_navigation_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LocateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LocateRequest) ProtoMessage() {}
+
+func (x *LocateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dns_v1_navigation_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LocateRequest.ProtoReflect.Descriptor instead.
+func (*LocateRequest) Descriptor() ([]byte, []int) {
+	return file_dns_v1_navigation_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *LocateRequest) GetSystem() System {
+	if x != nil {
+		return x.System
+	}
+	return System_SYSTEM_UNSPECIFIED
+}
+
+func (x *LocateRequest) GetCoordinates() *Coordinates {
+	if x != nil {
+		return x.Coordinates
+	}
+	return nil
+}
+
+type LocateResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// location is the databank to upload to
+	Location float64 `protobuf:"fixed64,1,opt,name=location,proto3" json:"location,omitempty"`
+	// sector is the sector the location was computed in
+	Sector float64 `protobuf:"fixed64,2,opt,name=sector,proto3" json:"sector,omitempty"`
+}
+
+func (x *LocateResponse) Reset() {
+	*x = LocateResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dns_v1_navigation_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LocateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LocateResponse) ProtoMessage() {}
+
+func (x *LocateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dns_v1_navigation_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LocateResponse.ProtoReflect.Descriptor instead.
+func (*LocateResponse) Descriptor() ([]byte, []int) {
+	return file_dns_v1_navigation_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *LocateResponse) GetLocation() float64 {
+	if x != nil {
+		return x.Location
+	}
+	return 0
+}
+
+func (x *LocateResponse) GetSector() float64 {
+	if x != nil {
+		return x.Sector
+	}
+	return 0
+}
+
+type BatchLocateRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Requests []*LocateRequest `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+}
+
+func (x *BatchLocateRequest) Reset() {
+	*x = BatchLocateRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dns_v1_navigation_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BatchLocateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchLocateRequest) ProtoMessage() {}
+
+func (x *BatchLocateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dns_v1_navigation_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchLocateRequest.ProtoReflect.Descriptor instead.
+func (*BatchLocateRequest) Descriptor() ([]byte, []int) {
+	return file_dns_v1_navigation_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BatchLocateRequest) GetRequests() []*LocateRequest {
+	if x != nil {
+		return x.Requests
+	}
+	return nil
+}
+
+type BatchLocateResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// results are in the order of the requests
+	Results []*LocateResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+}
+
+func (x *BatchLocateResponse) Reset() {
+	*x = BatchLocateResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dns_v1_navigation_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BatchLocateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchLocateResponse) ProtoMessage() {}
+
+func (x *BatchLocateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dns_v1_navigation_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchLocateResponse.ProtoReflect.Descriptor instead.
+func (*BatchLocateResponse) Descriptor() ([]byte, []int) {
+	return file_dns_v1_navigation_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BatchLocateResponse) GetResults() []*LocateResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+// LocateResult holds the response to a request of a batch, or the
+// status it failed with.
+type LocateResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Response *LocateResponse `protobuf:"bytes,1,opt,name=response,proto3" json:"response,omitempty"`
+	Error    *status.Status  `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (x *LocateResult) Reset() {
+	*x = LocateResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dns_v1_navigation_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LocateResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LocateResult) ProtoMessage() {}
+
+func (x *LocateResult) ProtoReflect() protoreflect.Message {
+	mi := &file_dns_v1_navigation_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LocateResult.ProtoReflect.Descriptor instead.
+func (*LocateResult) Descriptor() ([]byte, []int) {
+	return file_dns_v1_navigation_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *LocateResult) GetResponse() *LocateResponse {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+func (x *LocateResult) GetError() *status.Status {
+	if x != nil {
+		return x.Error
+	}
+	return nil
+}
+
+type GetSectorRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *GetSectorRequest) Reset() {
+	*x = GetSectorRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dns_v1_navigation_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetSectorRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSectorRequest) ProtoMessage() {}
+
+func (x *GetSectorRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dns_v1_navigation_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSectorRequest.ProtoReflect.Descriptor instead.
+func (*GetSectorRequest) Descriptor() ([]byte, []int) {
+	return file_dns_v1_navigation_proto_rawDescGZIP(), []int{6}
+}
+
+type Sector struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id float64 `protobuf:"fixed64,1,opt,name=id,proto3" json:"id,omitempty"`
+	// systems are the system types navigated
+	Systems []System `protobuf:"varint,2,rep,packed,name=systems,proto3,enum=dns.v1.System" json:"systems,omitempty"`
+}
+
+func (x *Sector) Reset() {
+	*x = Sector{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_dns_v1_navigation_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Sector) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sector) ProtoMessage() {}
+
+func (x *Sector) ProtoReflect() protoreflect.Message {
+	mi := &file_dns_v1_navigation_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sector.ProtoReflect.Descriptor instead.
+func (*Sector) Descriptor() ([]byte, []int) {
+	return file_dns_v1_navigation_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Sector) GetId() float64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Sector) GetSystems() []System {
+	if x != nil {
+		return x.Systems
+	}
+	return nil
+}
+
+var File_dns_v1_navigation_proto protoreflect.FileDescriptor
+
+var file_dns_v1_navigation_proto_rawDesc = []byte{
+	0x0a, 0x17, 0x64, 0x6e, 0x73, 0x2f, 0x76, 0x31, 0x2f, 0x6e, 0x61, 0x76, 0x69, 0x67, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x06, 0x64, 0x6e, 0x73, 0x2e, 0x76,
+	0x31, 0x1a, 0x17, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x72, 0x70, 0x63, 0x2f, 0x73, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x49, 0x0a, 0x0b, 0x43, 0x6f,
+	0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74, 0x65, 0x73, 0x12, 0x0c, 0x0a, 0x01, 0x78, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x01, 0x52, 0x01, 0x78, 0x12, 0x0c, 0x0a, 0x01, 0x79, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x01, 0x52, 0x01, 0x79, 0x12, 0x0c, 0x0a, 0x01, 0x7a, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x01, 0x7a, 0x12, 0x10, 0x0a, 0x03, 0x76, 0x65, 0x6c, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x03, 0x76, 0x65, 0x6c, 0x22, 0x6e, 0x0a, 0x0d, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x65, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x26, 0x0a, 0x06, 0x73, 0x79, 0x73, 0x74, 0x65, 0x6d,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0e, 0x2e, 0x64, 0x6e, 0x73, 0x2e, 0x76, 0x31, 0x2e,
+	0x53, 0x79, 0x73, 0x74, 0x65, 0x6d, 0x52, 0x06, 0x73, 0x79, 0x73, 0x74, 0x65, 0x6d, 0x12, 0x35,
+	0x0a, 0x0b, 0x63, 0x6f, 0x6f, 0x72, 0x64, 0x69, 0x6e, 0x61, 0x74, 0x65, 0x73, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x64, 0x6e, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6f,
+	0x72, 0x64, 0x69, 0x6e, 0x61, 0x74, 0x65, 0x73, 0x52, 0x0b, 0x63, 0x6f, 0x6f, 0x72, 0x64, 0x69,
+	0x6e, 0x61, 0x74, 0x65, 0x73, 0x22, 0x44, 0x0a, 0x0e, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x65, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x01, 0x52, 0x06, 0x73, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x22, 0x47, 0x0a, 0x12, 0x42,
+	0x61, 0x74, 0x63, 0x68, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x31, 0x0a, 0x08, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x73, 0x18, 0x01, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x64, 0x6e, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x6f, 0x63,
+	0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x52, 0x08, 0x72, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x73, 0x22, 0x45, 0x0a, 0x13, 0x42, 0x61, 0x74, 0x63, 0x68, 0x4c, 0x6f, 0x63,
+	0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2e, 0x0a, 0x07, 0x72,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x64,
+	0x6e, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x75,
+	0x6c, 0x74, 0x52, 0x07, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x22, 0x6c, 0x0a, 0x0c, 0x4c,
+	0x6f, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x32, 0x0a, 0x08, 0x72,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e,
+	0x64, 0x6e, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x52, 0x08, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x28, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x12,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x72, 0x70, 0x63, 0x2e, 0x53, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x22, 0x12, 0x0a, 0x10, 0x47, 0x65, 0x74,
+	0x53, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x42, 0x0a,
+	0x06, 0x53, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x01, 0x52, 0x02, 0x69, 0x64, 0x12, 0x28, 0x0a, 0x07, 0x73, 0x79, 0x73, 0x74, 0x65,
+	0x6d, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x0e, 0x2e, 0x64, 0x6e, 0x73, 0x2e, 0x76,
+	0x31, 0x2e, 0x53, 0x79, 0x73, 0x74, 0x65, 0x6d, 0x52, 0x07, 0x73, 0x79, 0x73, 0x74, 0x65, 0x6d,
+	0x73, 0x2a, 0x43, 0x0a, 0x06, 0x53, 0x79, 0x73, 0x74, 0x65, 0x6d, 0x12, 0x16, 0x0a, 0x12, 0x53,
+	0x59, 0x53, 0x54, 0x45, 0x4d, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45,
+	0x44, 0x10, 0x00, 0x12, 0x10, 0x0a, 0x0c, 0x53, 0x59, 0x53, 0x54, 0x45, 0x4d, 0x5f, 0x44, 0x52,
+	0x4f, 0x4e, 0x45, 0x10, 0x01, 0x12, 0x0f, 0x0a, 0x0b, 0x53, 0x59, 0x53, 0x54, 0x45, 0x4d, 0x5f,
+	0x53, 0x48, 0x49, 0x50, 0x10, 0x02, 0x32, 0xc4, 0x01, 0x0a, 0x0a, 0x4e, 0x61, 0x76, 0x69, 0x67,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x37, 0x0a, 0x06, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x65, 0x12,
+	0x15, 0x2e, 0x64, 0x6e, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x65, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x64, 0x6e, 0x73, 0x2e, 0x76, 0x31, 0x2e,
+	0x4c, 0x6f, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x46,
+	0x0a, 0x0b, 0x42, 0x61, 0x74, 0x63, 0x68, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x65, 0x12, 0x1a, 0x2e,
+	0x64, 0x6e, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x61, 0x74, 0x63, 0x68, 0x4c, 0x6f, 0x63, 0x61,
+	0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x64, 0x6e, 0x73, 0x2e,
+	0x76, 0x31, 0x2e, 0x42, 0x61, 0x74, 0x63, 0x68, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x65, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x35, 0x0a, 0x09, 0x47, 0x65, 0x74, 0x53, 0x65, 0x63,
+	0x74, 0x6f, 0x72, 0x12, 0x18, 0x2e, 0x64, 0x6e, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74,
+	0x53, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0e, 0x2e,
+	0x64, 0x6e, 0x73, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x42, 0x23, 0x5a,
+	0x21, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x74, 0x69, 0x6d, 0x6f,
+	0x6c, 0x69, 0x6e, 0x6e, 0x2f, 0x64, 0x6e, 0x73, 0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x64, 0x6e, 0x73,
+	0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_dns_v1_navigation_proto_rawDescOnce sync.Once
+	file_dns_v1_navigation_proto_rawDescData = file_dns_v1_navigation_proto_rawDesc
+)
+
+func file_dns_v1_navigation_proto_rawDescGZIP() []byte {
+	file_dns_v1_navigation_proto_rawDescOnce.Do(func() {
+		file_dns_v1_navigation_proto_rawDescData = protoimpl.X.CompressGZIP(file_dns_v1_navigation_proto_rawDescData)
+	})
+	return file_dns_v1_navigation_proto_rawDescData
+}
+
+var file_dns_v1_navigation_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_dns_v1_navigation_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_dns_v1_navigation_proto_goTypes = []interface{}{
+	(System)(0),                 // 0: dns.v1.System
+	(*Coordinates)(nil),         // 1: dns.v1.Coordinates
+	(*LocateRequest)(nil),       // 2: dns.v1.LocateRequest
+	(*LocateResponse)(nil),      // 3: dns.v1.LocateResponse
+	(*BatchLocateRequest)(nil),  // 4: dns.v1.BatchLocateRequest
+	(*BatchLocateResponse)(nil), // 5: dns.v1.BatchLocateResponse
+	(*LocateResult)(nil),        // 6: dns.v1.LocateResult
+	(*GetSectorRequest)(nil),    // 7: dns.v1.GetSectorRequest
+	(*Sector)(nil),              // 8: dns.v1.Sector
+	(*status.Status)(nil),       // 9: google.rpc.Status
+}
+var file_dns_v1_navigation_proto_depIdxs = []int32{
+	0,  // 0: dns.v1.LocateRequest.system:type_name -> dns.v1.System
+	1,  // 1: dns.v1.LocateRequest.coordinates:type_name -> dns.v1.Coordinates
+	2,  // 2: dns.v1.BatchLocateRequest.requests:type_name -> dns.v1.LocateRequest
+	6,  // 3: dns.v1.BatchLocateResponse.results:type_name -> dns.v1.LocateResult
+	3,  // 4: dns.v1.LocateResult.response:type_name -> dns.v1.LocateResponse
+	9,  // 5: dns.v1.LocateResult.error:type_name -> google.rpc.Status
+	0,  // 6: dns.v1.Sector.systems:type_name -> dns.v1.System
+	2,  // 7: dns.v1.Navigation.Locate:input_type -> dns.v1.LocateRequest
+	4,  // 8: dns.v1.Navigation.BatchLocate:input_type -> dns.v1.BatchLocateRequest
+	7,  // 9: dns.v1.Navigation.GetSector:input_type -> dns.v1.GetSectorRequest
+	3,  // 10: dns.v1.Navigation.Locate:output_type -> dns.v1.LocateResponse
+	5,  // 11: dns.v1.Navigation.BatchLocate:output_type -> dns.v1.BatchLocateResponse
+	8,  // 12: dns.v1.Navigation.GetSector:output_type -> dns.v1.Sector
+	10, // [10:13] is the sub-list for method output_type
+	7,  // [7:10] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_dns_v1_navigation_proto_init() }
+func file_dns_v1_navigation_proto_init() {
+	if File_dns_v1_navigation_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_dns_v1_navigation_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Coordinates); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dns_v1_navigation_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LocateRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dns_v1_navigation_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LocateResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dns_v1_navigation_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BatchLocateRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dns_v1_navigation_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BatchLocateResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dns_v1_navigation_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LocateResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dns_v1_navigation_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetSectorRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_dns_v1_navigation_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Sector); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_dns_v1_navigation_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_dns_v1_navigation_proto_goTypes,
+		DependencyIndexes: file_dns_v1_navigation_proto_depIdxs,
+		EnumInfos:         file_dns_v1_navigation_proto_enumTypes,
+		MessageInfos:      file_dns_v1_navigation_proto_msgTypes,
+	}.Build()
+	File_dns_v1_navigation_proto = out.File
+	file_dns_v1_navigation_proto_rawDesc = nil
+	file_dns_v1_navigation_proto_goTypes = nil
+	file_dns_v1_navigation_proto_depIdxs = nil
+}
@@ -0,0 +1,189 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: dns/v1/navigation.proto
+
+package dnspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// NavigationClient is the client API for Navigation service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type NavigationClient interface {
+	// Locate returns the databank location of one system. Invalid
+	// coordinates fail with INVALID_ARGUMENT and a google.rpc.BadRequest
+	// detail naming the fields.
+	Locate(ctx context.Context, in *LocateRequest, opts ...grpc.CallOption) (*LocateResponse, error)
+	// BatchLocate locates many systems at once. Every request gets its own
+	// result, the call only fails when the batch as a whole is rejected.
+	BatchLocate(ctx context.Context, in *BatchLocateRequest, opts ...grpc.CallOption) (*BatchLocateResponse, error)
+	// GetSector reports the sector served and the system types navigated.
+	GetSector(ctx context.Context, in *GetSectorRequest, opts ...grpc.CallOption) (*Sector, error)
+}
+
+type navigationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNavigationClient(cc grpc.ClientConnInterface) NavigationClient {
+	return &navigationClient{cc}
+}
+
+func (c *navigationClient) Locate(ctx context.Context, in *LocateRequest, opts ...grpc.CallOption) (*LocateResponse, error) {
+	out := new(LocateResponse)
+	err := c.cc.Invoke(ctx, "/dns.v1.Navigation/Locate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *navigationClient) BatchLocate(ctx context.Context, in *BatchLocateRequest, opts ...grpc.CallOption) (*BatchLocateResponse, error) {
+	out := new(BatchLocateResponse)
+	err := c.cc.Invoke(ctx, "/dns.v1.Navigation/BatchLocate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *navigationClient) GetSector(ctx context.Context, in *GetSectorRequest, opts ...grpc.CallOption) (*Sector, error) {
+	out := new(Sector)
+	err := c.cc.Invoke(ctx, "/dns.v1.Navigation/GetSector", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NavigationServer is the server API for Navigation service.
+// All implementations must embed UnimplementedNavigationServer
+// for forward compatibility
+type NavigationServer interface {
+	// Locate returns the databank location of one system. Invalid
+	// coordinates fail with INVALID_ARGUMENT and a google.rpc.BadRequest
+	// detail naming the fields.
+	Locate(context.Context, *LocateRequest) (*LocateResponse, error)
+	// BatchLocate locates many systems at once. Every request gets its own
+	// result, the call only fails when the batch as a whole is rejected.
+	BatchLocate(context.Context, *BatchLocateRequest) (*BatchLocateResponse, error)
+	// GetSector reports the sector served and the system types navigated.
+	GetSector(context.Context, *GetSectorRequest) (*Sector, error)
+	mustEmbedUnimplementedNavigationServer()
+}
+
+// UnimplementedNavigationServer must be embedded to have forward compatible implementations.
+type UnimplementedNavigationServer struct {
+}
+
+func (UnimplementedNavigationServer) Locate(context.Context, *LocateRequest) (*LocateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Locate not implemented")
+}
+func (UnimplementedNavigationServer) BatchLocate(context.Context, *BatchLocateRequest) (*BatchLocateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchLocate not implemented")
+}
+func (UnimplementedNavigationServer) GetSector(context.Context, *GetSectorRequest) (*Sector, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSector not implemented")
+}
+func (UnimplementedNavigationServer) mustEmbedUnimplementedNavigationServer() {}
+
+// UnsafeNavigationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NavigationServer will
+// result in compilation errors.
+type UnsafeNavigationServer interface {
+	mustEmbedUnimplementedNavigationServer()
+}
+
+func RegisterNavigationServer(s grpc.ServiceRegistrar, srv NavigationServer) {
+	s.RegisterService(&Navigation_ServiceDesc, srv)
+}
+
+func _Navigation_Locate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LocateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NavigationServer).Locate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dns.v1.Navigation/Locate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NavigationServer).Locate(ctx, req.(*LocateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Navigation_BatchLocate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchLocateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NavigationServer).BatchLocate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dns.v1.Navigation/BatchLocate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NavigationServer).BatchLocate(ctx, req.(*BatchLocateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Navigation_GetSector_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSectorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NavigationServer).GetSector(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dns.v1.Navigation/GetSector",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NavigationServer).GetSector(ctx, req.(*GetSectorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Navigation_ServiceDesc is the grpc.ServiceDesc for Navigation service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Navigation_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dns.v1.Navigation",
+	HandlerType: (*NavigationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Locate",
+			Handler:    _Navigation_Locate_Handler,
+		},
+		{
+			MethodName: "BatchLocate",
+			Handler:    _Navigation_BatchLocate_Handler,
+		},
+		{
+			MethodName: "GetSector",
+			Handler:    _Navigation_GetSector_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "dns/v1/navigation.proto",
+}
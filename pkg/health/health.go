@@ -0,0 +1,195 @@
+// Package health reports whether DNS is alive and ready to serve
+// traffic. Readiness aggregates dependency checks, and fails as soon
+// as the server starts shutting down so that load balancers stop
+// routing drones to it before connections are drained.
+package health
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Statuses reported by checks and reports
+const (
+	StatusOK       = "ok"
+	StatusFailing  = "failing"
+	StatusDraining = "draining"
+)
+
+// DefaultTimeout bounds how long a check may take
+const DefaultTimeout = 2 * time.Second
+
+// A Check reports an error when a dependency is unavailable. It must
+// give up once ctx is done.
+type Check func(ctx context.Context) error
+
+// Result is the outcome of a check
+type Result struct {
+	Name     string  `json:"name"`
+	Status   string  `json:"status"`
+	Error    string  `json:"error,omitempty"`
+	Duration float64 `json:"duration_ms"`
+}
+
+// Report is the outcome of every check
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Ready reports whether the report allows serving traffic
+func (r *Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+// Checker runs the registered checks. It is safe for concurrent use.
+type Checker struct {
+	draining int32
+
+	mu      sync.RWMutex
+	checks  map[string]Check
+	timeout time.Duration
+}
+
+// New constructs a Checker running each check for at most DefaultTimeout
+func New() *Checker {
+	return &Checker{checks: make(map[string]Check), timeout: DefaultTimeout}
+}
+
+// Register adds a check named name, replacing any check of that name
+func (c *Checker) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// Drain makes readiness fail from now on
+func (c *Checker) Drain() {
+	atomic.StoreInt32(&c.draining, 1)
+}
+
+// Draining reports whether Drain was called
+func (c *Checker) Draining() bool {
+	return atomic.LoadInt32(&c.draining) == 1
+}
+
+// Check runs every check concurrently and reports their outcome, ordered
+// by name. The report is failing when a check fails, and draining once
+// Drain was called.
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.RLock()
+	names := make([]string, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	checks := c.checks
+	c.mu.RUnlock()
+	sort.Strings(names)
+
+	report := Report{Status: StatusOK, Checks: make([]Result, len(names))}
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string, check Check) {
+			defer wg.Done()
+			report.Checks[i] = c.run(ctx, name, check)
+		}(i, name, checks[name])
+	}
+	wg.Wait()
+
+	for _, res := range report.Checks {
+		if res.Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+	if c.Draining() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, name string, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errors.Wrap(ctx.Err(), "check did not complete")
+	}
+
+	res := Result{
+		Name:     name,
+		Status:   StatusOK,
+		Duration: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if err != nil {
+		res.Status, res.Error = StatusFailing, err.Error()
+	}
+	return res
+}
+
+// Cached runs a check at most once every interval, answering with
+// its last outcome in between, for checks too costly to run on every
+// probe. It is safe for concurrent use.
+type Cached struct {
+	check    Check
+	interval time.Duration
+
+	mu  sync.Mutex
+	at  time.Time
+	err error
+}
+
+// NewCached constructs a Cached running check at most once every interval
+func NewCached(check Check, interval time.Duration) *Cached {
+	return &Cached{check: check, interval: interval}
+}
+
+// Check is the Check to register, running the check when its last
+// outcome is older than the interval.
+func (c *Cached) Check(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.at.IsZero() || time.Since(c.at) >= c.interval {
+		c.err, c.at = c.check(ctx), time.Now()
+	}
+	return c.err
+}
+
+// Set records err as the outcome of the check, when it was learnt
+// another way, until the interval elapses again.
+func (c *Cached) Set(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err, c.at = err, time.Now()
+}
+
+// Writable checks that files can be created in the directories holding
+// paths, so that logs and key stores can be written.
+func Writable(paths ...string) Check {
+	return func(ctx context.Context) error {
+		for _, path := range paths {
+			f, err := ioutil.TempFile(filepath.Dir(path), ".dns-health-")
+			if err != nil {
+				return errors.Wrapf(err, "%s is not writable", filepath.Dir(path))
+			}
+			f.Close()
+			os.Remove(f.Name())
+		}
+		return nil
+	}
+}
@@ -0,0 +1,92 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/timolinn/dns/pkg/health"
+)
+
+func TestChecker(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should be ready when every check passes", func(t *testing.T) {
+		c := health.New()
+		c.Register("config", func(context.Context) error { return nil })
+		c.Register("storage", health.Writable(filepath.Join(os.TempDir(), "audit.log")))
+
+		report := c.Check(ctx)
+		if !report.Ready() || len(report.Checks) != 2 {
+			t.Errorf("unexpected report %+v", report)
+		}
+	})
+
+	t.Run("should report failing checks", func(t *testing.T) {
+		c := health.New()
+		c.Register("config", func(context.Context) error { return nil })
+		c.Register("storage", func(context.Context) error { return errors.New("disk full") })
+
+		report := c.Check(ctx)
+		if report.Status != health.StatusFailing {
+			t.Errorf("want status %s, got %s", health.StatusFailing, report.Status)
+		}
+		if res := report.Checks[1]; res.Name != "storage" || res.Error != "disk full" {
+			t.Errorf("unexpected result %+v", res)
+		}
+	})
+
+	t.Run("should fail checks that do not complete in time", func(t *testing.T) {
+		c := health.New()
+		c.Register("slow", func(ctx context.Context) error {
+			<-ctx.Done()
+			time.Sleep(time.Second)
+			return nil
+		})
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if report := c.Check(ctx); report.Ready() {
+			t.Errorf("unexpected report %+v", report)
+		}
+	})
+
+	t.Run("should not be ready once draining", func(t *testing.T) {
+		c := health.New()
+		c.Drain()
+		if report := c.Check(ctx); report.Status != health.StatusDraining {
+			t.Errorf("want status %s, got %s", health.StatusDraining, report.Status)
+		}
+	})
+}
+
+func TestCached(t *testing.T) {
+	ctx := context.Background()
+	runs := 0
+	c := health.NewCached(func(context.Context) error {
+		runs++
+		return errors.New("invalid configuration")
+	}, 50*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		if err := c.Check(ctx); err == nil {
+			t.Error("want the check error, got nil")
+		}
+	}
+	if runs != 1 {
+		t.Errorf("want 1 run, got %d", runs)
+	}
+
+	c.Set(nil)
+	if err := c.Check(ctx); err != nil || runs != 1 {
+		t.Errorf("want the outcome set without a run, got %v after %d runs", err, runs)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := c.Check(ctx); err == nil || runs != 2 {
+		t.Errorf("want a new run once the interval elapsed, got %v after %d runs", err, runs)
+	}
+}
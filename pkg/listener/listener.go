@@ -0,0 +1,238 @@
+// Package listener opens the listeners DNS accepts connections on:
+// TCP and TLS addresses, Unix domain sockets and listeners inherited
+// from a parent process or from systemd socket activation.
+package listener
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// Listener schemes
+const (
+	TCP     = "tcp"
+	TLS     = "tls"
+	Unix    = "unix"
+	FD      = "fd"
+	Systemd = "systemd"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd
+const listenFdsStart = 3
+
+// Spec describes a listener as scheme://address:
+//
+//	tcp://:8080              a TCP address
+//	tls://:8443              a TCP address serving TLS
+//	unix:///run/dns.sock     a Unix domain socket
+//	fd://3                   an inherited file descriptor
+//	systemd://               every socket passed by systemd
+//	systemd://dns            the sockets systemd passed named dns
+//
+// An address without scheme is a TCP address.
+type Spec struct {
+	Scheme  string
+	Address string
+}
+
+// Parse parses a listener spec
+func Parse(s string) (Spec, error) {
+	i := strings.Index(s, "://")
+	if i < 0 {
+		return Spec{Scheme: TCP, Address: s}, nil
+	}
+	spec := Spec{Scheme: strings.ToLower(s[:i]), Address: s[i+3:]}
+	switch spec.Scheme {
+	case TCP, TLS, Unix:
+		if spec.Address == "" {
+			return spec, errors.Errorf("listener %q: missing address", s)
+		}
+	case FD:
+		if n, err := strconv.Atoi(spec.Address); err != nil || n < 0 {
+			return spec, errors.Errorf("listener %q: invalid file descriptor", s)
+		}
+	case Systemd:
+	default:
+		return spec, errors.Errorf("listener %q: unknown scheme %q: requires tcp, tls, unix, fd or systemd", s, spec.Scheme)
+	}
+	return spec, nil
+}
+
+// String formats the spec as parsed by Parse
+func (s Spec) String() string {
+	return s.Scheme + "://" + s.Address
+}
+
+// Listener is an open listener and the spec it was opened from
+type Listener struct {
+	net.Listener
+	Spec Spec
+
+	// from is the spec given to Open, the listeners of a systemd
+	// spec have one of their own
+	from string
+}
+
+// TLS reports whether connections accepted are expected to speak TLS.
+// The listener does not handshake itself, serving TLS is left to the
+// server, eg. with http.Server.ServeTLS.
+func (l Listener) TLS() bool {
+	return l.Spec.Scheme == TLS
+}
+
+// Open opens the listeners of specs. A systemd spec opens every socket
+// it matches, as fd listeners. Listeners passed on by Upgrade are taken
+// over rather than opened again. On error the listeners already open
+// are closed.
+func Open(specs []Spec) ([]Listener, error) {
+	var lns []Listener
+	activated := false
+	fail := func(err error) ([]Listener, error) {
+		for _, ln := range lns {
+			ln.Close()
+		}
+		return nil, err
+	}
+
+	for _, spec := range specs {
+		if fds := inherit(spec.String()); len(fds) > 0 {
+			for _, fd := range fds {
+				ln, err := fileListener(fd, spec.String())
+				if err != nil {
+					return fail(err)
+				}
+				l := Listener{Listener: ln, Spec: spec, from: spec.String()}
+				switch spec.Scheme {
+				case Systemd:
+					l.Spec = Spec{Scheme: FD, Address: strconv.Itoa(fd)}
+				case Unix:
+					// like the process that opened it, remove the socket on close
+					if ul, ok := ln.(*net.UnixListener); ok {
+						ul.SetUnlinkOnClose(true)
+					}
+				}
+				lns = append(lns, l)
+			}
+			continue
+		}
+
+		switch spec.Scheme {
+		case TCP, TLS:
+			ln, err := net.Listen("tcp", spec.Address)
+			if err != nil {
+				return fail(err)
+			}
+			lns = append(lns, Listener{Listener: ln, Spec: spec, from: spec.String()})
+
+		case Unix:
+			ln, err := listenUnix(spec.Address)
+			if err != nil {
+				return fail(err)
+			}
+			lns = append(lns, Listener{Listener: ln, Spec: spec, from: spec.String()})
+
+		case FD:
+			fd, _ := strconv.Atoi(spec.Address)
+			ln, err := fileListener(fd, spec.String())
+			if err != nil {
+				return fail(err)
+			}
+			lns = append(lns, Listener{Listener: ln, Spec: spec, from: spec.String()})
+
+		case Systemd:
+			activated = true
+			fds, err := systemd(spec.Address)
+			if err != nil {
+				return fail(err)
+			}
+			for _, fd := range fds {
+				ln, err := fileListener(fd, spec.String())
+				if err != nil {
+					return fail(err)
+				}
+				lns = append(lns, Listener{Listener: ln, Spec: Spec{Scheme: FD, Address: strconv.Itoa(fd)}, from: spec.String()})
+			}
+
+		default:
+			return fail(errors.Errorf("listener %s: unknown scheme", spec))
+		}
+	}
+
+	// processes started by DNS must not take the sockets for theirs
+	if activated {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}
+	return lns, nil
+}
+
+// listenUnix listens on the Unix domain socket at path, replacing the
+// socket left behind by a server that did not exit cleanly. The socket
+// is removed when the listener is closed.
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Stat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, errors.Errorf("listener unix://%s: file exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, errors.Errorf("listener unix://%s: socket in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, errors.Wrapf(err, "listener unix://%s", path)
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// fileListener makes a listener of the inherited file descriptor fd
+func fileListener(fd int, name string) (net.Listener, error) {
+	syscall.CloseOnExec(fd)
+	f := os.NewFile(uintptr(fd), name)
+	if f == nil {
+		return nil, errors.Errorf("listener %s: invalid file descriptor", name)
+	}
+	defer f.Close()
+
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "listener %s", name)
+	}
+	return ln, nil
+}
+
+// systemd returns the file descriptors passed by systemd socket
+// activation, all of them or those named name in LISTEN_FDNAMES.
+func systemd(name string) ([]int, error) {
+	pid, fds, names := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES")
+	if pid == "" || fds == "" {
+		return nil, errors.New("listener systemd: LISTEN_FDS not set, the service is not socket activated")
+	}
+	if pid != strconv.Itoa(os.Getpid()) {
+		return nil, errors.Errorf("listener systemd: LISTEN_PID %s is not this process", pid)
+	}
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 1 {
+		return nil, errors.Errorf("listener systemd: invalid LISTEN_FDS %q", fds)
+	}
+	var nameOf []string
+	if names != "" {
+		nameOf = strings.Split(names, ":")
+	}
+	var matched []int
+	for i := 0; i < n; i++ {
+		if name == "" || (i < len(nameOf) && nameOf[i] == name) {
+			matched = append(matched, listenFdsStart+i)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, errors.Errorf("listener systemd: no socket named %q", name)
+	}
+	return matched, nil
+}
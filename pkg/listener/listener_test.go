@@ -0,0 +1,117 @@
+package listener_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/timolinn/dns/pkg/listener"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec string
+		want listener.Spec
+	}{
+		{":8080", listener.Spec{Scheme: listener.TCP, Address: ":8080"}},
+		{"tls://:8443", listener.Spec{Scheme: listener.TLS, Address: ":8443"}},
+		{"unix:///run/dns.sock", listener.Spec{Scheme: listener.Unix, Address: "/run/dns.sock"}},
+		{"fd://3", listener.Spec{Scheme: listener.FD, Address: "3"}},
+		{"systemd://", listener.Spec{Scheme: listener.Systemd}},
+	}
+	for _, tt := range tests {
+		got, err := listener.Parse(tt.spec)
+		if err != nil {
+			t.Errorf("%s: expected nil-err got %s", tt.spec, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: want %+v, got %+v", tt.spec, tt.want, got)
+		}
+	}
+
+	for _, spec := range []string{"udp://:53", "unix://", "fd://three"} {
+		if _, err := listener.Parse(spec); err == nil {
+			t.Errorf("%s: want an error", spec)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "listener")
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "dns.sock")
+
+	t.Run("should replace a stale unix socket", func(t *testing.T) {
+		stale, err := net.Listen("unix", sock)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		stale.Close()
+
+		lns, err := listener.Open([]listener.Spec{{Scheme: listener.Unix, Address: sock}})
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		defer lns[0].Close()
+
+		if _, err := listener.Open([]listener.Spec{{Scheme: listener.Unix, Address: sock}}); err == nil {
+			t.Errorf("want an error opening a socket in use")
+		}
+	})
+
+	t.Run("should open inherited file descriptors", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		defer ln.Close()
+		f, err := ln.(*net.TCPListener).File()
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		// the listener owns the descriptor it is given, like one inherited
+		fd, err := syscall.Dup(int(f.Fd()))
+		f.Close()
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+
+		lns, err := listener.Open([]listener.Spec{
+			{Scheme: listener.TCP, Address: "127.0.0.1:0"},
+			{Scheme: listener.FD, Address: strconv.Itoa(fd)},
+		})
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		defer lns[0].Close()
+		defer lns[1].Close()
+
+		if got := lns[1].Addr().String(); got != ln.Addr().String() {
+			t.Errorf("want the inherited listener on %s, got %s", ln.Addr(), got)
+		}
+		if lns[0].TLS() || lns[1].TLS() {
+			t.Errorf("want plain listeners")
+		}
+	})
+
+	t.Run("should close the listeners opened on error", func(t *testing.T) {
+		_, err := listener.Open([]listener.Spec{
+			{Scheme: listener.Unix, Address: filepath.Join(dir, "other.sock")},
+			{Scheme: listener.Systemd},
+		})
+		if err == nil {
+			t.Fatalf("want an error without socket activation")
+		}
+		if _, err := os.Stat(filepath.Join(dir, "other.sock")); !os.IsNotExist(err) {
+			t.Errorf("want the unix socket removed, got %v", err)
+		}
+	})
+}
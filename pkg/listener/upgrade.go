@@ -0,0 +1,187 @@
+package listener
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Environment variables of a process started by Upgrade
+const (
+	// EnvInherit lists the specs of the listeners passed to the process,
+	// the first one being file descriptor 3, separated by newlines.
+	EnvInherit = "DNS_INHERIT_LISTENERS"
+
+	// EnvReady holds the file descriptor the process reports ready on.
+	EnvReady = "DNS_READY_FD"
+)
+
+// ready is the message a new process writes once it serves
+const ready = "ready\n"
+
+// filer is implemented by the listeners of package net
+type filer interface {
+	File() (*os.File, error)
+}
+
+// Upgrade starts the executable at path with args, passing it lns as
+// inherited file descriptors, and waits up to timeout for the new
+// process to report ready. When the process does not, or ctx is done
+// first, it is killed and an error returned, and lns keep serving. Once
+// Upgrade succeeds the Unix sockets of lns are no longer removed when
+// closed.
+func Upgrade(ctx context.Context, path string, args []string, lns []Listener, timeout time.Duration) (*os.Process, error) {
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	specs := make([]string, 0, len(lns))
+	for _, ln := range lns {
+		fl, ok := ln.Listener.(filer)
+		if !ok {
+			return nil, errors.Errorf("listener %s cannot be passed on", ln.Spec)
+		}
+		f, err := fl.File()
+		if err != nil {
+			return nil, errors.Wrapf(err, "listener %s", ln.Spec)
+		}
+		files = append(files, f)
+		specs = append(specs, ln.from)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "ready pipe")
+	}
+	defer r.Close()
+	files = append(files, w)
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(),
+		EnvInherit+"="+strings.Join(specs, "\n"),
+		EnvReady+"="+strconv.Itoa(listenFdsStart+len(lns)),
+	)
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrap(err, "starting new process")
+	}
+	// only the new process may hold the write end, so that
+	// reading fails as soon as it exits
+	w.Close()
+	files = files[:len(files)-1]
+
+	r.SetReadDeadline(time.Now().Add(timeout))
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.SetReadDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+	msg, err := ioutil.ReadAll(r)
+	if string(msg) != ready {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		cmd.Process.Kill()
+		cmd.Wait()
+		if err == nil {
+			err = errors.New("exited before reporting ready")
+		}
+		return nil, errors.Wrapf(err, "new process %d", cmd.Process.Pid)
+	}
+
+	// the sockets now belong to the new process too
+	for _, ln := range lns {
+		if ul, ok := ln.Listener.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	return cmd.Process, nil
+}
+
+// Ready reports to the process that started this one with Upgrade that
+// it serves, and does nothing when it was not started by Upgrade.
+func Ready() error {
+	s := os.Getenv(EnvReady)
+	if s == "" {
+		return nil
+	}
+	os.Unsetenv(EnvReady)
+	fd, err := strconv.Atoi(s)
+	if err != nil {
+		return errors.Errorf("invalid %s %q", EnvReady, s)
+	}
+	f := os.NewFile(uintptr(fd), "ready")
+	defer f.Close()
+	_, err = f.WriteString(ready)
+	return errors.Wrap(err, "reporting ready")
+}
+
+var (
+	inheritOnce sync.Once
+	inheritMu   sync.Mutex
+	inherited   map[string][]int
+)
+
+// inherit takes the file descriptors passed by Upgrade for the spec s,
+// every descriptor is taken once.
+func inherit(s string) []int {
+	inheritInit()
+	inheritMu.Lock()
+	defer inheritMu.Unlock()
+	fds := inherited[s]
+	delete(inherited, s)
+	return fds
+}
+
+// CloseUnclaimed closes the file descriptors passed by Upgrade that no
+// call to Open took, for listeners the configuration no longer serves,
+// so that their clients are refused instead of left waiting. It returns
+// the specs of the listeners closed.
+func CloseUnclaimed() []string {
+	inheritInit()
+	inheritMu.Lock()
+	defer inheritMu.Unlock()
+	var specs []string
+	for s, fds := range inherited {
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+		specs = append(specs, s)
+		delete(inherited, s)
+	}
+	sort.Strings(specs)
+	return specs
+}
+
+// inheritInit reads the file descriptors passed by Upgrade, once
+func inheritInit() {
+	inheritOnce.Do(func() {
+		inherited = make(map[string][]int)
+		env := os.Getenv(EnvInherit)
+		if env == "" {
+			return
+		}
+		os.Unsetenv(EnvInherit)
+		for i, from := range strings.Split(env, "\n") {
+			inherited[from] = append(inherited[from], listenFdsStart+i)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package listener_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/timolinn/dns/pkg/listener"
+)
+
+// TestUpgradeProcess is the new process started by TestUpgrade
+func TestUpgradeProcess(t *testing.T) {
+	switch os.Getenv("UPGRADE_TEST_PROCESS") {
+	case "":
+		t.Skip("started by TestUpgrade")
+	case "slow":
+		time.Sleep(time.Minute)
+	}
+	// the spec given to the previous process, not its address, the
+	// Unix socket is no longer served
+	lns, err := listener.Open([]listener.Spec{{Scheme: listener.TCP, Address: "127.0.0.1:0"}})
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	unclaimed := listener.CloseUnclaimed()
+	if err := listener.Ready(); err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	conn, err := lns[0].Accept()
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	fmt.Fprintf(conn, "pid %d closed %s", os.Getpid(), strings.Join(unclaimed, ","))
+	conn.Close()
+}
+
+func TestUpgrade(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upgrade")
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "dns.sock")
+
+	lns, err := listener.Open([]listener.Spec{
+		{Scheme: listener.TCP, Address: "127.0.0.1:0"},
+		{Scheme: listener.Unix, Address: sock},
+	})
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	addr := lns[0].Addr().String()
+	ctx := context.Background()
+
+	t.Run("should pass the listeners to the new process", func(t *testing.T) {
+		os.Setenv("UPGRADE_TEST_PROCESS", "1")
+		defer os.Unsetenv("UPGRADE_TEST_PROCESS")
+
+		p, err := listener.Upgrade(ctx, os.Args[0], []string{"-test.run=^TestUpgradeProcess$"}, lns, 10*time.Second)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		defer p.Wait()
+
+		// the new process accepts once this one stops, and closed
+		// the socket it does not serve
+		lns[1].Close()
+		if conn, err := net.Dial("unix", sock); err == nil {
+			conn.Close()
+			t.Errorf("want the unclaimed socket closed")
+		}
+		lns[0].Close()
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		defer conn.Close()
+		got, _ := ioutil.ReadAll(conn)
+		if want := fmt.Sprintf("pid %d closed unix://%s", p.Pid, sock); string(got) != want {
+			t.Errorf("want %q, got %q", want, got)
+		}
+	})
+
+	t.Run("should fail when the new process does not report ready", func(t *testing.T) {
+		lns, err := listener.Open([]listener.Spec{{Scheme: listener.TCP, Address: "127.0.0.1:0"}})
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		defer lns[0].Close()
+
+		// without UPGRADE_TEST_PROCESS the new process exits right away
+		if _, err := listener.Upgrade(ctx, os.Args[0], []string{"-test.run=^TestUpgradeProcess$"}, lns, 10*time.Second); err == nil {
+			t.Errorf("want an error")
+		}
+	})
+
+	t.Run("should stop the new process when canceled", func(t *testing.T) {
+		lns, err := listener.Open([]listener.Spec{{Scheme: listener.TCP, Address: "127.0.0.1:0"}})
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		defer lns[0].Close()
+
+		os.Setenv("UPGRADE_TEST_PROCESS", "slow")
+		defer os.Unsetenv("UPGRADE_TEST_PROCESS")
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err = listener.Upgrade(ctx, os.Args[0], []string{"-test.run=^TestUpgradeProcess$"}, lns, 10*time.Second)
+		if errors.Cause(err) != context.DeadlineExceeded {
+			t.Errorf("want %v, got %v", context.DeadlineExceeded, err)
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Errorf("Should give up once canceled, took %v", time.Since(start))
+		}
+	})
+}
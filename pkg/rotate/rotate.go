@@ -0,0 +1,340 @@
+// Package rotate provides a log file writer that rotates the file by
+// size and age, compresses rotated files and prunes old ones
+package rotate
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// backupFormat is appended to the file name of rotated files, files
+// rotated within the same millisecond get a -<n> sequence suffix
+const backupFormat = "20060102T150405.000"
+
+// Config configures when a Writer rotates and which rotated files it keeps
+type Config struct {
+	// Filename is the file written to, rotated files are kept beside it.
+	Filename string
+	// MaxSize rotates the file before it grows over MaxSize bytes.
+	MaxSize int64
+	// Interval rotates the file once it has been written for Interval.
+	Interval time.Duration
+	// Compress gzips rotated files.
+	Compress bool
+	// MaxBackups is the number of rotated files kept, 0 keeps them all.
+	MaxBackups int
+	// MaxAge removes rotated files older than MaxAge, 0 keeps them all.
+	MaxAge time.Duration
+}
+
+// Writer is an io.WriteCloser writing to a rotated file, it is safe
+// for concurrent use. Each Write is written to a single file. Several
+// processes may write the same file, like a server upgraded and the one
+// it replaces: the file is locked while written, and a Writer follows
+// the file another process rotated.
+type Writer struct {
+	cfg Config
+
+	mu     sync.Mutex
+	file   *os.File
+	closed bool
+	size   int64
+	opened time.Time
+
+	// housekeeping compresses and prunes rotated files one rotation
+	// at a time, so pruning never sees a half compressed file
+	housekeeping sync.Mutex
+	wg           sync.WaitGroup
+}
+
+// New opens the file described by cfg for appending
+func New(cfg Config) (*Writer, error) {
+	if cfg.Filename == "" {
+		return nil, errors.New("rotate: missing file name")
+	}
+	w := Writer{cfg: cfg}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// Write implements io.Writer, rotating the file first when p
+// would grow it over its maximum size or when it is too old.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	// a rotation that failed to open the new file is retried
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if err := w.lock(); err != nil {
+		return 0, err
+	}
+	defer w.unlock()
+
+	tooBig := w.cfg.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.cfg.MaxSize
+	tooOld := w.cfg.Interval > 0 && time.Since(w.opened) >= w.cfg.Interval
+	var rotateErr error
+	if tooBig || tooOld {
+		// p still goes to the file kept when the rotation failed
+		if rotateErr = w.rotate(); w.file == nil {
+			return 0, rotateErr
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+// Rotate rotates the file immediately
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file != nil {
+		if err := w.lock(); err != nil {
+			return err
+		}
+		defer w.unlock()
+	}
+	return w.rotate()
+}
+
+// Close closes the file, waiting for rotated files to be compressed
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	return err
+}
+
+// open opens the file for appending, it must be called with w.mu held.
+func (w *Writer) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.cfg.Filename), 0755); err != nil {
+		return errors.Wrap(err, "rotate: creating log directory")
+	}
+	f, err := os.OpenFile(w.cfg.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrap(err, "rotate: opening log file")
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "rotate: opening log file")
+	}
+	w.file, w.size, w.opened = f, info.Size(), time.Now()
+	return nil
+}
+
+// lock locks the file against other processes writing it, until unlock
+// is called. When another process rotated it meanwhile, the new file is
+// opened and locked instead. It must be called with w.mu held.
+func (w *Writer) lock() error {
+	for {
+		if err := syscall.Flock(int(w.file.Fd()), syscall.LOCK_EX); err != nil {
+			return errors.Wrap(err, "rotate: locking log file")
+		}
+		info, err := w.file.Stat()
+		if err != nil {
+			return errors.Wrap(err, "rotate: locking log file")
+		}
+		if current, err := os.Stat(w.cfg.Filename); err == nil && os.SameFile(info, current) {
+			// other processes grew the file too
+			w.size = info.Size()
+			return nil
+		}
+
+		// closing the file unlocks it
+		w.file.Close()
+		w.file = nil
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+}
+
+// unlock unlocks the file locked by lock, it must be called with w.mu held
+func (w *Writer) unlock() {
+	if w.file != nil {
+		syscall.Flock(int(w.file.Fd()), syscall.LOCK_UN)
+	}
+}
+
+// rotate moves the current file aside and opens a new one. Compression
+// and pruning of rotated files happen in the background. When the file
+// can not be moved aside writes carry on in it. It must be called with
+// w.mu held, and the file locked, which closing the file moved aside
+// unlocks once the new one exists.
+func (w *Writer) rotate() error {
+	backup := w.backupName(time.Now())
+	if err := os.Rename(w.cfg.Filename, backup); err != nil && !os.IsNotExist(err) {
+		if w.file == nil {
+			if err := w.open(); err != nil {
+				return err
+			}
+		}
+		return errors.Wrap(err, "rotate: renaming log file")
+	}
+
+	// the new file exists before the file moved aside is unlocked
+	old := w.file
+	err := w.open()
+	var closeErr error
+	if old != nil {
+		closeErr = old.Close()
+	}
+	if err != nil {
+		w.file = nil
+		return err
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.housekeeping.Lock()
+		defer w.housekeeping.Unlock()
+		if w.cfg.Compress {
+			compress(backup)
+		}
+		w.prune()
+	}()
+	return errors.Wrap(closeErr, "rotate: closing log file")
+}
+
+// backupName returns the name of a file rotated at t, that no file
+// rotated earlier uses
+func (w *Writer) backupName(t time.Time) string {
+	name := w.cfg.Filename + "." + t.UTC().Format(backupFormat)
+	for seq := 1; ; seq++ {
+		_, err := os.Lstat(name)
+		_, gzErr := os.Lstat(name + ".gz")
+		if os.IsNotExist(err) && os.IsNotExist(gzErr) {
+			return name
+		}
+		name = w.cfg.Filename + "." + t.UTC().Format(backupFormat) + "-" + strconv.Itoa(seq)
+	}
+}
+
+// prune removes the rotated files exceeding MaxBackups or MaxAge
+func (w *Writer) prune() {
+	if w.cfg.MaxBackups <= 0 && w.cfg.MaxAge <= 0 {
+		return
+	}
+	backups, err := w.backups()
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-w.cfg.MaxAge)
+	for i, b := range backups {
+		tooMany := w.cfg.MaxBackups > 0 && i >= w.cfg.MaxBackups
+		tooOld := w.cfg.MaxAge > 0 && b.at.Before(cutoff)
+		if tooMany || tooOld {
+			os.Remove(b.path)
+		}
+	}
+}
+
+type backup struct {
+	path string
+	at   time.Time
+	seq  int
+}
+
+// backups lists the rotated files, newest first
+func (w *Writer) backups() ([]backup, error) {
+	dir, base := filepath.Split(w.cfg.Filename)
+	if dir == "" {
+		dir = "."
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backup
+	for _, info := range infos {
+		name := info.Name()
+		if !strings.HasPrefix(name, base+".") {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, base+"."), ".gz")
+		var seq int
+		if i := strings.LastIndex(stamp, "-"); i >= 0 {
+			if seq, err = strconv.Atoi(stamp[i+1:]); err != nil {
+				continue
+			}
+			stamp = stamp[:i]
+		}
+		at, err := time.Parse(backupFormat, stamp)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(dir, name), at: at, seq: seq})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].at.Equal(backups[j].at) {
+			return backups[i].at.After(backups[j].at)
+		}
+		return backups[i].seq > backups[j].seq
+	})
+	return backups, nil
+}
+
+// compress gzips path into path.gz and removes path
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,159 @@
+package rotate_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/timolinn/dns/pkg/rotate"
+)
+
+func TestWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := rotate.New(rotate.Config{
+		Filename:   filepath.Join(dir, "access.log"),
+		MaxSize:    10,
+		Compress:   true,
+		MaxBackups: 2,
+	})
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+
+	for _, line := range []string{"line-one\n", "line-two\n", "line-three\n", "line-four\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		// rotated files are named after the time they were rotated
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+
+	current, err := ioutil.ReadFile(filepath.Join(dir, "access.log"))
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	if string(current) != "line-four\n" {
+		t.Errorf("want the last line in the current file, got %q", current)
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	var backups []string
+	for _, info := range infos {
+		if info.Name() != "access.log" {
+			backups = append(backups, info.Name())
+		}
+	}
+	if len(backups) != 2 {
+		t.Fatalf("want 2 backups, got %v", backups)
+	}
+	for _, b := range backups {
+		if !strings.HasSuffix(b, ".gz") {
+			t.Errorf("want compressed backups, got %s", b)
+		}
+	}
+}
+
+func TestWriterRotateQuickly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := rotate.New(rotate.Config{Filename: filepath.Join(dir, "access.log")})
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	// rotations within the same millisecond keep every rotated file
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write([]byte("line\n")); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		if err := w.Rotate(); err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	if len(infos) != 6 {
+		t.Fatalf("want the current file and 5 backups, got %d files", len(infos))
+	}
+	for _, info := range infos {
+		if info.Name() != "access.log" && info.Size() != int64(len("line\n")) {
+			t.Errorf("want a line in %s, got %d bytes", info.Name(), info.Size())
+		}
+	}
+
+	if _, err := w.Write([]byte("late\n")); err != os.ErrClosed {
+		t.Errorf("want os.ErrClosed once closed, got %v", err)
+	}
+}
+
+func TestWriterShared(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// like a server upgraded and the one it replaces
+	cfg := rotate.Config{Filename: filepath.Join(dir, "access.log"), MaxSize: 20}
+	var writers []*rotate.Writer
+	for i := 0; i < 2; i++ {
+		w, err := rotate.New(cfg)
+		if err != nil {
+			t.Fatalf("expected nil-err got %s", err)
+		}
+		writers = append(writers, w)
+	}
+	for i := 0; i < 10; i++ {
+		for j, w := range writers {
+			if _, err := w.Write([]byte(strings.Repeat(strconv.Itoa(j), 9) + "\n")); err != nil {
+				t.Fatalf("expected nil-err got %s", err)
+			}
+		}
+	}
+	for _, w := range writers {
+		w.Close()
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	lines := 0
+	for _, info := range infos {
+		if info.Size() > cfg.MaxSize {
+			t.Errorf("%s grew to %d bytes", info.Name(), info.Size())
+		}
+		data, _ := ioutil.ReadFile(filepath.Join(dir, info.Name()))
+		lines += strings.Count(string(data), "\n")
+	}
+	if lines != 20 {
+		t.Errorf("want 20 lines, got %d", lines)
+	}
+	if len(infos) != 10 {
+		t.Errorf("want 10 files of 2 lines, got %d", len(infos))
+	}
+}
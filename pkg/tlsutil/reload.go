@@ -0,0 +1,104 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CertReloader serves a certificate loaded from a PEM encoded certificate
+// and key file pair, loading it again when the files change on disk, so
+// that renewed certificates are picked up without a restart.
+type CertReloader struct {
+	certFile, keyFile string
+	interval          time.Duration
+	log               *log.Logger
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	stamp   string
+	checked time.Time
+}
+
+// NewCertReloader loads the certificate and key files, checking them for
+// changes at most every interval during handshakes. Reloading is
+// disabled when interval is 0. Certificates that fail to load are
+// reported to log, and the previous certificate is kept.
+func NewCertReloader(certFile, keyFile string, interval time.Duration, log *log.Logger) (*CertReloader, error) {
+	cr := CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+		log:      log,
+	}
+	if err := cr.Reload(); err != nil {
+		return nil, err
+	}
+	return &cr, nil
+}
+
+// Reload loads the certificate and key files again
+func (cr *CertReloader) Reload() error {
+	stamp, err := cr.stat()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "loading TLS certificate")
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.cert, cr.stamp, cr.checked = &cert, stamp, time.Now()
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (cr *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.Lock()
+	due := cr.interval > 0 && time.Since(cr.checked) >= cr.interval
+	if due {
+		cr.checked = time.Now()
+	}
+	stamp, cert := cr.stamp, cr.cert
+	cr.mu.Unlock()
+
+	if !due {
+		return cert, nil
+	}
+	if current, err := cr.stat(); err == nil && current == stamp {
+		return cert, nil
+	}
+	if err := cr.Reload(); err != nil {
+		if cr.log != nil {
+			cr.log.Printf("tlsutil: keeping the current certificate: %v", err)
+		}
+		return cert, nil
+	}
+	if cr.log != nil {
+		cr.log.Printf("tlsutil: reloaded certificate %s", cr.certFile)
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	return cr.cert, nil
+}
+
+// stat identifies the current version of the certificate and key files
+func (cr *CertReloader) stat() (string, error) {
+	var stamp string
+	for _, path := range []string{cr.certFile, cr.keyFile} {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return "", errors.Wrap(err, "checking TLS certificate")
+		}
+		stamp += fmt.Sprintf("%s:%d:%d;", path, fi.ModTime().UnixNano(), fi.Size())
+	}
+	return stamp, nil
+}
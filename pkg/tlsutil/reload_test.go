@@ -0,0 +1,97 @@
+package tlsutil_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/timolinn/dns/pkg/tlsutil"
+)
+
+// writeCert writes a self-signed certificate for name and its key
+func writeCert(t *testing.T, certFile, keyFile, name string, mtime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{name},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	os.Chtimes(certFile, mtime, mtime)
+	os.Chtimes(keyFile, mtime, mtime)
+}
+
+func commonName(t *testing.T, cr *tlsutil.CertReloader) string {
+	cert, err := cr.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tlsutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	now := time.Now()
+	writeCert(t, certFile, keyFile, "old.dns", now.Add(-time.Minute))
+	cr, err := tlsutil.NewCertReloader(certFile, keyFile, time.Nanosecond, nil)
+	if err != nil {
+		t.Fatalf("expected nil-err got %s", err)
+	}
+
+	t.Run("should reload a renewed certificate", func(t *testing.T) {
+		writeCert(t, certFile, keyFile, "new.dns", now)
+		if name := commonName(t, cr); name != "new.dns" {
+			t.Errorf("want new.dns, got %s", name)
+		}
+	})
+
+	t.Run("should keep the certificate when the new one is invalid", func(t *testing.T) {
+		ioutil.WriteFile(keyFile, []byte("not a key"), 0600)
+		if name := commonName(t, cr); name != "new.dns" {
+			t.Errorf("want new.dns, got %s", name)
+		}
+	})
+}
+
+func TestCipherSuites(t *testing.T) {
+	suites, err := tlsutil.CipherSuites([]string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"})
+	if err != nil || !tlsutil.HTTP2CipherSuite(suites) {
+		t.Errorf("want an HTTP/2 suite, got %v, %v", suites, err)
+	}
+	if _, err := tlsutil.CipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"}); err == nil {
+		t.Errorf("want insecure suite rejected")
+	}
+}
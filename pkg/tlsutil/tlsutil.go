@@ -0,0 +1,86 @@
+// Package tlsutil builds the TLS configuration DNS serves with
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// LoadCertPool reads a PEM encoded CA bundle
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading CA bundle")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.Errorf("no certificates found in CA bundle %s", path)
+	}
+	return pool, nil
+}
+
+// ClientAuth parses how client certificates are handled: "none" ignores
+// them, "request" verifies them when presented and "require" rejects
+// handshakes without a valid certificate.
+func ClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "", "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return tls.NoClientCert, errors.Errorf("unknown client auth mode %q: requires 'none', 'request' or 'require'", mode)
+}
+
+// versions maps the names of TLS versions to their ID
+var versions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseVersion parses a TLS version written "1.0" to "1.3"
+func ParseVersion(s string) (uint16, error) {
+	v, ok := versions[s]
+	if !ok {
+		return 0, errors.Errorf("unknown TLS version %q: requires '1.0', '1.1', '1.2' or '1.3'", s)
+	}
+	return v, nil
+}
+
+// CipherSuites parses cipher suites by their IANA name, eg.
+// TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256. Suites known to be insecure
+// are rejected. The suites of TLS 1.3 are not configurable.
+func CipherSuites(names []string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, errors.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// HTTP2CipherSuite reports whether one of the suites is the
+// TLS_ECDHE_*_WITH_AES_128_GCM_SHA256 suite HTTP/2 requires.
+func HTTP2CipherSuite(suites []uint16) bool {
+	for _, id := range suites {
+		if id == tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || id == tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+			return true
+		}
+	}
+	return false
+}
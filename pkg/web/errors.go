@@ -1,5 +1,15 @@
 package web
 
+import "errors"
+
+var (
+	// ErrTimeout is reported when a request runs past its deadline.
+	ErrTimeout = errors.New("request timed out")
+
+	// ErrCanceled is reported when a request is canceled before completing.
+	ErrCanceled = errors.New("request canceled")
+)
+
 // Error represents errors that happens on the web layer
 type Error struct {
 	Err    error
@@ -1,7 +1,10 @@
 package web
 
 import (
+	"compress/gzip"
+	"compress/zlib"
 	"encoding/json"
+	"io"
 	"net/http"
 	"reflect"
 	"strings"
@@ -17,8 +20,13 @@ import (
 var validate = validator.New()
 var translator *ut.UniversalTranslator
 
+// MaxDecodedBodySize caps the size of compressed request bodies once
+// decompressed, so a small payload can not expand without bound.
+var MaxDecodedBodySize int64 = 1 << 20
+
 var (
 	ErrMalformedRequestData = errors.New("malformed request data")
+	ErrUnsupportedEncoding  = errors.New("unsupported content encoding: requires 'gzip', 'deflate' or 'identity'")
 )
 
 func init() {
@@ -44,13 +52,26 @@ func init() {
 	})
 }
 
-// Decode unmarshals request data into val interface
+// Decode unmarshals request data into val interface, transparently
+// decompressing gzip and deflate encoded bodies.
 func Decode(r *http.Request, val interface{}) error {
-	decoder := json.NewDecoder(r.Body)
+	body, err := decodeBody(r)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	decoder := json.NewDecoder(body)
 	decoder.DisallowUnknownFields()
 	if err := decoder.Decode(val); err != nil {
 		return NewRequestError(ErrMalformedRequestData, http.StatusBadRequest)
 	}
+	return Validate(val)
+}
+
+// Validate checks val against its validate struct tags, reporting
+// the fields failing them in an *Error
+func Validate(val interface{}) error {
 	if err := validate.Struct(val); err != nil {
 		// Use a type assertion to get the real error value.
 		verrors, ok := err.(validator.ValidationErrors)
@@ -78,6 +99,35 @@ func Decode(r *http.Request, val interface{}) error {
 	return nil
 }
 
+// decodeBody returns a reader over the request body
+// undoing its Content-Encoding
+func decodeBody(r *http.Request) (io.ReadCloser, error) {
+	var (
+		body io.ReadCloser
+		err  error
+	)
+	switch strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return r.Body, nil
+	case "gzip", "x-gzip":
+		body, err = gzip.NewReader(r.Body)
+	case "deflate":
+		body, err = zlib.NewReader(r.Body)
+	default:
+		return nil, NewRequestError(ErrUnsupportedEncoding, http.StatusUnsupportedMediaType)
+	}
+	if err != nil {
+		return nil, NewRequestError(ErrMalformedRequestData, http.StatusBadRequest)
+	}
+	return limitedBody{io.LimitReader(body, MaxDecodedBodySize), body}, nil
+}
+
+// limitedBody caps reads from a decompressing reader
+type limitedBody struct {
+	io.Reader
+	io.Closer
+}
+
 // Params extracts web params
 func Params(r *http.Request) map[string]string {
 	return mux.Vars(r)
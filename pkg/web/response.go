@@ -22,6 +22,12 @@ func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statu
 	// Set the statusCode for the http request logger middleware.
 	v.StatusCode = statusCode
 
+	// a 204 response must not carry a body
+	if statusCode == http.StatusNoContent {
+		w.WriteHeader(statusCode)
+		return nil
+	}
+
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return err
@@ -37,8 +43,17 @@ func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statu
 	return nil
 }
 
-// RespondError sends error response to the client
+// RespondError sends error response to the client. Errors caused by the
+// request context ending are reported as 504 when its deadline passed
+// and 503 when it was canceled.
 func RespondError(ctx context.Context, w http.ResponseWriter, err error) error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		err = NewRequestError(ErrTimeout, http.StatusGatewayTimeout)
+	case errors.Is(err, context.Canceled):
+		err = NewRequestError(ErrCanceled, http.StatusServiceUnavailable)
+	}
+
 	// check if err is a web error
 	if webErr, ok := (err).(*Error); ok {
 		er := ErrorResponse{
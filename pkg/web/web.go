@@ -7,10 +7,14 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
 )
 
 type key int
@@ -18,10 +22,24 @@ type key int
 // KeyValues is how request values or stored/retrieved.
 const KeyValues key = 1
 
+// HeaderRequestID carries the ID identifying a request in logs and audits
+const HeaderRequestID = "X-Request-ID"
+
 // Values represent state for each request.
 type Values struct {
+	TraceID    string
 	Now        time.Time
 	StatusCode int
+
+	draining <-chan struct{}
+}
+
+// Request is a request an App is serving
+type Request struct {
+	ID      string    `json:"id"`
+	Method  string    `json:"method"`
+	Path    string    `json:"path"`
+	Started time.Time `json:"started"`
 }
 
 // A Handler handles http requests
@@ -32,6 +50,13 @@ type App struct {
 	*mux.Router
 	shutdown chan os.Signal
 	mw       []Middleware
+	methods  map[string][]string
+
+	mu       sync.Mutex
+	next     uint64
+	inflight map[uint64]Request
+	draining chan struct{}
+	drain    sync.Once
 }
 
 // NewApp constructs an App
@@ -40,16 +65,61 @@ func NewApp(shutdown chan os.Signal, mw ...Middleware) *App {
 		Router:   mux.NewRouter(),
 		shutdown: shutdown,
 		mw:       mw,
+		methods:  make(map[string][]string),
+		inflight: make(map[uint64]Request),
+		draining: make(chan struct{}),
 	}
 
 	return app
 }
 
-// MountHandler mounts a http handler on the router
+// MountHandler mounts a http handler on the router. Every mounted path
+// also answers OPTIONS requests, listing its methods in the Allow header,
+// so that application middlewares can handle CORS preflight requests.
 func (a *App) MountHandler(verb, path string, handler Handler, mw ...Middleware) {
-	handler = wrapMiddleware(mw, handler)
+	if _, ok := a.methods[path]; !ok {
+		a.handle(http.MethodOptions, path, a.options(path))
+	}
+	a.methods[path] = append(a.methods[path], verb)
+
+	a.handle(verb, path, wrapMiddleware(mw, handler))
+}
+
+// Route is a path and method an App serves
+type Route struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
 
-	// wrap application level middlewares
+// Routes returns the mounted routes sorted by path, the OPTIONS
+// answered for every path left out
+func (a *App) Routes() []Route {
+	var routes []Route
+	for path, verbs := range a.methods {
+		for _, verb := range verbs {
+			routes = append(routes, Route{Method: verb, Path: path})
+		}
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// options answers OPTIONS requests for path
+func (a *App) options(path string) Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		allow := append([]string{http.MethodOptions}, a.methods[path]...)
+		w.Header().Set("Allow", strings.Join(allow, ", "))
+		return Respond(ctx, w, nil, http.StatusNoContent)
+	}
+}
+
+// handle mounts handler wrapped in the application level middlewares
+func (a *App) handle(verb, path string, handler Handler) {
 	handler = wrapMiddleware(a.mw, handler)
 
 	h := func(w http.ResponseWriter, r *http.Request) {
@@ -57,10 +127,23 @@ func (a *App) MountHandler(verb, path string, handler Handler, mw ...Middleware)
 
 		// add relevant values the context for propagation
 		v := Values{
-			Now: time.Now(),
+			TraceID:  requestID(r),
+			Now:      time.Now(),
+			draining: a.draining,
 		}
+		w.Header().Set(HeaderRequestID, v.TraceID)
 		ctx := context.WithValue(r.Context(), KeyValues, &v)
 
+		defer a.track(Request{ID: v.TraceID, Method: r.Method, Path: r.URL.Path, Started: v.Now})()
+
+		// clients are asked to reconnect, to another instance
+		// once load balancers stop routing to this one
+		select {
+		case <-a.draining:
+			w.Header().Set("Connection", "close")
+		default:
+		}
+
 		if err := handler(ctx, w, r); err != nil {
 			log.Println(err)
 			a.Shutdown()
@@ -70,6 +153,66 @@ func (a *App) MountHandler(verb, path string, handler Handler, mw ...Middleware)
 	a.HandleFunc(path, h).Methods(verb)
 }
 
+// requestID returns the request ID set by the client or a proxy
+// in front of DNS, or a new one
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(HeaderRequestID); id != "" && len(id) <= 128 {
+		return id
+	}
+	return uuid.NewV4().String()
+}
+
+// track counts r in flight until the returned func is called
+func (a *App) track(r Request) func() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	id := a.next
+	a.next++
+	a.inflight[id] = r
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		delete(a.inflight, id)
+	}
+}
+
+// InFlight returns the requests being served, oldest first
+func (a *App) InFlight() []Request {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	reqs := make([]Request, 0, len(a.inflight))
+	for _, r := range a.inflight {
+		reqs = append(reqs, r)
+	}
+	sort.Slice(reqs, func(i, j int) bool { return reqs[i].Started.Before(reqs[j].Started) })
+	return reqs
+}
+
+// Drain starts draining the App: responses to requests received from
+// then on carry Connection: close so that clients do not reuse their
+// connection, and the channel returned by Draining is closed so that
+// long-lived responses can end cleanly. Requests already in flight are
+// left to the server, see http.Server.SetKeepAlivesEnabled.
+func (a *App) Drain() {
+	a.drain.Do(func() { close(a.draining) })
+}
+
+// Draining returns a channel closed once the App starts draining
+func (a *App) Draining() <-chan struct{} {
+	return a.draining
+}
+
+// Draining returns a channel closed once the App serving the request
+// of ctx starts draining. Handlers streaming a response should end it
+// when the channel is closed.
+func Draining(ctx context.Context) <-chan struct{} {
+	v, ok := ctx.Value(KeyValues).(*Values)
+	if !ok {
+		return nil
+	}
+	return v.draining
+}
+
 // Shutdown sends a sigterm signal to the app to shutdown gracefully
 func (a *App) Shutdown() {
 	a.shutdown <- syscall.SIGTERM
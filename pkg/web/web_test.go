@@ -0,0 +1,92 @@
+package web_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/timolinn/dns/pkg/web"
+)
+
+func TestDrain(t *testing.T) {
+	shutdown := make(chan os.Signal, 1)
+	app := web.NewApp(shutdown)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	app.MountHandler(http.MethodGet, "/slow", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		close(started)
+		<-release
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	})
+	app.MountHandler(http.MethodGet, "/stream", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		<-web.Draining(ctx)
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	})
+
+	t.Run("should track requests in flight", func(t *testing.T) {
+		done := make(chan struct{})
+		go func() {
+			r := httptest.NewRequest(http.MethodGet, "/slow", nil)
+			r.Header.Set(web.HeaderRequestID, "slow-1")
+			app.ServeHTTP(httptest.NewRecorder(), r)
+			close(done)
+		}()
+		<-started
+
+		inflight := app.InFlight()
+		if len(inflight) != 1 || inflight[0].ID != "slow-1" || inflight[0].Path != "/slow" {
+			t.Errorf("want the slow request in flight, got %+v", inflight)
+		}
+		close(release)
+		<-done
+		if inflight := app.InFlight(); len(inflight) != 0 {
+			t.Errorf("want no request in flight, got %+v", inflight)
+		}
+	})
+
+	t.Run("should close connections and streams while draining", func(t *testing.T) {
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+			done <- w
+		}()
+		app.Drain()
+		app.Drain()
+
+		if w := <-done; w.Code != http.StatusNoContent {
+			t.Errorf("Should receive status code %d, got %d", http.StatusNoContent, w.Code)
+		}
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+		if got := w.Header().Get("Connection"); got != "close" {
+			t.Errorf("want Connection: close, got %q", got)
+		}
+	})
+}
+
+func TestRoutes(t *testing.T) {
+	shutdown := make(chan os.Signal, 1)
+	app := web.NewApp(shutdown)
+	h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error { return nil }
+	app.MountHandler(http.MethodPost, "/v1/locate", h)
+	app.MountHandler(http.MethodGet, "/", h)
+	app.MountHandler(http.MethodGet, "/v1/locate", h)
+
+	want := []web.Route{
+		{Method: http.MethodGet, Path: "/"},
+		{Method: http.MethodGet, Path: "/v1/locate"},
+		{Method: http.MethodPost, Path: "/v1/locate"},
+	}
+	got := app.Routes()
+	if len(got) != len(want) {
+		t.Fatalf("want %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("want %v, got %v", want[i], got[i])
+		}
+	}
+}